@@ -0,0 +1,419 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+const (
+	BackupVersion = 1
+
+	FormatJson = "json"
+	FormatYaml = "yaml"
+
+	ErrBackupVersion = "unsupported backup version"
+	ErrBackupFormat  = "the format needs to be json or yaml"
+)
+
+// This is everything needed to recreate the collector's sources, Discord web hooks and subscriptions.
+// The IDs are the ones the collector used when it was exported, subscriptions point at them.
+type Backup struct {
+	Version          int                  `yaml:"version" json:"version"`
+	ExportedAt       time.Time            `yaml:"exportedAt" json:"exportedAt"`
+	CollectorVersion string               `yaml:"collectorVersion,omitempty" json:"collectorVersion,omitempty"`
+	Sources          []BackupSource       `yaml:"sources" json:"sources"`
+	Webhooks         []BackupWebhook      `yaml:"webhooks" json:"webhooks"`
+	Subscriptions    []BackupSubscription `yaml:"subscriptions" json:"subscriptions"`
+}
+
+type BackupSource struct {
+	ID      uuid.UUID `yaml:"id" json:"id"`
+	Source  string    `yaml:"source" json:"source"`
+	Name    string    `yaml:"name" json:"name"`
+	Url     string    `yaml:"url,omitempty" json:"url,omitempty"`
+	Enabled bool      `yaml:"enabled" json:"enabled"`
+	Tags    []string  `yaml:"tags,omitempty" json:"tags,omitempty"`
+}
+
+type BackupWebhook struct {
+	ID      uuid.UUID `yaml:"id" json:"id"`
+	Server  string    `yaml:"server" json:"server"`
+	Channel string    `yaml:"channel" json:"channel"`
+	Url     string    `yaml:"url" json:"url"`
+	Enabled bool      `yaml:"enabled" json:"enabled"`
+}
+
+type BackupSubscription struct {
+	ID        uuid.UUID `yaml:"id" json:"id"`
+	WebhookID uuid.UUID `yaml:"webhookId" json:"webhookId"`
+	SourceID  uuid.UUID `yaml:"sourceId" json:"sourceId"`
+}
+
+// This reads everything from the collector into a backup.
+// Deleted sources are left out, along with any subscription that points at them.
+func Export(ctx context.Context, client api.CollectorApi) (Backup, error) {
+	b := Backup{
+		Version:       BackupVersion,
+		ExportedAt:    time.Now().UTC(),
+		Sources:       []BackupSource{},
+		Webhooks:      []BackupWebhook{},
+		Subscriptions: []BackupSubscription{},
+	}
+
+	info, err := client.Info(ctx)
+	if err == nil {
+		b.CollectorVersion = info.Version
+	}
+
+	sources, err := client.Sources().List(ctx)
+	if err != nil {
+		return b, fmt.Errorf("unable to list the sources: %w", err)
+	}
+	knownSources := make(map[uuid.UUID]bool)
+	if sources != nil {
+		for _, item := range *sources {
+			if item.Deleted {
+				continue
+			}
+			knownSources[item.ID] = true
+			b.Sources = append(b.Sources, BackupSource{
+				ID:      item.ID,
+				Source:  item.Source,
+				Name:    item.Name,
+				Url:     item.Url,
+				Enabled: item.Enabled,
+				Tags:    item.Tags,
+			})
+		}
+	}
+
+	webhooks, err := client.Outputs().DiscordWebHook().List(ctx)
+	if err != nil {
+		return b, fmt.Errorf("unable to list the Discord web hooks: %w", err)
+	}
+	knownWebhooks := make(map[uuid.UUID]bool)
+	if webhooks != nil {
+		for _, item := range *webhooks {
+			knownWebhooks[item.ID] = true
+			b.Webhooks = append(b.Webhooks, BackupWebhook{
+				ID:      item.ID,
+				Server:  item.Server,
+				Channel: item.Channel,
+				Url:     item.Url,
+				Enabled: item.Enabled,
+			})
+		}
+	}
+
+	subscriptions, err := client.Subscriptions().List(ctx)
+	if err != nil {
+		return b, fmt.Errorf("unable to list the subscriptions: %w", err)
+	}
+	for _, item := range subscriptions {
+		if !knownSources[item.SourceId] || !knownWebhooks[item.DiscordWebhookId] {
+			continue
+		}
+		b.Subscriptions = append(b.Subscriptions, BackupSubscription{
+			ID:        item.ID,
+			WebhookID: item.DiscordWebhookId,
+			SourceID:  item.SourceId,
+		})
+	}
+
+	return b, nil
+}
+
+// Writes the backup as json or yaml.
+func (b Backup) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatJson:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(b)
+	case FormatYaml:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		err := enc.Encode(b)
+		if err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return errors.New(ErrBackupFormat)
+}
+
+// This reads a backup written as json or yaml.
+func ParseBackup(r io.Reader) (Backup, error) {
+	var b Backup
+
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	err := dec.Decode(&b)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return b, fmt.Errorf("unable to parse the backup: %w", err)
+	}
+
+	if b.Version != BackupVersion {
+		return b, fmt.Errorf("%v %v, expected %v", ErrBackupVersion, b.Version, BackupVersion)
+	}
+
+	// A record listed twice would be created twice, and the subscriptions could only point at one of them.
+	sources := make(map[string]bool)
+	sourceIDs := make(map[uuid.UUID]bool)
+	for i, s := range b.Sources {
+		key := sourceKey(s.Source, s.Name)
+		switch {
+		case s.Source == "" || s.Name == "":
+			return b, fmt.Errorf("sources[%v] needs a source and a name", i)
+		case sources[key]:
+			return b, fmt.Errorf("sources[%v] '%v' is listed more than once", i, key)
+		case sourceIDs[s.ID]:
+			return b, fmt.Errorf("sources[%v] has the ID %v which is used more than once", i, s.ID)
+		}
+		sources[key] = true
+		sourceIDs[s.ID] = true
+	}
+
+	webhooks := make(map[string]bool)
+	webhookIDs := make(map[uuid.UUID]bool)
+	for i, w := range b.Webhooks {
+		key := webhookKey(w.Server, w.Channel)
+		switch {
+		case w.Server == "" || w.Channel == "" || w.Url == "":
+			return b, fmt.Errorf("webhooks[%v] needs a server, channel and url", i)
+		case webhooks[key]:
+			return b, fmt.Errorf("webhooks[%v] '%v' is listed more than once", i, key)
+		case webhookIDs[w.ID]:
+			return b, fmt.Errorf("webhooks[%v] has the ID %v which is used more than once", i, w.ID)
+		}
+		webhooks[key] = true
+		webhookIDs[w.ID] = true
+	}
+
+	subscriptionIDs := make(map[uuid.UUID]bool)
+	for i, item := range b.Subscriptions {
+		if subscriptionIDs[item.ID] {
+			return b, fmt.Errorf("subscriptions[%v] has the ID %v which is used more than once", i, item.ID)
+		}
+		subscriptionIDs[item.ID] = true
+	}
+
+	return b, nil
+}
+
+// This is what an import did, and what it could not do.
+// Conflicts are records that already exist but differ from the backup, they are only reported when KeepExisting is set.
+type ImportResult struct {
+	DryRun    bool     `json:"dryRun"`
+	Changes   []Change `json:"changes"`
+	Conflicts []string `json:"conflicts"`
+	Warnings  []string `json:"warnings"`
+}
+
+type ImportOptions struct {
+	// Leave records that already exist exactly as they are and report how they differ,
+	// instead of changing their enabled state to match the backup.
+	KeepExisting bool
+
+	// Only work out what would change.
+	DryRun bool
+}
+
+// This recreates the backup on the collector.
+//
+// Records that already exist are matched by source and name, or server and channel, and kept.
+// Only their enabled state is changed to match the backup, unless KeepExisting is set.
+// The collector gives new records new IDs, so subscriptions are linked using the IDs it hands out.
+// Tags are not sent because the api has no way to set them, a warning lists the sources that had any.
+func Import(ctx context.Context, client api.CollectorApi, b Backup, opts ImportOptions) (ImportResult, error) {
+	res := ImportResult{
+		DryRun:    opts.DryRun,
+		Changes:   []Change{},
+		Conflicts: []string{},
+		Warnings:  []string{},
+	}
+
+	current, err := loadState(ctx, client)
+	if err != nil {
+		return res, err
+	}
+
+	// Makes the change unless this is a dry run.
+	apply := func(change Change) error {
+		if !opts.DryRun {
+			err := applyChange(ctx, client, change)
+			if err != nil {
+				return fmt.Errorf("unable to %v %v '%v': %w", change.Action, change.Kind, change.Name, err)
+			}
+		}
+		res.Changes = append(res.Changes, change)
+		return nil
+	}
+
+	// These map the IDs in the backup to the IDs on the collector.
+	// A dry run keeps the IDs from the backup for the records it would create.
+	sourceIDs := make(map[uuid.UUID]uuid.UUID)
+	sourceNames := make(map[uuid.UUID]string)
+	for _, item := range b.Sources {
+		key := sourceKey(item.Source, item.Name)
+		sourceNames[item.ID] = key
+
+		existing, ok := current.sources[key]
+		if !ok {
+			if item.Source == SourceFfxiv {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("source '%v' was skipped, the collector creates %v sources itself", key, SourceFfxiv))
+				continue
+			}
+			if len(item.Tags) > 0 {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("source '%v' had the tags %v, they need to be added on the collector", key, item.Tags))
+			}
+
+			change := Change{Action: ActionCreate, Kind: KindSource, Name: key, Detail: item.Url}
+			sourceIDs[item.ID] = item.ID
+			if !opts.DryRun {
+				enabled := item.Enabled
+				id, err := createSource(ctx, client, ManifestSource{Source: item.Source, Name: item.Name, Url: item.Url, Enabled: &enabled})
+				if err != nil {
+					return res, fmt.Errorf("unable to create source '%v': %w", key, err)
+				}
+				sourceIDs[item.ID] = id
+				change.ID, change.Detail = id.String(), ""
+			}
+			res.Changes = append(res.Changes, change)
+			continue
+		}
+
+		sourceIDs[item.ID] = existing.ID
+
+		if !sameTags(existing.Tags, item.Tags) {
+			msg := fmt.Sprintf("source '%v' has the tags %v but %v were wanted, the api can not change them", key, existing.Tags, item.Tags)
+			if opts.KeepExisting {
+				res.Conflicts = append(res.Conflicts, msg)
+			} else {
+				res.Warnings = append(res.Warnings, msg)
+			}
+		}
+
+		if opts.KeepExisting {
+			if item.Url != "" && existing.Url != item.Url {
+				res.Conflicts = append(res.Conflicts, fmt.Sprintf("source '%v' has the url '%v' but '%v' was wanted", key, existing.Url, item.Url))
+			}
+			if existing.Enabled != item.Enabled {
+				res.Conflicts = append(res.Conflicts, fmt.Sprintf("source '%v' has enabled set to %v but %v was wanted", key, existing.Enabled, item.Enabled))
+			}
+			continue
+		}
+
+		if existing.Enabled != item.Enabled {
+			err = apply(toggle(KindSource, key, existing.ID, item.Enabled))
+			if err != nil {
+				return res, err
+			}
+		}
+	}
+
+	webhookIDs := make(map[uuid.UUID]uuid.UUID)
+	webhookNames := make(map[uuid.UUID]string)
+	for _, item := range b.Webhooks {
+		key := webhookKey(item.Server, item.Channel)
+		webhookNames[item.ID] = key
+
+		existing, ok := current.webhooks[key]
+		if !ok {
+			enabled := item.Enabled
+			webhook := ManifestWebhook{Server: item.Server, Channel: item.Channel, Url: item.Url, Enabled: &enabled}
+			change := Change{Action: ActionCreate, Kind: KindWebhook, Name: key, Detail: webhook.redactedUrl()}
+			webhookIDs[item.ID] = item.ID
+			if !opts.DryRun {
+				id, err := createWebhook(ctx, client, webhook)
+				if err != nil {
+					return res, fmt.Errorf("unable to create webhook '%v': %w", key, err)
+				}
+				webhookIDs[item.ID] = id
+				change.ID = id.String()
+			}
+			res.Changes = append(res.Changes, change)
+			continue
+		}
+
+		webhookIDs[item.ID] = existing.ID
+
+		if existing.Url != item.Url {
+			msg := fmt.Sprintf("webhook '%v' already exists with a different url, it was kept", key)
+			if opts.KeepExisting {
+				res.Conflicts = append(res.Conflicts, msg)
+			} else {
+				res.Warnings = append(res.Warnings, msg)
+			}
+		}
+
+		if existing.Enabled == item.Enabled {
+			continue
+		}
+		if opts.KeepExisting {
+			res.Conflicts = append(res.Conflicts, fmt.Sprintf("webhook '%v' has enabled set to %v but %v was wanted", key, existing.Enabled, item.Enabled))
+			continue
+		}
+		err = apply(toggle(KindWebhook, key, existing.ID, item.Enabled))
+		if err != nil {
+			return res, err
+		}
+	}
+
+	for _, item := range b.Subscriptions {
+		sourceID, ok := sourceIDs[item.SourceID]
+		if !ok {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("subscription %v was skipped, its source %v was not imported", item.ID, item.SourceID))
+			continue
+		}
+		webhookID, ok := webhookIDs[item.WebhookID]
+		if !ok {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("subscription %v was skipped, its webhook %v was not imported", item.ID, item.WebhookID))
+			continue
+		}
+
+		key := subscriptionKey(webhookNames[item.WebhookID], sourceNames[item.SourceID])
+		if _, ok := current.subscriptions[key]; ok {
+			continue
+		}
+
+		if !opts.DryRun {
+			err = client.Subscriptions().New(ctx, webhookID, sourceID)
+			if err != nil {
+				return res, fmt.Errorf("unable to create subscription '%v': %w", key, err)
+			}
+		}
+		res.Changes = append(res.Changes, Change{Action: ActionCreate, Kind: KindSubscription, Name: key})
+		current.subscriptions[key] = api.Subscription{}
+	}
+
+	return res, nil
+}
+
+// Returns true when both have the same tags, in any order.
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	counts := make(map[string]int)
+	for _, tag := range a {
+		counts[tag]++
+	}
+	for _, tag := range b {
+		counts[tag]--
+		if counts[tag] < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package admin_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jtom38/newsbot/portal/admin"
+)
+
+func TestExportAndImport(t *testing.T) {
+	ctx := context.Background()
+
+	from := &fakeCollector{}
+	golang := from.addSource("reddit", "golang", "https://reddit.com/r/golang", true)
+	from.sources[0].Tags = []string{"go"}
+	streamer := from.addSource("twitch", "streamer", "", false)
+	from.addSource("ffxiv", "na", "", true)
+	news := from.addWebhook("home", "news", "https://discord.com/api/webhooks/1", true)
+	alerts := from.addWebhook("home", "alerts", "https://discord.com/api/webhooks/2", false)
+	from.subscribe(news, golang)
+	from.subscribe(alerts, streamer)
+
+	b, err := admin.Export(ctx, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{admin.FormatJson, admin.FormatYaml} {
+		var buf bytes.Buffer
+		err = b.Write(&buf, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := admin.ParseBackup(&buf)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		if len(parsed.Sources) != 3 || len(parsed.Webhooks) != 2 || len(parsed.Subscriptions) != 2 {
+			t.Fatalf("%v: the backup did not survive the round trip, %+v", format, parsed)
+		}
+		if parsed.Sources[0].Tags[0] != "go" {
+			t.Errorf("%v: expected the tags to be kept, got %v", format, parsed.Sources[0].Tags)
+		}
+	}
+
+	to := &fakeCollector{}
+	res, err := admin.Import(ctx, to, b, admin.ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Warnings) != 2 {
+		t.Errorf("expected warnings for the tags and the ffxiv source, got %v", res.Warnings)
+	}
+
+	expected := []string{
+		"create subscription home#news -> reddit/golang",
+		"create subscription home#alerts -> twitch/streamer",
+	}
+	var got []string
+	for _, c := range to.calls {
+		if strings.HasPrefix(c, "create subscription") {
+			got = append(got, c)
+		}
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected the subscriptions to be linked to the new IDs, got %v", got)
+	}
+	if to.sources[1].Enabled || to.webhooks[1].Enabled {
+		t.Errorf("expected the disabled records to stay disabled, got %+v %+v", to.sources[1], to.webhooks[1])
+	}
+
+	to.calls = nil
+	_, err = admin.Import(ctx, to, b, admin.ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(to.calls) != 0 {
+		t.Errorf("expected a second import to change nothing, got %v", to.calls)
+	}
+}
+
+func TestExportSkipsBrokenSubscriptions(t *testing.T) {
+	from := &fakeCollector{}
+	golang := from.addSource("reddit", "golang", "https://reddit.com/r/golang", true)
+	news := from.addWebhook("home", "news", "https://discord.com/api/webhooks/1", true)
+	from.subscribe(news, golang)
+
+	// These point at a web hook where the source should be, and the other way around.
+	from.subscribe(news, news)
+	from.subscribe(golang, golang)
+
+	b, err := admin.Export(context.Background(), from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Subscriptions) != 1 || b.Subscriptions[0].SourceID != golang {
+		t.Errorf("expected only the subscription with a real source and web hook, got %+v", b.Subscriptions)
+	}
+}
+
+func TestParseBackupVersion(t *testing.T) {
+	_, err := admin.ParseBackup(strings.NewReader(`{"version": 7}`))
+	if err == nil || !strings.Contains(err.Error(), admin.ErrBackupVersion) {
+		t.Errorf("expected a version error, got %v", err)
+	}
+}
+
+func TestParseBackupDuplicates(t *testing.T) {
+	cases := map[string]string{
+		"'reddit/golang' is listed more than once": `{"version": 1, "sources": [
+			{"id": "00000000-0000-0000-0000-000000000001", "source": "reddit", "name": "golang"},
+			{"id": "00000000-0000-0000-0000-000000000002", "source": "reddit", "name": "golang"}]}`,
+		"sources[1] has the ID": `{"version": 1, "sources": [
+			{"id": "00000000-0000-0000-0000-000000000001", "source": "reddit", "name": "golang"},
+			{"id": "00000000-0000-0000-0000-000000000001", "source": "reddit", "name": "rust"}]}`,
+		"'home#news' is listed more than once": `{"version": 1, "webhooks": [
+			{"id": "00000000-0000-0000-0000-000000000001", "server": "home", "channel": "news", "url": "https://discord.com/api/webhooks/1"},
+			{"id": "00000000-0000-0000-0000-000000000002", "server": "home", "channel": "news", "url": "https://discord.com/api/webhooks/2"}]}`,
+		"webhooks[1] has the ID": `{"version": 1, "webhooks": [
+			{"id": "00000000-0000-0000-0000-000000000001", "server": "home", "channel": "news", "url": "https://discord.com/api/webhooks/1"},
+			{"id": "00000000-0000-0000-0000-000000000001", "server": "home", "channel": "games", "url": "https://discord.com/api/webhooks/2"}]}`,
+		"subscriptions[1] has the ID": `{"version": 1, "subscriptions": [
+			{"id": "00000000-0000-0000-0000-000000000001"},
+			{"id": "00000000-0000-0000-0000-000000000001"}]}`,
+	}
+	for want, backup := range cases {
+		_, err := admin.ParseBackup(strings.NewReader(backup))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected '%v', got %v", want, err)
+		}
+	}
+}
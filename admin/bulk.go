@@ -0,0 +1,127 @@
+package admin
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Subreddit and Twitch channel names are letters, numbers and underscores.
+	validChannelName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+// This reads a list of sources of one type, one per line.
+//
+// A line is either just the name, or CSV with the name, url and tags, like
+//
+//	golang,https://reddit.com/r/golang,"go,news"
+//
+// A first line of name,url or name,url,tags is skipped.
+// Every row is checked, the ones with problems are returned as invalid with the reason.
+// The api can not set tags, so rows with tags are kept but warn that they need to be added on the collector.
+func ParseSourceList(source string, r io.Reader) ([]SourceEntry, error) {
+	switch source {
+	case SourceReddit, SourceYoutube, SourceTwitch:
+	default:
+		return nil, fmt.Errorf("%v sources can not be added", source)
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	entries := []SourceEntry{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				line = parseErr.Line
+			}
+			entries = append(entries, SourceEntry{Line: line, Source: source, Status: EntryInvalid, Error: err.Error()})
+			continue
+		}
+
+		if len(entries) == 0 && line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "name") {
+			continue
+		}
+
+		entries = append(entries, listEntry(source, line, record))
+	}
+
+	return entries, nil
+}
+
+func listEntry(source string, line int, record []string) SourceEntry {
+	e := SourceEntry{Line: line, Source: source}
+
+	field := func(i int) string {
+		if i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	e.Name, e.Url = field(0), field(1)
+
+	// Anything after the url is a tag, so the tags can be quoted or left as extra columns.
+	for _, column := range record[min(len(record), 2):] {
+		for _, tag := range strings.FieldsFunc(column, func(r rune) bool { return r == ',' || r == ';' }) {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				e.Tags = append(e.Tags, tag)
+			}
+		}
+	}
+	if len(e.Tags) > 0 {
+		e.Warning = "the api can not set tags, add them on the collector afterwards"
+	}
+
+	var problem string
+	switch source {
+	case SourceReddit:
+		// A pasted url or r/name is turned into the subreddit name.
+		if sub, ok := redditName(e.Name); ok {
+			e.Name = sub
+		}
+		e.Name = strings.TrimPrefix(e.Name, "r/")
+		if e.Url == "" {
+			e.Url = "https://reddit.com/r/" + e.Name
+		}
+		if !validChannelName.MatchString(e.Name) {
+			problem = "the subreddit name can only have letters, numbers and underscores"
+		}
+	case SourceYoutube:
+		if e.Url == "" && guessSource(e.Name) == SourceYoutube {
+			problem = "the first column is the channel name, the url goes in the second"
+		} else if guessSource(e.Url) != SourceYoutube {
+			problem = "a YouTube channel url is required"
+		} else if _, err := url.ParseRequestURI(e.Url); err != nil {
+			problem = err.Error()
+		}
+	case SourceTwitch:
+		if channel, ok := twitchName(e.Name); ok && guessSource(e.Name) == SourceTwitch {
+			e.Name = channel
+		}
+		if !validChannelName.MatchString(e.Name) {
+			problem = "the channel name can only have letters, numbers and underscores"
+		}
+	}
+
+	if e.Name == "" {
+		problem = "the name is missing"
+	}
+	if problem != "" {
+		e.Status, e.Error = EntryInvalid, problem
+	}
+	return e
+}
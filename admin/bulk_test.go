@@ -0,0 +1,114 @@
+package admin_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jtom38/newsbot/portal/admin"
+)
+
+func TestParseSourceList(t *testing.T) {
+	entries, err := admin.ParseSourceList(admin.SourceReddit, strings.NewReader(`name,url
+golang
+r/rust
+https://www.reddit.com/r/dadjokes/
+not a subreddit
+# comments are skipped
+news,https://reddit.com/r/news
+tagged,,"programming,rust"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		line   int
+		name   string
+		status string
+	}{
+		{2, "golang", ""},
+		{3, "rust", ""},
+		{4, "dadjokes", ""},
+		{5, "not a subreddit", admin.EntryInvalid},
+		{7, "news", ""},
+		{8, "tagged", ""},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %v rows, got %+v", len(expected), entries)
+	}
+	for i, want := range expected {
+		got := entries[i]
+		if got.Line != want.line || got.Name != want.name || got.Status != want.status {
+			t.Errorf("row %v: expected %+v, got %+v", i, want, got)
+		}
+	}
+
+	// The tags are kept, with a warning that they have to be added on the collector.
+	for _, got := range entries[:5] {
+		if len(got.Tags) != 0 || got.Warning != "" {
+			t.Errorf("expected no tags or warning for %+v", got)
+		}
+	}
+	tagged := entries[5]
+	if !reflect.DeepEqual(tagged.Tags, []string{"programming", "rust"}) || tagged.Warning == "" {
+		t.Errorf("expected the tags and a warning, got %+v", tagged)
+	}
+
+	entries, err = admin.ParseSourceList(admin.SourceTwitch, strings.NewReader("name,url,tags\nstreamer,,games,speedruns\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Status != "" || !reflect.DeepEqual(entries[0].Tags, []string{"games", "speedruns"}) {
+		t.Errorf("expected the extra columns as tags, got %+v", entries)
+	}
+
+	entries, err = admin.ParseSourceList(admin.SourceYoutube, strings.NewReader("Go\nGo,https://www.youtube.com/@golang\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].Status != admin.EntryInvalid || entries[1].Status != "" {
+		t.Errorf("expected only the row with a url to be valid, got %+v", entries)
+	}
+
+	_, err = admin.ParseSourceList(admin.SourceFfxiv, strings.NewReader("na"))
+	if err == nil {
+		t.Error("expected ffxiv to be refused")
+	}
+}
+
+func TestAddSourcesConcurrently(t *testing.T) {
+	ctx := context.Background()
+
+	var list strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&list, "sub_%v\n", i)
+	}
+	list.WriteString("sub_0\n")
+
+	entries, err := admin.ParseSourceList(admin.SourceReddit, strings.NewReader(list.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collector := &fakeCollector{}
+	entries, err = admin.CheckSources(ctx, collector, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[20].Status != admin.EntryDuplicate {
+		t.Errorf("expected the last row to be a duplicate, got %+v", entries[20])
+	}
+
+	entries = admin.AddSources(ctx, collector, entries)
+	for _, entry := range entries[:20] {
+		if entry.Status != admin.EntryCreated {
+			t.Errorf("expected %v to be created, got %+v", entry.Name, entry)
+		}
+	}
+	if len(collector.sources) != 20 {
+		t.Errorf("expected 20 sources, got %v", len(collector.sources))
+	}
+}
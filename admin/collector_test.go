@@ -0,0 +1,278 @@
+package admin_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+// This is an in memory collector so plans can be applied and checked without a server.
+type fakeCollector struct {
+	// AddSources creates sources from a few goroutines at once, so the source methods it calls hold this.
+	mu sync.Mutex
+
+	sources       []api.Source
+	webhooks      []api.DiscordWebHooks
+	subscriptions []api.Subscription
+
+	// Every call that changes something, like "create source reddit/golang".
+	calls []string
+}
+
+func (c *fakeCollector) Info(ctx context.Context) (api.CollectorInfo, error) {
+	return api.CollectorInfo{Version: "0.0.0"}, nil
+}
+
+func (c *fakeCollector) Articles() api.ArticlesApi           { return nil }
+func (c *fakeCollector) Sources() api.SourcesApi             { return fakeSources{c} }
+func (c *fakeCollector) Outputs() api.OutputsApi             { return fakeOutputs{c} }
+func (c *fakeCollector) Subscriptions() api.SubscriptionsApi { return fakeSubscriptions{c} }
+
+func (c *fakeCollector) addSource(source, name, url string, enabled bool) uuid.UUID {
+	id := uuid.New()
+	c.sources = append(c.sources, api.Source{ID: id, Source: source, Name: name, Url: url, Enabled: enabled})
+	return id
+}
+
+func (c *fakeCollector) addWebhook(server, channel, url string, enabled bool) uuid.UUID {
+	id := uuid.New()
+	c.webhooks = append(c.webhooks, api.DiscordWebHooks{ID: id, Server: server, Channel: channel, Url: url, Enabled: enabled})
+	return id
+}
+
+func (c *fakeCollector) subscribe(webhook, source uuid.UUID) {
+	c.subscriptions = append(c.subscriptions, api.Subscription{ID: uuid.New(), DiscordWebhookId: webhook, SourceId: source})
+}
+
+func (c *fakeCollector) source(id uuid.UUID) *api.Source {
+	for i := range c.sources {
+		if c.sources[i].ID == id {
+			return &c.sources[i]
+		}
+	}
+	return nil
+}
+
+func (c *fakeCollector) webhook(id uuid.UUID) *api.DiscordWebHooks {
+	for i := range c.webhooks {
+		if c.webhooks[i].ID == id {
+			return &c.webhooks[i]
+		}
+	}
+	return nil
+}
+
+var errNotFound = errors.New("not found")
+
+type fakeSources struct{ c *fakeCollector }
+
+func (f fakeSources) List(ctx context.Context) (*[]api.Source, error) {
+	items := append([]api.Source{}, f.c.sources...)
+	return &items, nil
+}
+
+func (f fakeSources) ListBySource(ctx context.Context, value string) (*[]api.Source, error) {
+	var items []api.Source
+	for _, item := range f.c.sources {
+		if item.Source == value {
+			items = append(items, item)
+		}
+	}
+	return &items, nil
+}
+
+func (f fakeSources) GetById(ctx context.Context, ID uuid.UUID) (*api.Source, error) {
+	item := f.c.source(ID)
+	if item == nil {
+		return &api.Source{}, errNotFound
+	}
+	return item, nil
+}
+
+func (f fakeSources) GetBySourceAndName(ctx context.Context, source string, name string) (*api.Source, error) {
+	f.c.mu.Lock()
+	defer f.c.mu.Unlock()
+
+	for _, item := range f.c.sources {
+		if item.Source == source && item.Name == name && !item.Deleted {
+			return &item, nil
+		}
+	}
+	return &api.Source{}, errNotFound
+}
+
+func (f fakeSources) NewReddit(ctx context.Context, name string, url string) error {
+	f.c.mu.Lock()
+	defer f.c.mu.Unlock()
+
+	f.c.calls = append(f.c.calls, fmt.Sprintf("create source reddit/%v", name))
+	f.c.addSource("reddit", name, url, true)
+	return nil
+}
+
+func (f fakeSources) NewYouTube(ctx context.Context, name string, url string) error {
+	f.c.mu.Lock()
+	defer f.c.mu.Unlock()
+
+	f.c.calls = append(f.c.calls, fmt.Sprintf("create source youtube/%v", name))
+	f.c.addSource("youtube", name, url, true)
+	return nil
+}
+
+func (f fakeSources) NewTwitch(ctx context.Context, name string) error {
+	f.c.mu.Lock()
+	defer f.c.mu.Unlock()
+
+	f.c.calls = append(f.c.calls, fmt.Sprintf("create source twitch/%v", name))
+	f.c.addSource("twitch", name, "", true)
+	return nil
+}
+
+func (f fakeSources) Enable(ctx context.Context, ID uuid.UUID) error {
+	return f.set(ID, "enable", true)
+}
+
+func (f fakeSources) Disable(ctx context.Context, ID uuid.UUID) error {
+	f.c.mu.Lock()
+	defer f.c.mu.Unlock()
+
+	return f.set(ID, "disable", false)
+}
+
+func (f fakeSources) set(ID uuid.UUID, action string, enabled bool) error {
+	item := f.c.source(ID)
+	if item == nil {
+		return errNotFound
+	}
+	f.c.calls = append(f.c.calls, fmt.Sprintf("%v source %v/%v", action, item.Source, item.Name))
+	item.Enabled = enabled
+	return nil
+}
+
+func (f fakeSources) Delete(ctx context.Context, ID uuid.UUID) error {
+	item := f.c.source(ID)
+	if item == nil {
+		return errNotFound
+	}
+	f.c.calls = append(f.c.calls, fmt.Sprintf("delete source %v/%v", item.Source, item.Name))
+	item.Deleted = true
+	return nil
+}
+
+type fakeOutputs struct{ c *fakeCollector }
+
+func (f fakeOutputs) DiscordWebHook() api.OutputDiscordWebHookApi {
+	return fakeWebhooks(f)
+}
+
+type fakeWebhooks struct{ c *fakeCollector }
+
+func (f fakeWebhooks) List(ctx context.Context) (*[]api.DiscordWebHooks, error) {
+	items := append([]api.DiscordWebHooks{}, f.c.webhooks...)
+	return &items, nil
+}
+
+func (f fakeWebhooks) Get(ctx context.Context, id uuid.UUID) (*api.DiscordWebHooks, error) {
+	item := f.c.webhook(id)
+	if item == nil {
+		return &api.DiscordWebHooks{}, errNotFound
+	}
+	return item, nil
+}
+
+func (f fakeWebhooks) GetByServerAndChannel(ctx context.Context, server string, channel string) ([]api.DiscordWebHooks, error) {
+	var items []api.DiscordWebHooks
+	for _, item := range f.c.webhooks {
+		if item.Server == server && item.Channel == channel {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (f fakeWebhooks) New(ctx context.Context, server string, channel string, url string) error {
+	f.c.calls = append(f.c.calls, fmt.Sprintf("create webhook %v#%v", server, channel))
+	f.c.addWebhook(server, channel, url, true)
+	return nil
+}
+
+func (f fakeWebhooks) Enable(ctx context.Context, id uuid.UUID) error {
+	return f.set(id, "enable", true)
+}
+
+func (f fakeWebhooks) Disable(ctx context.Context, id uuid.UUID) error {
+	return f.set(id, "disable", false)
+}
+
+func (f fakeWebhooks) set(id uuid.UUID, action string, enabled bool) error {
+	item := f.c.webhook(id)
+	if item == nil {
+		return errNotFound
+	}
+	f.c.calls = append(f.c.calls, fmt.Sprintf("%v webhook %v#%v", action, item.Server, item.Channel))
+	item.Enabled = enabled
+	return nil
+}
+
+func (f fakeWebhooks) Delete(ctx context.Context, id uuid.UUID) error {
+	for i, item := range f.c.webhooks {
+		if item.ID == id {
+			f.c.calls = append(f.c.calls, fmt.Sprintf("delete webhook %v#%v", item.Server, item.Channel))
+			f.c.webhooks = append(f.c.webhooks[:i], f.c.webhooks[i+1:]...)
+			return nil
+		}
+	}
+	return errNotFound
+}
+
+type fakeSubscriptions struct{ c *fakeCollector }
+
+func (f fakeSubscriptions) List(ctx context.Context) ([]api.Subscription, error) {
+	return append([]api.Subscription{}, f.c.subscriptions...), nil
+}
+
+func (f fakeSubscriptions) GetByDiscordID(ctx context.Context, ID uuid.UUID) (*[]api.Subscription, error) {
+	var items []api.Subscription
+	for _, item := range f.c.subscriptions {
+		if item.DiscordWebhookId == ID {
+			items = append(items, item)
+		}
+	}
+	return &items, nil
+}
+
+func (f fakeSubscriptions) GetBySourceID(ctx context.Context, ID uuid.UUID) (*[]api.Subscription, error) {
+	var items []api.Subscription
+	for _, item := range f.c.subscriptions {
+		if item.SourceId == ID {
+			items = append(items, item)
+		}
+	}
+	return &items, nil
+}
+
+func (f fakeSubscriptions) New(ctx context.Context, DiscordID uuid.UUID, SourceID uuid.UUID) error {
+	webhook, source := f.c.webhook(DiscordID), f.c.source(SourceID)
+	if webhook == nil || source == nil {
+		return errNotFound
+	}
+	f.c.calls = append(f.c.calls, fmt.Sprintf("create subscription %v#%v -> %v/%v", webhook.Server, webhook.Channel, source.Source, source.Name))
+	f.c.subscribe(DiscordID, SourceID)
+	return nil
+}
+
+func (f fakeSubscriptions) Delete(ctx context.Context, ID uuid.UUID) error {
+	for i, item := range f.c.subscriptions {
+		if item.ID == ID {
+			f.c.calls = append(f.c.calls, fmt.Sprintf("delete subscription %v", ID))
+			f.c.subscriptions = append(f.c.subscriptions[:i], f.c.subscriptions[i+1:]...)
+			return nil
+		}
+	}
+	return errNotFound
+}
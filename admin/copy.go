@@ -0,0 +1,73 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+type CopyOptions struct {
+	// Only copy sources from these sites, like reddit or youtube. All of them are copied when it is empty.
+	Sources []string
+
+	// Only work out what would be copied.
+	DryRun bool
+}
+
+// This copies the sources, Discord web hooks and subscriptions from one collector to another.
+//
+// Records are matched on the target by source and name, or server and channel.
+// The target is never changed where it already has a record,
+// any difference to the record being copied is reported as a conflict instead.
+func Copy(ctx context.Context, from api.CollectorApi, to api.CollectorApi, opts CopyOptions) (ImportResult, error) {
+	for _, source := range opts.Sources {
+		if !isKnownSource(source) {
+			return ImportResult{}, fmt.Errorf("unknown source '%v'", source)
+		}
+	}
+
+	b, err := Export(ctx, from)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("unable to read from the source collector: %w", err)
+	}
+
+	return Import(ctx, to, b.Filter(opts.Sources), ImportOptions{
+		KeepExisting: true,
+		DryRun:       opts.DryRun,
+	})
+}
+
+// Returns a copy of the backup with only the sources from these sites, and the subscriptions to them.
+// Every web hook is kept. When no sites are given the backup is returned as it is.
+func (b Backup) Filter(sources []string) Backup {
+	if len(sources) == 0 {
+		return b
+	}
+
+	wanted := make(map[string]bool)
+	for _, source := range sources {
+		wanted[source] = true
+	}
+
+	filtered := b
+	filtered.Sources = []BackupSource{}
+	filtered.Subscriptions = []BackupSubscription{}
+
+	kept := make(map[uuid.UUID]bool)
+	for _, item := range b.Sources {
+		if wanted[item.Source] {
+			filtered.Sources = append(filtered.Sources, item)
+			kept[item.ID] = true
+		}
+	}
+	for _, item := range b.Subscriptions {
+		if kept[item.SourceID] {
+			filtered.Subscriptions = append(filtered.Subscriptions, item)
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,64 @@
+package admin_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jtom38/newsbot/portal/admin"
+)
+
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+
+	staging := &fakeCollector{}
+	golang := staging.addSource("reddit", "golang", "https://reddit.com/r/golang", true)
+	rust := staging.addSource("reddit", "rust", "https://reddit.com/r/rust", true)
+	youtube := staging.addSource("youtube", "Go", "https://youtube.com/go", true)
+	news := staging.addWebhook("home", "news", "https://discord.com/api/webhooks/1", true)
+	staging.subscribe(news, golang)
+	staging.subscribe(news, rust)
+	staging.subscribe(news, youtube)
+
+	production := &fakeCollector{}
+	production.addSource("reddit", "golang", "https://reddit.com/r/golang", false)
+	production.addWebhook("home", "news", "https://discord.com/api/webhooks/1", true)
+
+	opts := admin.CopyOptions{Sources: []string{"reddit"}, DryRun: true}
+	res, err := admin.Copy(ctx, staging, production, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(production.calls) != 0 {
+		t.Errorf("expected a dry run to make no calls, got %v", production.calls)
+	}
+	if len(res.Changes) != 3 {
+		t.Errorf("expected the dry run to list 3 changes, got %v", actions(res.Changes))
+	}
+
+	opts.DryRun = false
+	res, err = admin.Copy(ctx, staging, production, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"create source reddit/rust",
+		"create subscription home#news -> reddit/golang",
+		"create subscription home#news -> reddit/rust",
+	}
+	if !reflect.DeepEqual(production.calls, expected) {
+		t.Errorf("expected %v, got %v", expected, production.calls)
+	}
+	if len(res.Conflicts) != 1 {
+		t.Errorf("expected the disabled reddit/golang to be reported, got %v", res.Conflicts)
+	}
+	if production.sources[0].Enabled {
+		t.Error("expected the existing source to be left alone")
+	}
+
+	_, err = admin.Copy(ctx, staging, production, admin.CopyOptions{Sources: []string{"rss"}})
+	if err == nil {
+		t.Error("expected an unknown source to be refused")
+	}
+}
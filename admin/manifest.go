@@ -0,0 +1,198 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ManifestVersion = 1
+
+	SourceReddit  = "reddit"
+	SourceYoutube = "youtube"
+	SourceTwitch  = "twitch"
+	SourceFfxiv   = "ffxiv"
+
+	ErrManifestVersion = "unsupported manifest version"
+)
+
+// This describes the sources, Discord web hooks and subscriptions the collector should have.
+//
+//	version: 1
+//	sources:
+//	  - source: reddit
+//	    name: golang
+//	webhooks:
+//	  - server: home
+//	    channel: news
+//	    url: https://discord.com/api/webhooks/...
+//	subscriptions:
+//	  - server: home
+//	    channel: news
+//	    source: reddit
+//	    name: golang
+type Manifest struct {
+	Version       int                    `yaml:"version" json:"version"`
+	Sources       []ManifestSource       `yaml:"sources,omitempty" json:"sources"`
+	Webhooks      []ManifestWebhook      `yaml:"webhooks,omitempty" json:"webhooks"`
+	Subscriptions []ManifestSubscription `yaml:"subscriptions,omitempty" json:"subscriptions"`
+}
+
+// A source is matched on the collector by its source and name.
+// Enabled defaults to true when it is left out.
+type ManifestSource struct {
+	Source  string `yaml:"source" json:"source"`
+	Name    string `yaml:"name" json:"name"`
+	Url     string `yaml:"url,omitempty" json:"url,omitempty"`
+	Enabled *bool  `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+}
+
+// A Discord web hook is matched on the collector by its server and channel.
+// Enabled defaults to true when it is left out.
+type ManifestWebhook struct {
+	Server  string `yaml:"server" json:"server"`
+	Channel string `yaml:"channel" json:"channel"`
+	Url     string `yaml:"url" json:"url"`
+	Enabled *bool  `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+}
+
+// This sends the articles from the source named by Source and Name to the web hook in Server and Channel.
+type ManifestSubscription struct {
+	Server  string `yaml:"server" json:"server"`
+	Channel string `yaml:"channel" json:"channel"`
+	Source  string `yaml:"source" json:"source"`
+	Name    string `yaml:"name" json:"name"`
+}
+
+func (s ManifestSource) key() string {
+	return sourceKey(s.Source, s.Name)
+}
+
+func (s ManifestSource) enabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+// Returns the url the collector is given when the source is created.
+// Reddit sources can leave it out because it can be built from the name.
+func (s ManifestSource) url() string {
+	if s.Url == "" && s.Source == SourceReddit {
+		return fmt.Sprintf("https://reddit.com/r/%v", s.Name)
+	}
+	return s.Url
+}
+
+func (w ManifestWebhook) key() string {
+	return webhookKey(w.Server, w.Channel)
+}
+
+func (w ManifestWebhook) enabled() bool {
+	return w.Enabled == nil || *w.Enabled
+}
+
+// Returns the url with the token cut off, written the way the manifest examples show it.
+// The token is the last part of the path and anyone who has it can post to the channel.
+func (w ManifestWebhook) redactedUrl() string {
+	i := strings.LastIndex(w.Url, "/")
+	if i < 0 || i == len(w.Url)-1 {
+		return w.Url
+	}
+	return w.Url[:i+1] + "..."
+}
+
+func (s ManifestSubscription) key() string {
+	return subscriptionKey(webhookKey(s.Server, s.Channel), sourceKey(s.Source, s.Name))
+}
+
+func sourceKey(source, name string) string {
+	return source + "/" + name
+}
+
+func webhookKey(server, channel string) string {
+	return server + "#" + channel
+}
+
+func subscriptionKey(webhook, source string) string {
+	return webhook + " -> " + source
+}
+
+// This reads a manifest from YAML, JSON works too because it is valid YAML.
+// Unknown fields are rejected so typos do not go unnoticed.
+func ParseManifest(r io.Reader) (Manifest, error) {
+	var m Manifest
+
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	err := dec.Decode(&m)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return m, fmt.Errorf("unable to parse the manifest: %w", err)
+	}
+
+	return m, errors.Join(m.Validate()...)
+}
+
+// Returns every problem with the manifest so they can all be fixed at once.
+func (m Manifest) Validate() []error {
+	var errs []error
+
+	if m.Version != 0 && m.Version != ManifestVersion {
+		errs = append(errs, fmt.Errorf("%v %v, expected %v", ErrManifestVersion, m.Version, ManifestVersion))
+	}
+
+	sources := make(map[string]bool)
+	for i, s := range m.Sources {
+		switch {
+		case s.Source == "" || s.Name == "":
+			errs = append(errs, fmt.Errorf("sources[%v] needs a source and a name", i))
+			continue
+		case !isKnownSource(s.Source):
+			errs = append(errs, fmt.Errorf("sources[%v] has an unknown source '%v'", i, s.Source))
+		case s.Source == SourceYoutube && s.Url == "":
+			errs = append(errs, fmt.Errorf("sources[%v] '%v' needs a url", i, s.key()))
+		}
+
+		if sources[s.key()] {
+			errs = append(errs, fmt.Errorf("sources[%v] '%v' is listed more than once", i, s.key()))
+		}
+		sources[s.key()] = true
+	}
+
+	webhooks := make(map[string]bool)
+	for i, w := range m.Webhooks {
+		if w.Server == "" || w.Channel == "" || w.Url == "" {
+			errs = append(errs, fmt.Errorf("webhooks[%v] needs a server, channel and url", i))
+			continue
+		}
+
+		if webhooks[w.key()] {
+			errs = append(errs, fmt.Errorf("webhooks[%v] '%v' is listed more than once", i, w.key()))
+		}
+		webhooks[w.key()] = true
+	}
+
+	subscriptions := make(map[string]bool)
+	for i, s := range m.Subscriptions {
+		if s.Server == "" || s.Channel == "" || s.Source == "" || s.Name == "" {
+			errs = append(errs, fmt.Errorf("subscriptions[%v] needs a server, channel, source and name", i))
+			continue
+		}
+
+		if subscriptions[s.key()] {
+			errs = append(errs, fmt.Errorf("subscriptions[%v] '%v' is listed more than once", i, s.key()))
+		}
+		subscriptions[s.key()] = true
+	}
+
+	return errs
+}
+
+func isKnownSource(name string) bool {
+	switch name {
+	case SourceReddit, SourceYoutube, SourceTwitch, SourceFfxiv:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,322 @@
+package admin
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+const (
+	OPMLContentType = "text/x-opml"
+)
+
+type opml struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    opmlHead `xml:"head"`
+	Body    opmlBody `xml:"body"`
+}
+
+type opmlHead struct {
+	Title       string `xml:"title"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type opmlBody struct {
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+type opmlOutline struct {
+	Text     string `xml:"text,attr"`
+	Title    string `xml:"title,attr,omitempty"`
+	Type     string `xml:"type,attr,omitempty"`
+	XmlUrl   string `xml:"xmlUrl,attr,omitempty"`
+	HtmlUrl  string `xml:"htmlUrl,attr,omitempty"`
+	Category string `xml:"category,attr,omitempty"`
+	// Exported outlines carry the source type so an import does not have to guess it from the url.
+	Source   string        `xml:"newsbotSource,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// This writes the sources as OPML 2.0.
+//
+// There is a folder for each source type, and in it a folder for each tag.
+// A source with more than one tag shows up in each of them, and one without tags sits in the source type folder.
+// Deleted and ffxiv sources are left out because there is nothing a feed reader can do with them.
+func WriteOPML(w io.Writer, sources []api.Source) error {
+	doc := opml{
+		Version: "2.0",
+		Head: opmlHead{
+			Title:       "newsbot sources",
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, site := range []string{SourceReddit, SourceYoutube, SourceTwitch} {
+		group := opmlOutline{Text: site, Title: site}
+		tags := make(map[string]*opmlOutline)
+		var tagNames []string
+
+		for _, item := range sources {
+			if item.Source != site || item.Deleted {
+				continue
+			}
+
+			outline := sourceOutline(item)
+			if len(item.Tags) == 0 {
+				group.Outlines = append(group.Outlines, outline)
+				continue
+			}
+
+			for _, tag := range item.Tags {
+				folder, ok := tags[tag]
+				if !ok {
+					folder = &opmlOutline{Text: tag, Title: tag}
+					tags[tag] = folder
+					tagNames = append(tagNames, tag)
+				}
+				folder.Outlines = append(folder.Outlines, outline)
+			}
+		}
+
+		sort.Strings(tagNames)
+		for _, tag := range tagNames {
+			group.Outlines = append(group.Outlines, *tags[tag])
+		}
+
+		if len(group.Outlines) > 0 {
+			doc.Body.Outlines = append(doc.Body.Outlines, group)
+		}
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(doc)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+func sourceOutline(item api.Source) opmlOutline {
+	o := opmlOutline{
+		Text:     item.Name,
+		Title:    item.Name,
+		HtmlUrl:  item.Url,
+		Category: strings.Join(item.Tags, ","),
+		Source:   item.Source,
+	}
+
+	switch item.Source {
+	case SourceReddit:
+		o.HtmlUrl = (ManifestSource{Source: SourceReddit, Name: item.Name, Url: item.Url}).url()
+		o.XmlUrl = fmt.Sprintf("https://www.reddit.com/r/%v/.rss", item.Name)
+	case SourceYoutube:
+		if id, ok := youtubeChannelID(item.Url); ok {
+			o.XmlUrl = "https://www.youtube.com/feeds/videos.xml?channel_id=" + id
+		}
+	case SourceTwitch:
+		if o.HtmlUrl == "" {
+			o.HtmlUrl = "https://www.twitch.tv/" + item.Name
+		}
+	}
+
+	if o.XmlUrl != "" {
+		o.Type = "rss"
+	}
+	return o
+}
+
+// Returns the channel ID from urls like https://www.youtube.com/channel/UC...
+func youtubeChannelID(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+
+	if id := u.Query().Get("channel_id"); id != "" {
+		return id, true
+	}
+
+	id, ok := strings.CutPrefix(u.Path, "/channel/")
+	id = strings.Trim(id, "/")
+	return id, ok && id != ""
+}
+
+// This reads the feeds out of an OPML file.
+//
+// Outlines exported by the portal keep their source type, others are matched on their urls.
+// Reddit subreddits, YouTube channels and Twitch channels are kept, anything else is returned as invalid.
+// The folders an outline is in, other than the source type folders, become its tags.
+func ParseOPML(r io.Reader) ([]SourceEntry, error) {
+	var doc opml
+	err := xml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the OPML: %w", err)
+	}
+
+	entries := []SourceEntry{}
+	var walk func(outlines []opmlOutline, folders []string)
+	walk = func(outlines []opmlOutline, folders []string) {
+		for _, o := range outlines {
+			if o.XmlUrl == "" && o.HtmlUrl == "" && o.Source == "" {
+				folder := o.Text
+				if folder == "" {
+					folder = o.Title
+				}
+				walk(o.Outlines, append(folders, folder))
+				continue
+			}
+			entries = append(entries, outlineEntry(o, folders))
+		}
+	}
+	walk(doc.Body.Outlines, nil)
+
+	return mergeEntries(entries), nil
+}
+
+// A source that is in more than one folder is listed once with the tags from every folder.
+// Invalid entries are kept so they can be reported, but the tags only go to the first valid one.
+func mergeEntries(entries []SourceEntry) []SourceEntry {
+	merged := []SourceEntry{}
+	index := make(map[string]int)
+	for _, entry := range entries {
+		if entry.Status == EntryInvalid {
+			merged = append(merged, entry)
+			continue
+		}
+
+		key := sourceKey(entry.Source, entry.Name)
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, entry)
+			continue
+		}
+
+		for _, tag := range entry.Tags {
+			if !containsTag(merged[i].Tags, tag) {
+				merged[i].Tags = append(merged[i].Tags, tag)
+			}
+		}
+	}
+	return merged
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func outlineEntry(o opmlOutline, folders []string) SourceEntry {
+	name := o.Text
+	if name == "" {
+		name = o.Title
+	}
+
+	e := SourceEntry{Source: o.Source, Name: name}
+	for _, folder := range folders {
+		if !isKnownSource(folder) {
+			e.Tags = append(e.Tags, folder)
+		}
+	}
+	if len(e.Tags) == 0 && o.Category != "" {
+		e.Tags = strings.Split(o.Category, ",")
+	}
+
+	if e.Source == "" {
+		e.Source = guessSource(o.HtmlUrl, o.XmlUrl)
+	}
+
+	switch e.Source {
+	case SourceReddit:
+		if sub, ok := redditName(o.HtmlUrl, o.XmlUrl); ok {
+			e.Name = sub
+		}
+		e.Url = "https://reddit.com/r/" + e.Name
+	case SourceYoutube:
+		e.Url = o.HtmlUrl
+		if id, ok := youtubeChannelID(o.XmlUrl); ok && e.Url == "" {
+			e.Url = "https://www.youtube.com/channel/" + id
+		}
+		if e.Url == "" {
+			e.Status, e.Error = EntryInvalid, "the YouTube channel url is missing"
+		}
+	case SourceTwitch:
+		if channel, ok := twitchName(o.HtmlUrl); ok {
+			e.Name = channel
+		}
+	default:
+		e.Url = o.XmlUrl
+		e.Status, e.Error = EntryInvalid, "only Reddit, YouTube and Twitch feeds can be added"
+	}
+
+	if e.Name == "" && e.Status == "" {
+		e.Status, e.Error = EntryInvalid, "the name is missing"
+	}
+	return e
+}
+
+// Works out the source type from the outline urls, or returns an empty string when it is not one the collector knows.
+func guessSource(urls ...string) string {
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+
+		host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+		switch host {
+		case "reddit.com", "old.reddit.com":
+			return SourceReddit
+		case "youtube.com", "m.youtube.com":
+			return SourceYoutube
+		case "twitch.tv":
+			return SourceTwitch
+		}
+	}
+	return ""
+}
+
+// Returns the subreddit from urls like https://www.reddit.com/r/golang/.rss
+func redditName(urls ...string) (string, bool) {
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) >= 2 && parts[0] == "r" && parts[1] != "" {
+			return parts[1], true
+		}
+	}
+	return "", false
+}
+
+// Returns the channel from urls like https://www.twitch.tv/name
+func twitchName(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+
+	name := strings.Split(strings.Trim(u.Path, "/"), "/")[0]
+	return name, name != ""
+}
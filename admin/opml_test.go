@@ -0,0 +1,124 @@
+package admin_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jtom38/newsbot/portal/admin"
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+func TestOPMLRoundTrip(t *testing.T) {
+	sources := []api.Source{
+		{Source: "reddit", Name: "golang", Url: "https://reddit.com/r/golang", Tags: []string{"go", "programming"}},
+		{Source: "youtube", Name: "Go", Url: "https://www.youtube.com/channel/UC123"},
+		{Source: "twitch", Name: "streamer"},
+		{Source: "ffxiv", Name: "na"},
+	}
+
+	var buf bytes.Buffer
+	err := admin.WriteOPML(&buf, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `xmlUrl="https://www.youtube.com/feeds/videos.xml?channel_id=UC123"`) {
+		t.Errorf("expected a feed url for the YouTube channel, got %v", buf.String())
+	}
+
+	entries, err := admin.ParseOPML(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// golang is in both the go and programming folders but only comes back once.
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 sources, got %+v", entries)
+	}
+	if entries[0].Source != "reddit" || entries[0].Name != "golang" || !reflect.DeepEqual(entries[0].Tags, []string{"go", "programming"}) {
+		t.Errorf("unexpected reddit entry, %+v", entries[0])
+	}
+	if entries[1].Url != "https://www.youtube.com/channel/UC123" {
+		t.Errorf("unexpected youtube entry, %+v", entries[1])
+	}
+	if entries[2].Name != "streamer" {
+		t.Errorf("unexpected twitch entry, %+v", entries[2])
+	}
+}
+
+func TestParseOPMLFromOtherReaders(t *testing.T) {
+	entries, err := admin.ParseOPML(strings.NewReader(`<?xml version="1.0"?>
+<opml version="1.0">
+  <head><title>Feeds</title></head>
+  <body>
+    <outline text="Tech">
+      <outline text="r/golang" type="rss" xmlUrl="https://www.reddit.com/r/golang/.rss"/>
+      <outline text="Go Channel" type="rss" xmlUrl="https://www.youtube.com/feeds/videos.xml?channel_id=UC123"/>
+      <outline text="A Blog" type="rss" xmlUrl="https://blog.example.com/feed"/>
+    </outline>
+  </body>
+</opml>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", entries)
+	}
+	if entries[0].Name != "golang" || entries[0].Tags[0] != "Tech" {
+		t.Errorf("unexpected reddit entry, %+v", entries[0])
+	}
+	if entries[1].Source != "youtube" || entries[1].Url != "https://www.youtube.com/channel/UC123" {
+		t.Errorf("unexpected youtube entry, %+v", entries[1])
+	}
+	if entries[2].Status != admin.EntryInvalid {
+		t.Errorf("expected the blog to be invalid, got %+v", entries[2])
+	}
+
+	collector := &fakeCollector{}
+	collector.addSource("reddit", "golang", "https://reddit.com/r/golang", true)
+
+	entries, err = admin.CheckSources(context.Background(), collector, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].Status != admin.EntryExists || entries[1].Status != admin.EntryNew {
+		t.Errorf("unexpected statuses, %+v", entries)
+	}
+
+	entries = admin.AddSources(context.Background(), collector, entries)
+	if entries[1].Status != admin.EntryCreated || len(collector.calls) != 1 {
+		t.Errorf("expected only the YouTube channel to be created, got %+v %v", entries, collector.calls)
+	}
+}
+
+func TestParseOPMLKeepsFirstValidEntry(t *testing.T) {
+	entries, err := admin.ParseOPML(strings.NewReader(`<?xml version="1.0"?>
+<opml version="2.0">
+  <body>
+    <outline text="Tech">
+      <outline text="Go" newsbotSource="youtube" htmlUrl="https://www.youtube.com/channel/UC123"/>
+    </outline>
+    <outline text="Broken">
+      <outline text="Go" newsbotSource="youtube"/>
+    </outline>
+    <outline text="Videos">
+      <outline text="Go" newsbotSource="youtube" htmlUrl="https://www.youtube.com/channel/UC123"/>
+    </outline>
+  </body>
+</opml>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected the channel and the broken copy, got %+v", entries)
+	}
+	if entries[0].Status == admin.EntryInvalid || !reflect.DeepEqual(entries[0].Tags, []string{"Tech", "Videos"}) {
+		t.Errorf("expected the tags to go to the first valid entry, got %+v", entries[0])
+	}
+	if entries[1].Status != admin.EntryInvalid {
+		t.Errorf("expected the broken copy to be reported, got %+v", entries[1])
+	}
+}
@@ -0,0 +1,391 @@
+package admin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionEnable  = "enable"
+	ActionDisable = "disable"
+	ActionDelete  = "delete"
+
+	KindSource       = "source"
+	KindWebhook      = "webhook"
+	KindSubscription = "subscription"
+
+	ErrPlanConflicts = "the plan has conflicts that need to be fixed first"
+)
+
+// A single call the plan makes against the collector.
+type Change struct {
+	Action string `json:"action"`
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	ID     string `json:"id,omitempty"`
+	Detail string `json:"detail,omitempty"`
+
+	id           uuid.UUID
+	source       ManifestSource
+	webhook      ManifestWebhook
+	subscription ManifestSubscription
+}
+
+// This is the list of changes that makes the collector match the manifest.
+// Conflicts are differences the api can not fix, the plan will not be applied while there are any.
+type Plan struct {
+	Changes   []Change `json:"changes"`
+	Conflicts []string `json:"conflicts"`
+}
+
+// Returns true when the collector already matches the manifest.
+func (p Plan) Empty() bool {
+	return len(p.Changes) == 0 && len(p.Conflicts) == 0
+}
+
+// Returns a hash of the changes and conflicts.
+// Two plans with the same hash make the same calls, so it can tell if a reviewed plan is still the one that would run.
+// The detail only shows a redacted web hook url, so the full urls are hashed as well.
+func (p Plan) Hash() string {
+	body, _ := json.Marshal(p)
+	hash := sha256.New()
+	hash.Write(body)
+	for _, c := range p.Changes {
+		if c.Kind == KindWebhook && c.Action == ActionCreate {
+			hash.Write([]byte(c.webhook.Url))
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+type PlanOptions struct {
+	// Delete the sources, web hooks and subscriptions that are not in the manifest.
+	// Without it they are left alone.
+	Prune bool
+}
+
+// This is what the collector has, keyed the same way as the manifest.
+type state struct {
+	sources       map[string]api.Source
+	webhooks      map[string]api.DiscordWebHooks
+	subscriptions map[string]api.Subscription
+
+	// These keep the order the collector returned so plans are stable.
+	sourceKeys       []string
+	webhookKeys      []string
+	subscriptionKeys []string
+}
+
+// Loads the sources, web hooks and subscriptions from the collector.
+// Deleted sources are skipped, and subscriptions are keyed by the names of what they link.
+func loadState(ctx context.Context, client api.CollectorApi) (state, error) {
+	s := state{
+		sources:       make(map[string]api.Source),
+		webhooks:      make(map[string]api.DiscordWebHooks),
+		subscriptions: make(map[string]api.Subscription),
+	}
+
+	sources, err := client.Sources().List(ctx)
+	if err != nil {
+		return s, fmt.Errorf("unable to list the sources: %w", err)
+	}
+	sourceNames := make(map[uuid.UUID]string)
+	if sources != nil {
+		for _, item := range *sources {
+			key := sourceKey(item.Source, item.Name)
+			if item.Deleted {
+				continue
+			}
+			if _, ok := s.sources[key]; ok {
+				continue
+			}
+			s.sources[key] = item
+			s.sourceKeys = append(s.sourceKeys, key)
+			sourceNames[item.ID] = key
+		}
+	}
+
+	webhooks, err := client.Outputs().DiscordWebHook().List(ctx)
+	if err != nil {
+		return s, fmt.Errorf("unable to list the Discord web hooks: %w", err)
+	}
+	webhookNames := make(map[uuid.UUID]string)
+	if webhooks != nil {
+		for _, item := range *webhooks {
+			key := webhookKey(item.Server, item.Channel)
+			if _, ok := s.webhooks[key]; ok {
+				continue
+			}
+			s.webhooks[key] = item
+			s.webhookKeys = append(s.webhookKeys, key)
+			webhookNames[item.ID] = key
+		}
+	}
+
+	subscriptions, err := client.Subscriptions().List(ctx)
+	if err != nil {
+		return s, fmt.Errorf("unable to list the subscriptions: %w", err)
+	}
+	for _, item := range subscriptions {
+		webhook, ok := webhookNames[item.DiscordWebhookId]
+		if !ok {
+			continue
+		}
+		source, ok := sourceNames[item.SourceId]
+		if !ok {
+			continue
+		}
+		key := subscriptionKey(webhook, source)
+		if _, ok := s.subscriptions[key]; ok {
+			continue
+		}
+		s.subscriptions[key] = item
+		s.subscriptionKeys = append(s.subscriptionKeys, key)
+	}
+
+	return s, nil
+}
+
+// This compares the manifest with the collector and returns what needs to change.
+//
+// The changes are ordered so they can be applied one after the other.
+// Sources and web hooks come first so new subscriptions can find them,
+// and anything being pruned goes last once nothing subscribes to it.
+func NewPlan(ctx context.Context, client api.CollectorApi, m Manifest, opts PlanOptions) (Plan, error) {
+	p := Plan{
+		Changes:   []Change{},
+		Conflicts: []string{},
+	}
+
+	errs := m.Validate()
+	if len(errs) > 0 {
+		return p, errors.Join(errs...)
+	}
+
+	current, err := loadState(ctx, client)
+	if err != nil {
+		return p, err
+	}
+
+	wantedSources := make(map[string]bool)
+	for _, item := range m.Sources {
+		wantedSources[item.key()] = true
+
+		existing, ok := current.sources[item.key()]
+		switch {
+		case !ok && item.Source == SourceFfxiv:
+			p.Conflicts = append(p.Conflicts, fmt.Sprintf("source '%v' can not be created, the collector manages %v sources itself", item.key(), SourceFfxiv))
+		case !ok:
+			p.Changes = append(p.Changes, Change{Action: ActionCreate, Kind: KindSource, Name: item.key(), Detail: item.url(), source: item})
+		case item.Url != "" && existing.Url != item.Url:
+			p.Conflicts = append(p.Conflicts, fmt.Sprintf("source '%v' has the url '%v' but the manifest wants '%v', the api can not change it", item.key(), existing.Url, item.Url))
+		case existing.Enabled != item.enabled():
+			p.Changes = append(p.Changes, toggle(KindSource, item.key(), existing.ID, item.enabled()))
+		}
+	}
+
+	wantedWebhooks := make(map[string]bool)
+	for _, item := range m.Webhooks {
+		wantedWebhooks[item.key()] = true
+
+		existing, ok := current.webhooks[item.key()]
+		switch {
+		case !ok:
+			p.Changes = append(p.Changes, Change{Action: ActionCreate, Kind: KindWebhook, Name: item.key(), Detail: item.redactedUrl(), webhook: item})
+		case existing.Url != item.Url:
+			p.Conflicts = append(p.Conflicts, fmt.Sprintf("webhook '%v' has a different url, the api can not change it so delete the web hook first", item.key()))
+		case existing.Enabled != item.enabled():
+			p.Changes = append(p.Changes, toggle(KindWebhook, item.key(), existing.ID, item.enabled()))
+		}
+	}
+
+	wantedSubscriptions := make(map[string]bool)
+	var creates []Change
+	for _, item := range m.Subscriptions {
+		wantedSubscriptions[item.key()] = true
+
+		source := sourceKey(item.Source, item.Name)
+		if !wantedSources[source] && (opts.Prune || current.sources[source].ID == uuid.Nil) {
+			p.Conflicts = append(p.Conflicts, fmt.Sprintf("subscription '%v' needs the source '%v' which is not in the manifest", item.key(), source))
+			continue
+		}
+		webhook := webhookKey(item.Server, item.Channel)
+		if !wantedWebhooks[webhook] && (opts.Prune || current.webhooks[webhook].ID == uuid.Nil) {
+			p.Conflicts = append(p.Conflicts, fmt.Sprintf("subscription '%v' needs the webhook '%v' which is not in the manifest", item.key(), webhook))
+			continue
+		}
+
+		if _, ok := current.subscriptions[item.key()]; !ok {
+			creates = append(creates, Change{Action: ActionCreate, Kind: KindSubscription, Name: item.key(), subscription: item})
+		}
+	}
+
+	if opts.Prune {
+		for _, key := range current.subscriptionKeys {
+			if !wantedSubscriptions[key] {
+				p.Changes = append(p.Changes, remove(KindSubscription, key, current.subscriptions[key].ID))
+			}
+		}
+	}
+
+	p.Changes = append(p.Changes, creates...)
+
+	if opts.Prune {
+		for _, key := range current.sourceKeys {
+			// The collector manages these itself and a manifest can not create them, so they are never pruned.
+			if !wantedSources[key] && current.sources[key].Source != SourceFfxiv {
+				p.Changes = append(p.Changes, remove(KindSource, key, current.sources[key].ID))
+			}
+		}
+		for _, key := range current.webhookKeys {
+			if !wantedWebhooks[key] {
+				p.Changes = append(p.Changes, remove(KindWebhook, key, current.webhooks[key].ID))
+			}
+		}
+	}
+
+	return p, nil
+}
+
+func toggle(kind, name string, id uuid.UUID, enabled bool) Change {
+	action := ActionDisable
+	if enabled {
+		action = ActionEnable
+	}
+	return Change{Action: action, Kind: kind, Name: name, ID: id.String(), id: id}
+}
+
+func remove(kind, name string, id uuid.UUID) Change {
+	return Change{Action: ActionDelete, Kind: kind, Name: name, ID: id.String(), id: id}
+}
+
+// This makes the changes in order and stops at the first one that fails.
+// Returns the changes that were made so the caller can report how far it got.
+func (p Plan) Apply(ctx context.Context, client api.CollectorApi) ([]Change, error) {
+	if len(p.Conflicts) > 0 {
+		return nil, errors.New(ErrPlanConflicts)
+	}
+
+	applied := []Change{}
+	for _, change := range p.Changes {
+		err := applyChange(ctx, client, change)
+		if err != nil {
+			return applied, fmt.Errorf("unable to %v %v '%v': %w", change.Action, change.Kind, change.Name, err)
+		}
+		applied = append(applied, change)
+	}
+
+	return applied, nil
+}
+
+func applyChange(ctx context.Context, client api.CollectorApi, c Change) error {
+	sources := client.Sources()
+	webhooks := client.Outputs().DiscordWebHook()
+
+	switch c.Kind + "/" + c.Action {
+	case KindSource + "/" + ActionCreate:
+		_, err := createSource(ctx, client, c.source)
+		return err
+	case KindSource + "/" + ActionEnable:
+		return sources.Enable(ctx, c.id)
+	case KindSource + "/" + ActionDisable:
+		return sources.Disable(ctx, c.id)
+	case KindSource + "/" + ActionDelete:
+		return sources.Delete(ctx, c.id)
+
+	case KindWebhook + "/" + ActionCreate:
+		_, err := createWebhook(ctx, client, c.webhook)
+		return err
+	case KindWebhook + "/" + ActionEnable:
+		return webhooks.Enable(ctx, c.id)
+	case KindWebhook + "/" + ActionDisable:
+		return webhooks.Disable(ctx, c.id)
+	case KindWebhook + "/" + ActionDelete:
+		return webhooks.Delete(ctx, c.id)
+
+	case KindSubscription + "/" + ActionCreate:
+		return createSubscription(ctx, client, c.subscription)
+	case KindSubscription + "/" + ActionDelete:
+		return client.Subscriptions().Delete(ctx, c.id)
+	}
+
+	return fmt.Errorf("unknown change %v %v", c.Action, c.Kind)
+}
+
+// The api does not say what ID a new record got, so it is looked up afterwards.
+// That ID is also needed to disable the source when it should start out disabled.
+func createSource(ctx context.Context, client api.CollectorApi, s ManifestSource) (uuid.UUID, error) {
+	var err error
+	switch s.Source {
+	case SourceReddit:
+		err = client.Sources().NewReddit(ctx, s.Name, s.url())
+	case SourceYoutube:
+		err = client.Sources().NewYouTube(ctx, s.Name, s.url())
+	case SourceTwitch:
+		err = client.Sources().NewTwitch(ctx, s.Name)
+	default:
+		err = fmt.Errorf("%v sources can not be created", s.Source)
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	created, err := client.Sources().GetBySourceAndName(ctx, s.Source, s.Name)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("created but unable to find it: %w", err)
+	}
+	if s.enabled() {
+		return created.ID, nil
+	}
+	return created.ID, client.Sources().Disable(ctx, created.ID)
+}
+
+func createWebhook(ctx context.Context, client api.CollectorApi, w ManifestWebhook) (uuid.UUID, error) {
+	err := client.Outputs().DiscordWebHook().New(ctx, w.Server, w.Channel, w.Url)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	created, err := findWebhook(ctx, client, w.Server, w.Channel)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("created but unable to find it: %w", err)
+	}
+	if w.enabled() {
+		return created.ID, nil
+	}
+	return created.ID, client.Outputs().DiscordWebHook().Disable(ctx, created.ID)
+}
+
+func createSubscription(ctx context.Context, client api.CollectorApi, s ManifestSubscription) error {
+	source, err := client.Sources().GetBySourceAndName(ctx, s.Source, s.Name)
+	if err != nil {
+		return fmt.Errorf("unable to find the source: %w", err)
+	}
+
+	webhook, err := findWebhook(ctx, client, s.Server, s.Channel)
+	if err != nil {
+		return fmt.Errorf("unable to find the webhook: %w", err)
+	}
+
+	return client.Subscriptions().New(ctx, webhook.ID, source.ID)
+}
+
+func findWebhook(ctx context.Context, client api.CollectorApi, server, channel string) (api.DiscordWebHooks, error) {
+	items, err := client.Outputs().DiscordWebHook().GetByServerAndChannel(ctx, server, channel)
+	if err != nil {
+		return api.DiscordWebHooks{}, err
+	}
+	if len(items) == 0 {
+		return api.DiscordWebHooks{}, fmt.Errorf("no webhook for %v", webhookKey(server, channel))
+	}
+	return items[0], nil
+}
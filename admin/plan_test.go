@@ -0,0 +1,233 @@
+package admin_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jtom38/newsbot/portal/admin"
+)
+
+const manifest = `
+version: 1
+sources:
+  - source: reddit
+    name: golang
+  - source: twitch
+    name: streamer
+    enabled: false
+webhooks:
+  - server: home
+    channel: news
+    url: https://discord.com/api/webhooks/1
+subscriptions:
+  - server: home
+    channel: news
+    source: reddit
+    name: golang
+`
+
+func actions(changes []admin.Change) []string {
+	var out []string
+	for _, c := range changes {
+		out = append(out, c.Action+" "+c.Kind+" "+c.Name)
+	}
+	return out
+}
+
+func TestPlanAndApply(t *testing.T) {
+	ctx := context.Background()
+	m, err := admin.ParseManifest(strings.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collector := &fakeCollector{}
+	keep := collector.addSource("reddit", "golang", "https://reddit.com/r/golang", false)
+	old := collector.addSource("youtube", "old", "https://youtube.com/old", true)
+	ffxiv := collector.addSource("ffxiv", "na", "", true)
+	webhook := collector.addWebhook("home", "news", "https://discord.com/api/webhooks/1", true)
+	collector.subscribe(webhook, old)
+
+	plan, err := admin.NewPlan(ctx, collector, m, admin.PlanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"enable source reddit/golang",
+		"create source twitch/streamer",
+		"create subscription home#news -> reddit/golang",
+	}
+	if !reflect.DeepEqual(actions(plan.Changes), expected) {
+		t.Errorf("expected %v, got %v", expected, actions(plan.Changes))
+	}
+
+	plan, err = admin.NewPlan(ctx, collector, m, admin.PlanOptions{Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{
+		"enable source reddit/golang",
+		"create source twitch/streamer",
+		"delete subscription home#news -> youtube/old",
+		"create subscription home#news -> reddit/golang",
+		"delete source youtube/old",
+	}
+	if !reflect.DeepEqual(actions(plan.Changes), expected) {
+		t.Errorf("expected %v, got %v", expected, actions(plan.Changes))
+	}
+
+	applied, err := plan.Apply(ctx, collector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(plan.Changes) {
+		t.Errorf("expected every change to be applied, got %v", actions(applied))
+	}
+	if !collector.source(keep).Enabled {
+		t.Error("expected reddit/golang to be enabled")
+	}
+	if !collector.source(old).Deleted {
+		t.Error("expected youtube/old to be deleted")
+	}
+	if collector.source(ffxiv).Deleted {
+		t.Error("expected the ffxiv source the collector manages to be left alone")
+	}
+
+	twitch := collector.sources[len(collector.sources)-1]
+	if twitch.Name != "streamer" || twitch.Enabled {
+		t.Errorf("expected twitch/streamer to be created disabled, got %+v", twitch)
+	}
+
+	plan, err = admin.NewPlan(ctx, collector, m, admin.PlanOptions{Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Empty() {
+		t.Errorf("expected the collector to match the manifest, got %v %v", actions(plan.Changes), plan.Conflicts)
+	}
+}
+
+func TestPlanConflicts(t *testing.T) {
+	ctx := context.Background()
+	m, err := admin.ParseManifest(strings.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collector := &fakeCollector{}
+	collector.addWebhook("home", "news", "https://discord.com/api/webhooks/2", true)
+
+	plan, err := admin.NewPlan(ctx, collector, m, admin.PlanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Conflicts) != 1 || !strings.Contains(plan.Conflicts[0], "home#news") {
+		t.Fatalf("expected a conflict for the web hook url, got %v", plan.Conflicts)
+	}
+
+	_, err = plan.Apply(ctx, collector)
+	if err == nil || err.Error() != admin.ErrPlanConflicts {
+		t.Errorf("expected the plan to be refused, got %v", err)
+	}
+	if len(collector.calls) != 0 {
+		t.Errorf("expected no calls, got %v", collector.calls)
+	}
+}
+
+func TestPlanHash(t *testing.T) {
+	ctx := context.Background()
+	m, err := admin.ParseManifest(strings.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collector := &fakeCollector{}
+	first, err := admin.NewPlan(ctx, collector, m, admin.PlanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := admin.NewPlan(ctx, collector, m, admin.PlanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Hash() != again.Hash() {
+		t.Error("expected the same plan to have the same hash")
+	}
+
+	// Someone else adds the source before the plan is applied.
+	collector.addSource("reddit", "golang", "https://reddit.com/r/golang", true)
+	changed, err := admin.NewPlan(ctx, collector, m, admin.PlanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Hash() == changed.Hash() {
+		t.Error("expected the hash to change with the plan")
+	}
+
+	// The plan only shows the web hook url without its token, a new token still has to change the hash.
+	webhookDetail := func(plan admin.Plan) string {
+		for _, c := range plan.Changes {
+			if c.Kind == admin.KindWebhook {
+				return c.Detail
+			}
+		}
+		return ""
+	}
+	detail := webhookDetail(first)
+	if detail != "https://discord.com/api/webhooks/..." {
+		t.Errorf("expected the redacted url as the detail, got '%v'", detail)
+	}
+
+	m.Webhooks[0].Url = "https://discord.com/api/webhooks/2"
+	moved, err := admin.NewPlan(ctx, &fakeCollector{}, m, admin.PlanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if webhookDetail(moved) != detail {
+		t.Errorf("expected the same detail for both urls, got '%v'", webhookDetail(moved))
+	}
+	if first.Hash() == moved.Hash() {
+		t.Error("expected the hash to change with the web hook url")
+	}
+}
+
+func TestParseManifestErrors(t *testing.T) {
+	_, err := admin.ParseManifest(strings.NewReader(`
+version: 2
+sources:
+  - source: reddit
+  - source: rss
+    name: blog
+  - source: youtube
+    name: channel
+webhooks:
+  - server: home
+    chanel: news
+`))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "chanel") {
+		t.Errorf("expected the unknown field to be reported, got %v", err)
+	}
+
+	_, err = admin.ParseManifest(strings.NewReader(`
+version: 2
+sources:
+  - source: reddit
+  - source: rss
+    name: blog
+  - source: youtube
+    name: channel
+`))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{admin.ErrManifestVersion, "sources[0]", "'rss'", "youtube/channel"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected '%v' in %v", want, err)
+		}
+	}
+}
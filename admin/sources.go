@@ -0,0 +1,113 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+const (
+	EntryNew       = "new"
+	EntryExists    = "exists"
+	EntryDuplicate = "duplicate"
+	EntryInvalid   = "invalid"
+	EntryCreated   = "created"
+	EntryFailed    = "failed"
+
+	addSourcesConcurrency = 4
+)
+
+// A source read from a file, like an OPML outline or a row in a list, that can be added to the collector.
+// Status says what will happen to it, or what happened once it has been added.
+type SourceEntry struct {
+	Line    int      `json:"line,omitempty"`
+	Source  string   `json:"source"`
+	Name    string   `json:"name"`
+	Url     string   `json:"url,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Status  string   `json:"status"`
+	Error   string   `json:"error,omitempty"`
+	Warning string   `json:"warning,omitempty"`
+}
+
+// Returns true when adding the entry will create a source.
+func (e SourceEntry) IsNew() bool {
+	return e.Status == EntryNew
+}
+
+func (e SourceEntry) manifest() ManifestSource {
+	return ManifestSource{Source: e.Source, Name: e.Name, Url: e.Url}
+}
+
+// This sets the status of every entry that is still valid to new, exists or duplicate,
+// by comparing them with the collector and with the entries before them.
+func CheckSources(ctx context.Context, client api.CollectorApi, entries []SourceEntry) ([]SourceEntry, error) {
+	current, err := loadState(ctx, client)
+	if err != nil {
+		return entries, err
+	}
+
+	checked := make([]SourceEntry, len(entries))
+	seen := make(map[string]bool)
+	for i, entry := range entries {
+		checked[i] = entry
+		if entry.Status == EntryInvalid {
+			continue
+		}
+
+		key := sourceKey(entry.Source, entry.Name)
+		switch {
+		case entry.Source == SourceFfxiv:
+			checked[i].Status = EntryInvalid
+			checked[i].Error = fmt.Sprintf("the collector creates %v sources itself", SourceFfxiv)
+		case seen[key]:
+			checked[i].Status = EntryDuplicate
+		case current.sources[key].Name != "":
+			checked[i].Status = EntryExists
+		default:
+			checked[i].Status = EntryNew
+		}
+		seen[key] = true
+	}
+
+	return checked, nil
+}
+
+// This creates the sources for the entries that are new and marks them created or failed.
+// The other entries are returned as they are.
+//
+// A few sources are created at a time, the RestClient rate limits still apply to every call.
+func AddSources(ctx context.Context, client api.CollectorApi, entries []SourceEntry) []SourceEntry {
+	added := make([]SourceEntry, len(entries))
+	copy(added, entries)
+
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, addSourcesConcurrency)
+	for i := range added {
+		if !added[i].IsNew() {
+			continue
+		}
+
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(entry *SourceEntry) {
+			defer func() {
+				<-limit
+				wg.Done()
+			}()
+
+			_, err := createSource(ctx, client, entry.manifest())
+			if err != nil {
+				entry.Status = EntryFailed
+				entry.Error = err.Error()
+				return
+			}
+			entry.Status = EntryCreated
+		}(&added[i])
+	}
+	wg.Wait()
+
+	return added
+}
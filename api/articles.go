@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/google/uuid"
 )
@@ -17,9 +18,13 @@ type ArticlesApiClient struct {
 }
 
 func NewArticlesClient(Endpoint string) ArticlesApiClient {
+	return newArticlesClient(Endpoint, NewRestClient())
+}
+
+func newArticlesClient(Endpoint string, rest *RestClient) ArticlesApiClient {
 	c := ArticlesApiClient{
 		endpoint: Endpoint,
-		rest:     NewRestClient(),
+		rest:     rest,
 	}
 	return c
 }
@@ -41,7 +46,7 @@ func (c ArticlesApiClient) List(ctx context.Context, param ArticlesListParam) ([
 
 	v := url.Values{}
 	if param.Page >= 1 {
-		v.Add("page", string(param.Page))
+		v.Add("page", strconv.Itoa(int(param.Page)))
 	}
 
 	keys := make([]string, 0, len(v))
@@ -106,7 +111,13 @@ func (c ArticlesApiClient) Get(ctx context.Context, ID uuid.UUID) (*Article, err
 func (c ArticlesApiClient) ListBySourceId(ctx context.Context, ID uuid.UUID, page int) (*[]Article, error) {
 	var items articlesListResult
 
-	uri := fmt.Sprintf("%v/api/articles/by/sourceid?id=%v", c.endpoint, ID)
+	v := url.Values{}
+	v.Add("id", ID.String())
+	if page >= 1 {
+		v.Add("page", strconv.Itoa(page))
+	}
+
+	uri := fmt.Sprintf("%v/api/articles/by/sourceid?%v", c.endpoint, v.Encode())
 	data, err := c.rest.Get(ctx, RestArgs{
 		Url:         uri,
 		StatusCode:  http.StatusOK,
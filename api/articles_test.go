@@ -2,6 +2,8 @@ package api_test
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/jtom38/newsbot/portal/api"
@@ -19,6 +21,25 @@ func TestArticlesList(t *testing.T) {
 	}
 }
 
+func TestArticlesListPage(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte(`{"status":200,"payload":[]}`))
+	}))
+	defer srv.Close()
+
+	c := api.NewArticlesClient(srv.URL)
+	_, err := c.List(context.Background(), api.ArticlesListParam{Page: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if query != "page=12" {
+		t.Errorf("expected page=12, got '%v'", query)
+	}
+}
+
 func TestArticlesGet(t *testing.T) {
 	ctx := context.Background()
 	cfg := services.NewConfigClient()
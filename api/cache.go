@@ -0,0 +1,174 @@
+package api
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+)
+
+const (
+	// The number of GET responses the RestClient keeps around for conditional requests.
+	DefaultCacheSize = 256
+)
+
+// This is a single response that can be reused when the API reports it has not changed.
+type cacheEntry struct {
+	url          string
+	etag         string
+	lastModified string
+	body         []byte
+}
+
+// This adds the validators from the cached response so the API can reply with a 304.
+func (e *cacheEntry) setConditionalHeaders(h http.Header) {
+	if e.etag != "" {
+		h.Set("If-None-Match", e.etag)
+	}
+	if e.lastModified != "" {
+		h.Set("If-Modified-Since", e.lastModified)
+	}
+}
+
+// This is a bounded LRU cache of GET responses keyed by the request url.
+type responseCache struct {
+	mu    sync.Mutex
+	size  int
+	items map[string]*list.Element
+	order *list.List
+
+	hits   uint64
+	misses uint64
+}
+
+func newResponseCache(size int) *responseCache {
+	return &responseCache{
+		size:  size,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// This changes how many responses can be kept, the least recently used are dropped to fit.
+// A size of 0 turns the cache off.
+func (c *responseCache) resize(size int) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.size = size
+	c.trim()
+}
+
+// Drops every response and returns how many there were.
+func (c *responseCache) purge() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.order.Len()
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+	return n
+}
+
+func (c *responseCache) trim() {
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).url)
+	}
+}
+
+// Returns the cached response for the url, if we have one.
+// A nil cache never has anything so callers do not need to check if caching is enabled.
+func (c *responseCache) get(url string) (*cacheEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[url]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+
+	return el.Value.(*cacheEntry), true
+}
+
+// This stores the response if it came back with something we can validate against later.
+func (c *responseCache) put(url string, h http.Header, body []byte) {
+	if c == nil {
+		return
+	}
+
+	entry := &cacheEntry{
+		url:          url,
+		etag:         h.Get("ETag"),
+		lastModified: h.Get("Last-Modified"),
+		body:         body,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Without a validator we would never get a 304 back, so do not waste the space.
+	if entry.etag == "" && entry.lastModified == "" {
+		if el, ok := c.items[url]; ok {
+			c.order.Remove(el)
+			delete(c.items, url)
+		}
+		return
+	}
+
+	if el, ok := c.items[url]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+
+	if c.size < 1 {
+		return
+	}
+
+	c.items[url] = c.order.PushFront(entry)
+	c.trim()
+}
+
+func (c *responseCache) hit() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.hits++
+	c.mu.Unlock()
+}
+
+func (c *responseCache) miss() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.misses++
+	c.mu.Unlock()
+}
+
+func (c *responseCache) stats(s *RestStats) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	s.CacheHits = c.hits
+	s.CacheMisses = c.misses
+	s.CacheEntries = c.order.Len()
+	s.CacheSize = c.size
+	c.mu.Unlock()
+}
@@ -2,6 +2,7 @@ package api
 
 type ApiClient struct {
 	endpoint string
+	rest     *RestClient
 
 	_articles      ArticlesApi
 	_sources       SourcesApi
@@ -10,19 +11,30 @@ type ApiClient struct {
 }
 
 func New(Endpoint string) ApiClient {
-	//client := http.Client{}
+	return NewWithRestClient(Endpoint, NewRestClient())
+}
+
+// This builds the client around a single RestClient that is shared by all the routes.
+// That way the response cache and its counters cover every call made to the API.
+func NewWithRestClient(Endpoint string, rest *RestClient) ApiClient {
 	c := ApiClient{
 		endpoint: Endpoint,
+		rest:     rest,
 
-		_articles:      NewArticlesClient(Endpoint),
-		_sources:       NewSourcesApiClient(Endpoint),
-		_outputs:       NewOutputsApiClient(Endpoint),
-		_subscriptions: NewSubscriptionsClient(Endpoint),
+		_articles:      newArticlesClient(Endpoint, rest),
+		_sources:       newSourcesApiClient(Endpoint, rest),
+		_outputs:       newOutputsApiClient(Endpoint, rest),
+		_subscriptions: newSubscriptionsClient(Endpoint, rest),
 	}
 
 	return c
 }
 
+// Returns the counters from the RestClient that is used to talk to the API.
+func (c ApiClient) Stats() RestStats {
+	return c.rest.Stats()
+}
+
 func (c ApiClient) Articles() ArticlesApi {
 	return c._articles
 }
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ErrUnknownVersion     = "the collector did not report a version"
+	ErrUnsupportedVersion = "the collector version is not supported by this portal"
+	ErrVersionUnavailable = "unable to ask the collector for its version"
+
+	// The oldest collector release this portal knows how to talk to.
+	MinCollectorVersion = "0.1.0"
+
+	// Collector releases with a newer major version can change the api without warning.
+	MaxCollectorMajorVersion = 0
+)
+
+// This is what the portal knows about the collector after it checks in with it.
+type CollectorInfo struct {
+	Version string        `json:"version"`
+	Latency time.Duration `json:"latency"`
+}
+
+var errVersionUnavailable = errors.New(ErrVersionUnavailable)
+
+// Reports if the error from Info came from asking for the version.
+// The collector responded to the ping in that case, it only lacks the endpoint or sent back something unexpected.
+func IsVersionUnavailable(err error) bool {
+	return errors.Is(err, errVersionUnavailable)
+}
+
+type versionResult struct {
+	RestPayload
+	Payload struct {
+		Version string `json:"version"`
+	} `json:"payload"`
+}
+
+// Checks that the API is able to respond.
+//
+// Route = /api/ping
+func (c ApiClient) Ping(ctx context.Context) error {
+	uri := fmt.Sprintf("%v/api/ping", c.endpoint)
+	_, err := c.rest.Get(ctx, RestArgs{
+		Url:        uri,
+		StatusCode: http.StatusOK,
+	})
+	return err
+}
+
+// This pings the API and asks for its version.
+// When the ping works but the version can not be read, the error is one IsVersionUnavailable reports on
+// so it can be told apart from a collector that is down.
+//
+// Route = /api/version
+func (c ApiClient) Info(ctx context.Context) (CollectorInfo, error) {
+	var info CollectorInfo
+
+	start := time.Now()
+	err := c.Ping(ctx)
+	info.Latency = time.Since(start)
+	if err != nil {
+		return info, err
+	}
+
+	var item versionResult
+	uri := fmt.Sprintf("%v/api/version", c.endpoint)
+	body, err := c.rest.Get(ctx, RestArgs{
+		Url:         uri,
+		StatusCode:  http.StatusOK,
+		ContentType: ContentTypeJson,
+	})
+	if err != nil {
+		return info, fmt.Errorf("%w: %v", errVersionUnavailable, err)
+	}
+
+	err = json.Unmarshal(body, &item)
+	if err != nil {
+		return info, fmt.Errorf("%w: %v", errVersionUnavailable, err)
+	}
+	info.Version = item.Payload.Version
+
+	return info, nil
+}
+
+// This keeps trying to reach the API until it responds or the timeout runs out.
+// The wait between attempts doubles each time, up to 30 seconds.
+func WaitForCollector(ctx context.Context, c CollectorApi, timeout time.Duration) (CollectorInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := 500 * time.Millisecond
+	for {
+		info, err := c.Info(ctx)
+		if err == nil || IsVersionUnavailable(err) {
+			return info, err
+		}
+		slog.WarnContext(ctx, "collector is not reachable yet", "retry_in", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return info, fmt.Errorf("gave up waiting on the collector: %w", err)
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+// Checks if the portal supports the version the collector reported.
+func CheckCollectorVersion(version string) error {
+	if version == "" {
+		return errors.New(ErrUnknownVersion)
+	}
+
+	v, err := parseVersion(version)
+	if err != nil {
+		return err
+	}
+
+	min, _ := parseVersion(MinCollectorVersion)
+	if compareVersions(v, min) < 0 || v[0] > MaxCollectorMajorVersion {
+		return fmt.Errorf("%v, found '%v' but expected %v or newer below %v.0.0", ErrUnsupportedVersion, version, MinCollectorVersion, MaxCollectorMajorVersion+1)
+	}
+
+	return nil
+}
+
+// Parses versions like "v1.2.3" or "1.2".  Anything after a "-" or "+" is ignored.
+func parseVersion(value string) ([3]int, error) {
+	var v [3]int
+
+	value = strings.TrimPrefix(strings.TrimSpace(value), "v")
+	if i := strings.IndexAny(value, "-+"); i >= 0 {
+		value = value[:i]
+	}
+
+	parts := strings.Split(value, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid version '%v'", value)
+	}
+
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return v, fmt.Errorf("invalid version '%v'", value)
+		}
+		v[i] = n
+	}
+
+	return v, nil
+}
+
+func compareVersions(a [3]int, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
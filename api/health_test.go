@@ -0,0 +1,71 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+func TestCheckCollectorVersion(t *testing.T) {
+	supported := []string{"0.1.0", "v0.4.2", "0.9.1-beta"}
+	for _, v := range supported {
+		if err := api.CheckCollectorVersion(v); err != nil {
+			t.Errorf("expected '%v' to be supported, %v", v, err)
+		}
+	}
+
+	unsupported := []string{"", "0.0.9", "1.0.0", "latest"}
+	for _, v := range unsupported {
+		if err := api.CheckCollectorVersion(v); err == nil {
+			t.Errorf("expected '%v' to not be supported", v)
+		}
+	}
+}
+
+func TestInfo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/ping":
+			w.Write([]byte("pong"))
+		case "/api/version":
+			w.Write([]byte(`{"status":200,"payload":{"version":"0.2.0"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	info, err := api.New(srv.URL).Info(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Version != "0.2.0" {
+		t.Errorf("expected version 0.2.0, got '%v'", info.Version)
+	}
+}
+
+func TestInfoWithoutVersion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/ping" {
+			w.Write([]byte("pong"))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	_, err := api.New(srv.URL).Info(context.Background())
+	if !api.IsVersionUnavailable(err) {
+		t.Errorf("expected the missing version endpoint to be reported, got %v", err)
+	}
+
+	srv.Close()
+	_, err = api.New(srv.URL).Info(context.Background())
+	if err == nil || api.IsVersionUnavailable(err) {
+		t.Errorf("expected a collector that is down to not be a version problem, got %v", err)
+	}
+}
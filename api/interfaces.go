@@ -7,6 +7,8 @@ import (
 )
 
 type CollectorApi interface {
+	Info(ctx context.Context) (CollectorInfo, error)
+
 	Articles() ArticlesApi
 	Sources() SourcesApi
 	Outputs() OutputsApi
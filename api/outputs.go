@@ -16,10 +16,14 @@ type OutputApiClient struct {
 }
 
 func NewOutputsApiClient(endpoint string) OutputsApi {
+	return newOutputsApiClient(endpoint, NewRestClient())
+}
+
+func newOutputsApiClient(endpoint string, rest *RestClient) OutputsApi {
 	c := OutputApiClient{
 		endpoint: endpoint,
 
-		discordWebHooks: NewDiscordWebHooksClient(endpoint),
+		discordWebHooks: newDiscordWebHooksClient(endpoint, rest),
 	}
 	return c
 }
@@ -34,12 +38,15 @@ type DiscordWebHooksClient struct {
 }
 
 func NewDiscordWebHooksClient(endpoint string) DiscordWebHooksClient {
+	return newDiscordWebHooksClient(endpoint, NewRestClient())
+}
+
+func newDiscordWebHooksClient(endpoint string, rest *RestClient) DiscordWebHooksClient {
 	c := DiscordWebHooksClient{
 		endpoint: endpoint,
-		client:   *NewRestClient(),
+		client:   *rest,
 	}
 	return c
-
 }
 
 type discordWebHooksListResult struct {
@@ -145,7 +152,7 @@ func (c DiscordWebHooksClient) GetByServerAndChannel(ctx context.Context, server
 	var items discordWebHooksListResult
 	uri := fmt.Sprintf("%v/api/discord/webhooks/by/serverAndChannel?server=%v&channel=%v", c.endpoint, server, channel)
 
-	resp, err := c.client.Get(context.Background(), RestArgs{
+	resp, err := c.client.Get(ctx, RestArgs{
 		Url:         uri,
 		StatusCode:  200,
 		ContentType: ContentTypeJson,
@@ -18,6 +18,7 @@ func NewQueueClient(serverAddress string) QueueClient {
 	c := QueueClient{
 		apiServer: serverAddress,
 		routeRoot: "api/queue",
+		rest:      *NewRestClient(),
 	}
 
 	return c
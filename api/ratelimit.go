@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	ErrRateLimited = "the request would not be sent before the context deadline because of the rate limit"
+)
+
+// RateLimit defines a token bucket.
+// Rate is the number of requests per second that are refilled and Burst is the size of the bucket.
+// A Rate of 0 turns the limit off.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+type RateLimitOptions struct {
+	// The limit that applies to every request sent to the API.
+	Global RateLimit
+
+	// Limits that only apply to requests where the path starts with the key, like "/api/sources".
+	// These are checked along with the global limit.
+	Routes map[string]RateLimit
+}
+
+type routeLimiter struct {
+	prefix  string
+	limiter *rate.Limiter
+}
+
+// This holds the token buckets and keeps track of how often requests had to wait.
+type rateLimiter struct {
+	mu     sync.Mutex
+	global *rate.Limiter
+	routes []routeLimiter
+
+	throttled uint64
+	rejected  uint64
+}
+
+func newRateLimiter(opts RateLimitOptions) *rateLimiter {
+	l := rateLimiter{}
+	l.set(opts)
+	return &l
+}
+
+// This replaces the token buckets, requests that are already waiting keep their place.
+func (l *rateLimiter) set(opts RateLimitOptions) {
+	var routes []routeLimiter
+	for prefix, limit := range opts.Routes {
+		lim := newLimiter(limit)
+		if lim == nil {
+			continue
+		}
+		routes = append(routes, routeLimiter{prefix: prefix, limiter: lim})
+	}
+
+	// The most specific prefix wins when more than one could match.
+	sort.Slice(routes, func(i, j int) bool {
+		return len(routes[i].prefix) > len(routes[j].prefix)
+	})
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.global = newLimiter(opts.Global)
+	l.routes = routes
+}
+
+func newLimiter(limit RateLimit) *rate.Limiter {
+	if limit.Rate <= 0 {
+		return nil
+	}
+
+	burst := limit.Burst
+	if burst < 1 {
+		burst = 1
+	}
+
+	return rate.NewLimiter(rate.Limit(limit.Rate), burst)
+}
+
+// This blocks until the request to path is allowed to go out.
+// If the wait would run past the context deadline the request is rejected right away.
+func (l *rateLimiter) wait(ctx context.Context, path string) error {
+	if l == nil {
+		return nil
+	}
+
+	var reservations []*rate.Reservation
+	cancel := func() {
+		for _, r := range reservations {
+			r.Cancel()
+		}
+	}
+
+	l.mu.Lock()
+	limiters := []*rate.Limiter{l.global}
+	for _, route := range l.routes {
+		if strings.HasPrefix(path, route.prefix) {
+			limiters = append(limiters, route.limiter)
+			break
+		}
+	}
+	l.mu.Unlock()
+
+	var delay time.Duration
+	for _, lim := range limiters {
+		if lim == nil {
+			continue
+		}
+
+		r := lim.Reserve()
+		if !r.OK() {
+			cancel()
+			return errors.New(ErrRateLimited)
+		}
+		reservations = append(reservations, r)
+
+		if d := r.Delay(); d > delay {
+			delay = d
+		}
+	}
+
+	if delay == 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	l.throttled++
+	l.mu.Unlock()
+
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+		cancel()
+		l.reject()
+		return errors.New(ErrRateLimited)
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		cancel()
+		l.reject()
+		return ctx.Err()
+	}
+}
+
+func (l *rateLimiter) reject() {
+	l.mu.Lock()
+	l.rejected++
+	l.mu.Unlock()
+}
+
+func (l *rateLimiter) stats(s *RestStats) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	s.Throttled = l.throttled
+	s.Rejected = l.rejected
+	l.mu.Unlock()
+}
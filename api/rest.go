@@ -7,8 +7,20 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jtom38/newsbot/portal/services"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -17,41 +29,133 @@ const (
 	ContentTypeJson = "application/json"
 )
 
+var tracer = otel.Tracer("github.com/jtom38/newsbot/portal/api")
+
 type RestClient struct {
-	client http.Client
+	// Settings that can be changed with Reconfigure while requests are being sent.
+	// This is a pointer so copies of the RestClient see the change.
+	settings *restSettings
+
+	// Holds the GET responses that can be revalidated with the API.
+	// This is a pointer so copies of the RestClient share the same cache.
+	cache *responseCache
+
+	// Keeps the portal from flooding the API when a lot of pages are being loaded.
+	limiter *rateLimiter
+
+	// Tracks how the requests to the API have been going.
+	stats *requestStats
+
+	// Optional hook to report on each request, like for metrics.
+	observer RestObserver
+}
+
+type restSettings struct {
+	client atomic.Pointer[http.Client]
+}
+
+type RestClientOptions struct {
+	// The number of GET responses to keep for conditional requests.
+	// Set to 0 to disable the cache.
+	CacheSize int
+
+	// Limits how fast requests are sent to the API.
+	// Requests wait for their turn until the context deadline.
+	RateLimit RateLimitOptions
+
+	// How long a single attempt can take, 0 means no timeout.
+	Timeout time.Duration
+
+	// Gets called for every request sent to the API.
+	Observer RestObserver
+}
+
+// RestObserver is told about every request the RestClient sends so it can be monitored.
+// The route is the path with IDs replaced, like /api/articles/{id}.
+type RestObserver interface {
+	ObserveRequest(method string, route string, status int, duration time.Duration, err error)
 }
 
 func NewRestClient() *RestClient {
-	return &RestClient{
-		client: http.Client{},
+	return NewRestClientWithOptions(RestClientOptions{
+		CacheSize: DefaultCacheSize,
+	})
+}
+
+func NewRestClientWithOptions(opts RestClientOptions) *RestClient {
+	c := RestClient{
+		settings: &restSettings{},
+		cache:    newResponseCache(0),
+		limiter:  newRateLimiter(RateLimitOptions{}),
+		stats:    &requestStats{},
+		observer: opts.Observer,
 	}
+	c.Reconfigure(opts)
+
+	return &c
+}
+
+// This applies new options to a RestClient that is already in use.
+// Requests that are in flight finish with the old options.
+// The cache keeps what it can within the new size and the Observer is not changed.
+func (c RestClient) Reconfigure(opts RestClientOptions) {
+	c.settings.client.Store(&http.Client{Timeout: opts.Timeout})
+	c.cache.resize(opts.CacheSize)
+	c.limiter.set(opts.RateLimit)
+}
+
+// Drops every cached response and returns how many there were.
+func (c RestClient) PurgeCache() int {
+	return c.cache.purge()
 }
 
 type RestArgs struct {
 	Url         string
 	StatusCode  int
 	ContentType string
+	Headers     http.Header
 	Body        interface{}
 	//Model       interface{}
 }
 
+// Get will send If-None-Match and If-Modified-Since when it has seen the url before.
+// If the API replies with 304 the cached body is returned instead.
 func (c RestClient) Get(ctx context.Context, Args RestArgs) ([]byte, error) {
 	var res []byte
 	var r *http.Response
 
-	r, err := c.request(ctx, http.MethodGet, Args)
+	entry, cached := c.cache.get(Args.Url)
+	if cached {
+		Args.Headers = Args.Headers.Clone()
+		if Args.Headers == nil {
+			Args.Headers = http.Header{}
+		}
+		entry.setConditionalHeaders(Args.Headers)
+	}
+
+	r, err := c.send(ctx, http.MethodGet, Args)
 	if err != nil {
 		return res, err
 	}
 	defer r.Body.Close()
 
+	if cached && r.StatusCode == http.StatusNotModified {
+		c.cache.hit()
+		return entry.body, nil
+	}
+
 	res, err = io.ReadAll(r.Body)
 	if err != nil {
 		return res, err
 	}
 
 	if r.StatusCode != Args.StatusCode {
-		return res, fmt.Errorf(string(res))
+		return res, fmt.Errorf("%v: %v", ErrInvalidStatusCode, string(res))
+	}
+
+	c.cache.miss()
+	if r.StatusCode == http.StatusOK {
+		c.cache.put(Args.Url, r.Header, res)
 	}
 
 	return res, nil
@@ -81,8 +185,22 @@ func (c RestClient) Delete(ctx context.Context, Args RestArgs) (*http.Response,
 
 // This handles the request flow and is the main logic loop for talking to the API.
 func (c RestClient) request(ctx context.Context, method string, args RestArgs) (*http.Response, error) {
-	var r *http.Response
+	r, err := c.send(ctx, method, args)
+	if err != nil {
+		return r, err
+	}
+
+	err = c.checkResponse(r.StatusCode, args.StatusCode)
+	if err != nil {
+		return r, err
+	}
 
+	return r, nil
+}
+
+// This sends the request to the API without looking at the status code that came back.
+// Each request gets its own span and the trace context is passed on to the API.
+func (c RestClient) send(ctx context.Context, method string, args RestArgs) (*http.Response, error) {
 	// replace spaces with url safe values
 	// I have not figured out the url package yet.
 	if strings.Contains(args.Url, " ") {
@@ -90,24 +208,81 @@ func (c RestClient) request(ctx context.Context, method string, args RestArgs) (
 		args.Url = u
 	}
 
+	route := routePattern(args.Url)
+	var r *http.Response
+
+	ctx, span := tracer.Start(ctx, fmt.Sprintf("%v %v", method, route), trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
 	req, err := c.generateRequest(ctx, args, method)
 	if err != nil {
+		span.RecordError(err)
 		return r, err
 	}
+	span.SetAttributes(
+		attribute.String("http.method", method),
+		attribute.String("http.route", route),
+		attribute.String("http.url", req.URL.String()),
+	)
 
-	r, err = c.client.Do(req)
+	err = c.limiter.wait(ctx, req.URL.Path)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return r, err
 	}
 
-	err = c.checkResponse(r.StatusCode, args.StatusCode)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
+	r, err = c.settings.client.Load().Do(req)
+	elapsed := time.Since(start)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.WarnContext(ctx, "api request failed", "method", method, "route", route, "duration", elapsed, "error", err)
+		c.stats.record(elapsed, err)
+		c.observe(func(o RestObserver) { o.ObserveRequest(method, route, 0, elapsed, err) })
 		return r, err
 	}
+	span.SetAttributes(attribute.Int("http.status_code", r.StatusCode))
+
+	var failed error
+	if r.StatusCode >= http.StatusInternalServerError {
+		failed = fmt.Errorf("%v %v returned %v", method, req.URL.Path, r.Status)
+		span.SetStatus(codes.Error, failed.Error())
+	}
+	slog.DebugContext(ctx, "api request", "method", method, "route", route, "status", r.StatusCode, "duration", elapsed)
+	c.stats.record(elapsed, failed)
+	c.observe(func(o RestObserver) { o.ObserveRequest(method, route, r.StatusCode, elapsed, failed) })
 
 	return r, nil
 }
 
+func (c RestClient) observe(fn func(o RestObserver)) {
+	if c.observer != nil {
+		fn(c.observer)
+	}
+}
+
+// This turns the url into a route pattern, so /api/articles/{uuid} becomes /api/articles/{id}.
+// The pattern is used as a label when reporting on requests so it needs to stay low cardinality.
+func routePattern(uri string) string {
+	path := uri
+	if u, err := url.Parse(uri); err == nil {
+		path = u.Path
+	}
+
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if _, err := uuid.Parse(part); err == nil {
+			parts[i] = "{id}"
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
+
 // This generates the http.Request object based in the information given.
 func (c RestClient) generateRequest(ctx context.Context, Args RestArgs, method string) (*http.Request, error) {
 	var req *http.Request
@@ -122,11 +297,21 @@ func (c RestClient) generateRequest(ctx context.Context, Args RestArgs, method s
 		return req, err
 	}
 
-	// Add headers here later
+	for key, values := range Args.Headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
 	if Args.ContentType != "" {
 		req.Header.Add("Content-Type", Args.ContentType)
 	}
 
+	// Lets the collector logs be matched up with the page that made the call.
+	if id := services.RequestID(ctx); id != "" {
+		req.Header.Set(services.RequestIDHeader, id)
+	}
+
 	return req, nil
 }
 
@@ -0,0 +1,165 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jtom38/newsbot/portal/api"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestRestClientConditionalGet(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"status":200}`))
+	}))
+	defer srv.Close()
+
+	c := api.NewRestClient()
+	args := api.RestArgs{
+		Url:        srv.URL + "/api/articles",
+		StatusCode: http.StatusOK,
+	}
+
+	first, err := c.Get(ctx, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := c.Get(ctx, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(first) != string(second) {
+		t.Errorf("expected the cached body, got '%v'", string(second))
+	}
+
+	if calls != 2 {
+		t.Errorf("expected 2 calls to the api, got %v", calls)
+	}
+
+	stats := c.Stats()
+	if stats.CacheHits != 1 || stats.CacheMisses != 1 {
+		t.Errorf("expected 1 hit and 1 miss, got %v and %v", stats.CacheHits, stats.CacheMisses)
+	}
+}
+
+func TestRestClientCacheIsBounded(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+
+	c := api.NewRestClientWithOptions(api.RestClientOptions{CacheSize: 2})
+	for _, path := range []string{"/a", "/b", "/c"} {
+		_, err := c.Get(ctx, api.RestArgs{Url: srv.URL + path, StatusCode: http.StatusOK})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if c.Stats().CacheEntries != 2 {
+		t.Errorf("expected the cache to hold 2 entries, got %v", c.Stats().CacheEntries)
+	}
+}
+
+func TestRestClientRateLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	c := api.NewRestClientWithOptions(api.RestClientOptions{
+		RateLimit: api.RateLimitOptions{
+			Routes: map[string]api.RateLimit{
+				"/api/sources": {Rate: 1, Burst: 1},
+			},
+		},
+	})
+	args := api.RestArgs{Url: srv.URL + "/api/sources/1", StatusCode: http.StatusOK}
+
+	_, err := c.Get(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The bucket is empty so this has to wait about a second, longer than it is allowed to.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.Get(ctx, args)
+	if err == nil {
+		t.Error("expected the request to be rejected by the rate limit")
+	}
+
+	// Other routes are not part of the limit.
+	_, err = c.Get(ctx, api.RestArgs{Url: srv.URL + "/api/articles", StatusCode: http.StatusOK})
+	if err != nil {
+		t.Error(err)
+	}
+
+	stats := c.Stats()
+	if stats.Throttled != 1 || stats.Rejected != 1 {
+		t.Errorf("expected 1 throttled and 1 rejected, got %v and %v", stats.Throttled, stats.Rejected)
+	}
+}
+
+func TestRestClientPropagatesTraceContext(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "page")
+	defer span.End()
+
+	_, err := api.NewRestClient().Get(ctx, api.RestArgs{Url: srv.URL + "/api/articles", StatusCode: http.StatusOK})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(traceparent, span.SpanContext().TraceID().String()) {
+		t.Errorf("expected the trace id to be sent to the api, got '%v'", traceparent)
+	}
+}
+
+func TestRestClientReconfigure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client := api.NewRestClientWithOptions(api.RestClientOptions{})
+	_, err := client.Get(context.Background(), api.RestArgs{Url: srv.URL, StatusCode: http.StatusOK})
+	if err != nil {
+		t.Fatalf("expected the request to finish without a timeout, got %v", err)
+	}
+
+	shared := *client
+	client.Reconfigure(api.RestClientOptions{Timeout: 10 * time.Millisecond})
+	_, err = shared.Get(context.Background(), api.RestArgs{Url: srv.URL + "/again", StatusCode: http.StatusOK})
+	if err == nil {
+		t.Error("expected copies of the client to pick up the new timeout")
+	}
+}
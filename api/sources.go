@@ -19,9 +19,14 @@ type SourcesApiClient struct {
 }
 
 func NewSourcesApiClient(serverAddress string) SourcesApiClient {
+	return newSourcesApiClient(serverAddress, NewRestClient())
+}
+
+func newSourcesApiClient(serverAddress string, rest *RestClient) SourcesApiClient {
 	c := SourcesApiClient{
 		apiServer: serverAddress,
 		routeRoot: "api/sources",
+		rest:      *rest,
 	}
 	return c
 }
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// This contains the counters the RestClient keeps so they can be monitored.
+type RestStats struct {
+	CacheHits    uint64 `json:"cacheHits"`
+	CacheMisses  uint64 `json:"cacheMisses"`
+	CacheEntries int    `json:"cacheEntries"`
+	CacheSize    int    `json:"cacheSize"`
+
+	// Requests that had to wait on the rate limit and the ones that gave up waiting.
+	Throttled uint64 `json:"throttled"`
+	Rejected  uint64 `json:"rejected"`
+
+	// Requests sent to the API and the ones that failed to connect or returned a 5xx.
+	Requests uint64 `json:"requests"`
+	Errors   uint64 `json:"errors"`
+
+	// How long the last response took to come back.
+	LastLatency time.Duration `json:"lastLatency"`
+
+	// The most recent failure, if there has been one.
+	LastError   string    `json:"lastError,omitempty"`
+	LastErrorAt time.Time `json:"lastErrorAt"`
+}
+
+// Returns a snapshot of the counters collected by the client.
+func (c RestClient) Stats() RestStats {
+	var s RestStats
+	c.cache.stats(&s)
+	c.limiter.stats(&s)
+	c.stats.stats(&s)
+	return s
+}
+
+type requestStats struct {
+	mu sync.Mutex
+
+	requests    uint64
+	errors      uint64
+	lastLatency time.Duration
+	lastError   string
+	lastErrorAt time.Time
+}
+
+func (r *requestStats) record(latency time.Duration, err error) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests++
+	r.lastLatency = latency
+	if err != nil {
+		r.errors++
+		r.lastError = err.Error()
+		r.lastErrorAt = time.Now()
+	}
+}
+
+func (r *requestStats) stats(s *RestStats) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s.Requests = r.requests
+	s.Errors = r.errors
+	s.LastLatency = r.lastLatency
+	s.LastError = r.lastError
+	s.LastErrorAt = r.lastErrorAt
+}
@@ -16,10 +16,14 @@ type SubscriptionsApiClient struct {
 }
 
 func NewSubscriptionsClient(endpoint string) SubscriptionsApiClient {
+	return newSubscriptionsClient(endpoint, NewRestClient())
+}
+
+func newSubscriptionsClient(endpoint string, rest *RestClient) SubscriptionsApiClient {
 	c := SubscriptionsApiClient{
 		endpoint:   endpoint,
 		routeRoute: "api/subscriptions",
-		client:     NewRestClient(),
+		client:     rest,
 	}
 	return c
 }
@@ -84,7 +88,7 @@ func (c SubscriptionsApiClient) GetByDiscordID(ctx context.Context, ID uuid.UUID
 	var items listSubscriptionsResult
 
 	uri := fmt.Sprintf("%v/%v/by/discordId?id=%v", c.endpoint, c.routeRoute, ID.String())
-	body, err := c.client.Get(context.Background(), RestArgs{
+	body, err := c.client.Get(ctx, RestArgs{
 		Url:         uri,
 		StatusCode:  http.StatusOK,
 		ContentType: ContentTypeJson,
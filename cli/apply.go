@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jtom38/newsbot/portal/admin"
+)
+
+// This is what `portal apply -o json` reports.
+type ApplyResult struct {
+	DryRun  bool           `json:"dryRun"`
+	Plan    admin.Plan     `json:"plan"`
+	Applied []admin.Change `json:"applied"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// portal apply -f <manifest> [-dry-run] [-prune] [-o json]
+//
+// Compares the manifest with the collector, prints the plan and then makes the changes.
+// Nothing is changed when the plan has conflicts or -dry-run is given.
+func (a App) apply(ctx context.Context, args []string) error {
+	flags := a.newFlags("apply", true)
+	file := flags.fs.String("f", "", "the manifest to apply, - reads it from stdin")
+	dryRun := flags.fs.Bool("dry-run", false, "only print the plan")
+	prune := flags.fs.Bool("prune", false, "delete sources, web hooks and subscriptions that are not in the manifest")
+	cfg, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *file == "" {
+		return usageError("-f is required")
+	}
+
+	m, err := a.readManifest(*file)
+	if err != nil {
+		return err
+	}
+
+	err = setupCommandLogger(a, cfg)
+	if err != nil {
+		return err
+	}
+
+	client := newApiClient(cfg)
+	plan, err := admin.NewPlan(ctx, client, m, admin.PlanOptions{Prune: *prune})
+	if err != nil {
+		return err
+	}
+
+	res := ApplyResult{
+		DryRun:  *dryRun,
+		Plan:    plan,
+		Applied: []admin.Change{},
+	}
+
+	var failed error
+	switch {
+	case len(plan.Conflicts) > 0:
+		failed = errors.New(admin.ErrPlanConflicts)
+	case !*dryRun:
+		res.Applied, failed = plan.Apply(ctx, client)
+	}
+	if failed != nil {
+		res.Error = failed.Error()
+	}
+
+	if *flags.output == OutputJson {
+		err = a.print(OutputJson, res, nil, nil)
+		if err != nil {
+			return err
+		}
+		return failed
+	}
+
+	err = a.printPlan(plan)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case failed != nil:
+	case plan.Empty():
+		fmt.Fprintln(a.Stdout, "nothing to change, the collector matches the manifest")
+	case *dryRun:
+		fmt.Fprintf(a.Stdout, "dry run, %v changes were not applied\n", len(plan.Changes))
+	default:
+		fmt.Fprintf(a.Stdout, "applied %v changes\n", len(res.Applied))
+	}
+
+	return failed
+}
+
+func (a App) readManifest(file string) (admin.Manifest, error) {
+	var r io.Reader = a.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return admin.Manifest{}, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	m, err := admin.ParseManifest(r)
+	if err != nil {
+		return m, fmt.Errorf("invalid manifest:\n%w", err)
+	}
+	return m, nil
+}
+
+func (a App) printPlan(plan admin.Plan) error {
+	for _, conflict := range plan.Conflicts {
+		fmt.Fprintln(a.Stderr, "conflict:", conflict)
+	}
+	if len(plan.Changes) == 0 {
+		return nil
+	}
+
+	var rows [][]string
+	for _, c := range plan.Changes {
+		rows = append(rows, []string{c.Action, c.Kind, c.Name, c.ID + c.Detail})
+	}
+	return a.print(OutputTable, nil, []string{"ACTION", "KIND", "NAME", "DETAIL"}, rows)
+}
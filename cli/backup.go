@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jtom38/newsbot/portal/admin"
+)
+
+// portal export [-format yaml|json] [-f <file>]
+//
+// Writes every source, Discord web hook and subscription to a backup.
+func (a App) export(ctx context.Context, args []string) error {
+	flags := a.newFlags("export", false)
+	format := flags.fs.String("format", admin.FormatYaml, "write the backup as yaml or json")
+	file := flags.fs.String("f", "-", "the file to write the backup to, - writes it to stdout")
+	cfg, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *format != admin.FormatYaml && *format != admin.FormatJson {
+		return usageError("-format needs to be yaml or json")
+	}
+
+	err = setupCommandLogger(a, cfg)
+	if err != nil {
+		return err
+	}
+
+	b, err := admin.Export(ctx, newApiClient(cfg))
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = a.Stdout
+	if *file != "-" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	err = b.Write(w, *format)
+	if err != nil {
+		return err
+	}
+
+	if *file != "-" {
+		fmt.Fprintf(a.Stdout, "exported %v sources, %v webhooks and %v subscriptions to %v\n", len(b.Sources), len(b.Webhooks), len(b.Subscriptions), *file)
+	}
+	return nil
+}
+
+// portal import -f <file> [-dry-run] [-o json]
+//
+// Recreates the sources, Discord web hooks and subscriptions in a backup.
+func (a App) importBackup(ctx context.Context, args []string) error {
+	flags := a.newFlags("import", true)
+	file := flags.fs.String("f", "", "the backup to import, - reads it from stdin")
+	dryRun := flags.fs.Bool("dry-run", false, "only print what would be created")
+	cfg, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *file == "" {
+		return usageError("-f is required")
+	}
+
+	var r io.Reader = a.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	b, err := admin.ParseBackup(r)
+	if err != nil {
+		return err
+	}
+
+	err = setupCommandLogger(a, cfg)
+	if err != nil {
+		return err
+	}
+
+	res, failed := admin.Import(ctx, newApiClient(cfg), b, admin.ImportOptions{DryRun: *dryRun})
+	return a.printImport(*flags.output, res, failed)
+}
+
+// Prints what an import or copy did, followed by the conflicts and warnings.
+func (a App) printImport(output string, res admin.ImportResult, failed error) error {
+	if output == OutputJson {
+		err := a.print(OutputJson, res, nil, nil)
+		if err != nil {
+			return err
+		}
+		return failed
+	}
+
+	var rows [][]string
+	for _, c := range res.Changes {
+		rows = append(rows, []string{c.Action, c.Kind, c.Name, c.ID + c.Detail})
+	}
+	if len(rows) > 0 {
+		err := a.print(OutputTable, nil, []string{"ACTION", "KIND", "NAME", "DETAIL"}, rows)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, conflict := range res.Conflicts {
+		fmt.Fprintln(a.Stderr, "conflict:", conflict)
+	}
+	for _, warning := range res.Warnings {
+		fmt.Fprintln(a.Stderr, "warning:", warning)
+	}
+
+	switch {
+	case failed != nil:
+	case res.DryRun:
+		fmt.Fprintf(a.Stdout, "dry run, %v changes were not made\n", len(res.Changes))
+	default:
+		fmt.Fprintf(a.Stdout, "made %v changes\n", len(res.Changes))
+	}
+	return failed
+}
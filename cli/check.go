@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+// This is what `portal check` reports.
+type CheckResult struct {
+	ConfigFile       string `json:"configFile"`
+	Collector        string `json:"collector"`
+	CollectorVersion string `json:"collectorVersion"`
+	LatencyMs        int64  `json:"latencyMs"`
+	Error            string `json:"error,omitempty"`
+	VersionError     string `json:"versionError,omitempty"`
+}
+
+// portal check
+//
+// Validates the config and checks that the collector responds with a version the portal supports.
+// A version problem only fails the check when the version check is set to strict.
+func (a App) check(ctx context.Context, args []string) error {
+	flags := a.newFlags("check", true)
+	cfg, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+
+	err = setupCommandLogger(a, cfg)
+	if err != nil {
+		return err
+	}
+
+	res := CheckResult{
+		ConfigFile: cfg.File,
+		Collector:  cfg.Api.Address,
+	}
+
+	client := newApiClient(cfg)
+	info, err := client.Info(ctx)
+	res.CollectorVersion = info.Version
+	res.LatencyMs = info.Latency.Milliseconds()
+
+	// The collector can be up without telling us its version, that is a version problem and not an outage.
+	verr := err
+	if err == nil {
+		verr = api.CheckCollectorVersion(info.Version)
+	} else if !api.IsVersionUnavailable(err) {
+		res.Error = err.Error()
+	}
+
+	var failed error
+	if res.Error != "" {
+		failed = errors.New("the collector is not reachable")
+	} else if verr != nil && cfg.Api.VersionCheck != "off" {
+		res.VersionError = verr.Error()
+		if cfg.Api.VersionCheck == "strict" {
+			failed = verr
+		}
+	}
+
+	file := res.ConfigFile
+	if file == "" {
+		file = "none, using env and flags"
+	}
+	version := res.CollectorVersion
+	if version == "" {
+		version = "unknown"
+	}
+	status := "ok"
+	if res.Error != "" {
+		status = res.Error
+	} else if res.VersionError != "" {
+		status = res.VersionError
+	}
+
+	err = a.print(*flags.output, res, []string{"CHECK", "RESULT"}, [][]string{
+		{"config", "ok"},
+		{"config file", file},
+		{"collector", res.Collector},
+		{"version", version},
+		{"latency", fmt.Sprintf("%vms", res.LatencyMs)},
+		{"status", status},
+	})
+	if err != nil {
+		return err
+	}
+
+	return failed
+}
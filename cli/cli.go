@@ -0,0 +1,290 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/uuid"
+
+	"github.com/jtom38/newsbot/portal/api"
+	"github.com/jtom38/newsbot/portal/services"
+)
+
+const (
+	ErrUsage = "invalid usage"
+
+	OutputTable = "table"
+	OutputJson  = "json"
+)
+
+// App runs the portal commands.
+// Output meant for scripts goes to Stdout, logs and errors go to Stderr.
+type App struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// This is a command like "serve" or "sources list".
+type command struct {
+	name  string
+	usage string
+	run   func(a App, ctx context.Context, args []string) error
+}
+
+func (a App) commands() []command {
+	return []command{
+		{name: "serve", usage: "serve the portal, this is the default", run: App.serve},
+		{name: "check", usage: "validate the config and check the collector can be reached", run: App.check},
+		{name: "healthcheck", usage: "check the running portal is up, for the Docker HEALTHCHECK", run: App.healthcheck},
+		{name: "sources", usage: "list, enable, disable or delete sources", run: App.sources},
+		{name: "webhooks", usage: "list, add, enable, disable or delete Discord web hooks", run: App.webhooks},
+		{name: "subscriptions", usage: "list, add or delete subscriptions", run: App.subscriptions},
+		{name: "apply", usage: "make the collector match a manifest of sources, web hooks and subscriptions", run: App.apply},
+		{name: "export", usage: "back up every source, web hook and subscription", run: App.export},
+		{name: "import", usage: "recreate the sources, web hooks and subscriptions from a backup", run: App.importBackup},
+		{name: "copy", usage: "copy sources, web hooks and subscriptions from one collector to another", run: App.copyCollector},
+		{name: "users", usage: "list, add, change the password or role of, or delete the users that can log in", run: App.users},
+	}
+}
+
+// This runs the command named by the first arg and returns the exit code.
+// When no command is given the portal is served, so `portal -listen :8080` keeps working.
+func (a App) Run(ctx context.Context, args []string) int {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" {
+		a.usage()
+		return 0
+	}
+
+	for _, cmd := range a.commands() {
+		if cmd.name != name {
+			continue
+		}
+
+		err := cmd.run(a, ctx, args)
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.Is(err, errUsage):
+			fmt.Fprintln(a.Stderr, err)
+			return 2
+		default:
+			fmt.Fprintln(a.Stderr, "error:", err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(a.Stderr, "unknown command '%v'\n\n", name)
+	a.usage()
+	return 2
+}
+
+var errUsage = errors.New(ErrUsage)
+
+// Returns an error that makes Run print the message and exit with 2.
+func usageError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %v", errUsage, fmt.Sprintf(format, args...))
+}
+
+func (a App) usage() {
+	fmt.Fprintln(a.Stderr, "Usage: portal <command> [flags]")
+	fmt.Fprintln(a.Stderr)
+	fmt.Fprintln(a.Stderr, "Commands:")
+	w := tabwriter.NewWriter(a.Stderr, 0, 4, 2, ' ', 0)
+	for _, cmd := range a.commands() {
+		fmt.Fprintf(w, "  %v\t%v\n", cmd.name, cmd.usage)
+	}
+	w.Flush()
+	fmt.Fprintln(a.Stderr)
+	fmt.Fprintln(a.Stderr, "Run 'portal <command> -h' to see the flags for a command.")
+}
+
+// This is what every command gets after its flags have been parsed.
+type commandFlags struct {
+	fs     *flag.FlagSet
+	config *services.ConfigFlags
+	output *string
+}
+
+// Creates a FlagSet for the command with the config flags already on it.
+// The output flag is only added for commands that print records.
+func (a App) newFlags(name string, output bool) commandFlags {
+	fs := flag.NewFlagSet("portal "+name, flag.ContinueOnError)
+	fs.SetOutput(a.Stderr)
+
+	f := commandFlags{
+		fs:     fs,
+		config: services.NewConfigFlags(fs),
+	}
+	if output {
+		f.output = fs.String("o", OutputTable, "output format, table or json")
+	}
+	return f
+}
+
+// This parses the args and loads the config.
+func (f commandFlags) parse(args []string) (services.Config, error) {
+	err := f.fs.Parse(args)
+	if err != nil {
+		return services.DefaultConfig(), err
+	}
+
+	if f.output != nil && *f.output != OutputTable && *f.output != OutputJson {
+		return services.DefaultConfig(), usageError("-o needs to be table or json")
+	}
+
+	cfg, err := f.config.Load()
+	if err != nil {
+		return cfg, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// Commands other than serve return their errors, so only log them when debugging.
+// This keeps the output easy to read and parse.
+func setupCommandLogger(a App, cfg services.Config) error {
+	level := cfg.Log.Level
+	if level != "debug" {
+		level = "error"
+	}
+
+	logger, err := services.NewLogger(a.Stderr, level, cfg.Log.Format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// Creates the api client the admin commands use.
+func newApiClient(cfg services.Config) api.CollectorApi {
+	return api.NewWithRestClient(cfg.Api.Address, api.NewRestClientWithOptions(RestClientOptions(cfg)))
+}
+
+// This converts the settings used by the RestClient when it talks to the API.
+func RestClientOptions(cfg services.Config) api.RestClientOptions {
+	opts := api.RestClientOptions{
+		CacheSize: cfg.Cache.Size,
+		Timeout:   cfg.Api.Timeout,
+		RateLimit: api.RateLimitOptions{
+			Global: api.RateLimit(cfg.Api.RateLimit),
+			Routes: make(map[string]api.RateLimit),
+		},
+	}
+
+	for prefix, limit := range cfg.Api.RateLimitRoutes {
+		opts.RateLimit.Routes[prefix] = api.RateLimit(limit)
+	}
+
+	return opts
+}
+
+// Writes the records as json, or as a table with the header and a row per record.
+func (a App) print(output string, records interface{}, header []string, rows [][]string) error {
+	if output == OutputJson {
+		enc := json.NewEncoder(a.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(records)
+	}
+
+	w := tabwriter.NewWriter(a.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// Runs the action named by the first arg, used by commands like "sources list".
+func (a App) runAction(ctx context.Context, group string, args []string, actions map[string]func(a App, ctx context.Context, args []string) error) error {
+	var names []string
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return usageError("portal %v <%v> [flags]", group, strings.Join(names, "|"))
+	}
+
+	action, ok := actions[args[0]]
+	if !ok {
+		return usageError("unknown action '%v', expected one of %v", args[0], strings.Join(names, ", "))
+	}
+	return action(a, ctx, args[1:])
+}
+
+// Parses the IDs given after the flags.
+func parseIDs(args []string) ([]uuid.UUID, error) {
+	if len(args) == 0 {
+		return nil, usageError("at least one ID is required")
+	}
+
+	var ids []uuid.UUID
+	for _, arg := range args {
+		id, err := uuid.Parse(arg)
+		if err != nil {
+			return nil, usageError("'%v' is not a valid ID", arg)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// An api call that changes a single record, like SourcesApi.Enable.
+type idAction func(ctx context.Context, id uuid.UUID) error
+
+// This is the shared flow for commands like "sources enable <id>...".
+// Every ID is tried and reported on, the errors are returned together at the end.
+func (a App) changeByID(ctx context.Context, name string, verb string, args []string, pick func(c api.CollectorApi) idAction) error {
+	flags := a.newFlags(name, false)
+	cfg, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+
+	ids, err := parseIDs(flags.fs.Args())
+	if err != nil {
+		return err
+	}
+
+	err = setupCommandLogger(a, cfg)
+	if err != nil {
+		return err
+	}
+
+	action := pick(newApiClient(cfg))
+
+	var errs []error
+	for _, id := range ids {
+		err := action(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", id, err))
+			continue
+		}
+		fmt.Fprintf(a.Stdout, "%v %v\n", verb, id)
+	}
+	return errors.Join(errs...)
+}
+
+// The default App writes to the standard streams.
+func Default() App {
+	return App{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+}
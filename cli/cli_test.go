@@ -0,0 +1,133 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jtom38/newsbot/portal/api"
+	"github.com/jtom38/newsbot/portal/cli"
+)
+
+const sourceID = "6f7e4f5b-8c1d-4f57-9a53-0cbbd2a8d4a1"
+
+func newCollector(t *testing.T) (*httptest.Server, *[]string) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/api/sources":
+			w.Write([]byte(`{"status":200,"payload":[{"id":"` + sourceID + `","source":"reddit","name":"golang","enabled":true,"tags":["go"]}]}`))
+		default:
+			w.Write([]byte(`{"status":200}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func run(t *testing.T, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	app := cli.App{Stdout: &stdout, Stderr: &stderr}
+	code := app.Run(context.Background(), args)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestSourcesList(t *testing.T) {
+	srv, _ := newCollector(t)
+
+	code, stdout, stderr := run(t, "sources", "list", "-api-address", srv.URL, "-o", "json")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %v: %v", code, stderr)
+	}
+
+	var items []api.Source
+	err := json.Unmarshal([]byte(stdout), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "golang" {
+		t.Errorf("unexpected sources, %v", items)
+	}
+
+	code, stdout, _ = run(t, "sources", "list", "-api-address", srv.URL)
+	if code != 0 || !strings.Contains(stdout, "golang") || !strings.HasPrefix(stdout, "ID") {
+		t.Errorf("expected a table with the source, got %v", stdout)
+	}
+}
+
+func TestSourcesEnable(t *testing.T) {
+	srv, calls := newCollector(t)
+
+	code, stdout, stderr := run(t, "sources", "enable", "-api-address", srv.URL, sourceID)
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %v: %v", code, stderr)
+	}
+
+	want := "POST /api/sources/" + sourceID + "/enable"
+	if len(*calls) != 1 || (*calls)[0] != want {
+		t.Errorf("expected %v, got %v", want, *calls)
+	}
+	if !strings.Contains(stdout, "enabled "+sourceID) {
+		t.Errorf("expected the change to be reported, got %v", stdout)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	code, _, stderr := run(t, "sources", "enable", "-api-address", "http://localhost:8081", "not-an-id")
+	if code != 2 || !strings.Contains(stderr, "not a valid ID") {
+		t.Errorf("expected a usage error, got %v: %v", code, stderr)
+	}
+
+	code, _, _ = run(t, "nope")
+	if code != 2 {
+		t.Errorf("expected unknown commands to exit with 2, got %v", code)
+	}
+}
+
+func TestUsersAdd(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "users.json")
+
+	var stdout, stderr bytes.Buffer
+	app := cli.App{Stdin: strings.NewReader("correct horse\n"), Stdout: &stdout, Stderr: &stderr}
+	code := app.Run(context.Background(), []string{"users", "add", "-api-address", "http://localhost:8081", "-users-file", file, "-role", "editor", "jamie"})
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %v: %v", code, stderr.String())
+	}
+
+	code, listed, _ := run(t, "users", "list", "-api-address", "http://localhost:8081", "-users-file", file)
+	if code != 0 || !strings.Contains(listed, "jamie") || !strings.Contains(listed, "editor") || strings.Contains(listed, "correct horse") {
+		t.Errorf("expected a table with the user, got %v", listed)
+	}
+}
+
+func TestHealthcheck(t *testing.T) {
+	collector, _ := newCollector(t)
+	portal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer portal.Close()
+
+	// The portal listens on every interface, the check still finds it on loopback.
+	_, port, _ := net.SplitHostPort(portal.Listener.Addr().String())
+	code, stdout, stderr := run(t, "healthcheck", "-api-address", collector.URL, "-listen", ":"+port)
+	if code != 0 || strings.TrimSpace(stdout) != "ok" {
+		t.Errorf("expected the portal to be healthy, got %v: %v", code, stderr)
+	}
+
+	portal.Close()
+	code, _, stderr = run(t, "healthcheck", "-api-address", collector.URL, "-listen", ":"+port)
+	if code != 1 || !strings.Contains(stderr, "not responding") {
+		t.Errorf("expected a portal that is down to fail the check, got %v: %v", code, stderr)
+	}
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jtom38/newsbot/portal/admin"
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+// portal copy -from <url> -to <url> [-source reddit,youtube] [-dry-run] [-o json]
+//
+// Copies sources, Discord web hooks and subscriptions from one collector to another.
+// Records the target already has are left alone, and how they differ is reported as a conflict.
+func (a App) copyCollector(ctx context.Context, args []string) error {
+	flags := a.newFlags("copy", true)
+	flags.fs.Func("from", "the collector to copy from, the same as -api-address", func(value string) error {
+		return flags.fs.Set("api-address", value)
+	})
+	to := flags.fs.String("to", "", "the collector to copy to")
+	sources := flags.fs.String("source", "", "only copy sources from these sites, like reddit,youtube")
+	dryRun := flags.fs.Bool("dry-run", false, "only print what would be copied")
+	cfg, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *to == "" {
+		return usageError("-to is required")
+	}
+	from := cfg.Api.Address
+	if strings.TrimRight(from, "/") == strings.TrimRight(*to, "/") {
+		return usageError("-from and -to need to be different collectors")
+	}
+
+	var sites []string
+	for _, site := range strings.Split(*sources, ",") {
+		site = strings.TrimSpace(site)
+		if site != "" {
+			sites = append(sites, site)
+		}
+	}
+
+	err = setupCommandLogger(a, cfg)
+	if err != nil {
+		return err
+	}
+
+	// Each collector gets its own RestClient so the caches and rate limits are kept apart.
+	opts := RestClientOptions(cfg)
+	source := api.NewWithRestClient(from, api.NewRestClientWithOptions(opts))
+	target := api.NewWithRestClient(*to, api.NewRestClientWithOptions(opts))
+
+	res, failed := admin.Copy(ctx, source, target, admin.CopyOptions{
+		Sources: sites,
+		DryRun:  *dryRun,
+	})
+	return a.printImport(*flags.output, res, failed)
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/jtom38/newsbot/portal/services"
+)
+
+const (
+	// How long the portal has to answer the health check.
+	healthcheckTimeout = 5 * time.Second
+)
+
+// portal healthcheck
+//
+// Asks the portal running with the same config if it is up, this is what the Docker HEALTHCHECK runs.
+// It follows the listen address, unix socket and TLS settings, so the check keeps working when they change.
+func (a App) healthcheck(ctx context.Context, args []string) error {
+	flags := a.newFlags("healthcheck", false)
+	cfg, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+
+	err = setupCommandLogger(a, cfg)
+	if err != nil {
+		return err
+	}
+
+	client, uri, err := healthcheckClient(cfg.Server)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthcheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("the portal is not responding: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("the portal is not healthy, /healthz returned %v", res.Status)
+	}
+
+	fmt.Fprintln(a.Stdout, "ok")
+	return nil
+}
+
+// Returns a client and the /healthz url for the address the portal listens on.
+// The health routes are always at the root, so the base path is not needed.
+func healthcheckClient(cfg services.ServerConfig) (*http.Client, string, error) {
+	transport := &http.Transport{}
+
+	scheme := "http"
+	if cfg.TLS.Enabled() {
+		// The certificate is for the name people use, not localhost, and all we want to know is that the portal answers.
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		scheme = "https"
+	}
+
+	if path, ok := cfg.UnixSocket(); ok {
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+		return &http.Client{Transport: transport}, scheme + "://portal/healthz", nil
+	}
+
+	host, port, err := net.SplitHostPort(cfg.Address)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to read the listen address: %w", err)
+	}
+
+	// A portal listening on every interface can be reached on loopback.
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+
+	uri := fmt.Sprintf("%v://%v/healthz", scheme, net.JoinHostPort(host, port))
+	return &http.Client{Transport: transport}, uri, nil
+}
@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"github.com/jtom38/newsbot/portal/services"
+	"github.com/jtom38/newsbot/portal/web"
+)
+
+// portal serve
+//
+// This runs the portal until ctx is done.
+func (a App) serve(ctx context.Context, args []string) error {
+	flags := a.newFlags("serve", false)
+	cfg, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+
+	logger, err := services.NewLogger(a.Stderr, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	if cfg.File != "" {
+		slog.Info("loaded the config file", "path", cfg.File)
+	}
+
+	shutdownTracing, err := services.SetupTracing(ctx, cfg.Tracing.Exporter)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// ctx is done by now, so give the exporter its own deadline to flush the last spans.
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownTracing(flushCtx)
+	}()
+
+	features := services.NewFeatureFlags(cfg.Features)
+
+	proxies, err := services.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		return err
+	}
+
+	users, err := openUsers(cfg.Auth)
+	if err != nil {
+		return err
+	}
+	if users != nil {
+		go users.Watch(ctx, services.DefaultConfigPollInterval)
+	}
+
+	server := web.NewServer(ctx, web.ServerOptions{
+		ApiEndpoint:    cfg.Api.Address,
+		Rest:           RestClientOptions(cfg),
+		Features:       features,
+		BasePath:       cfg.Server.BasePath,
+		TrustedProxies: proxies,
+		Users:          users,
+		OIDC:           cfg.Auth.OIDC,
+		SessionTimeout: cfg.Auth.SessionTimeout,
+	})
+
+	err = checkCollector(ctx, cfg, server)
+	if err != nil {
+		return err
+	}
+
+	watcher := services.NewConfigWatcher(cfg, args)
+	watcher.Subscribe(func(old services.Config, new services.Config) {
+		applyConfig(old, new, server, features)
+	})
+	go watcher.Watch(ctx, services.DefaultConfigPollInterval)
+
+	return server.ListenAndServe(ctx, cfg.Server)
+}
+
+// This makes sure the collector is up and running a version the portal can work with.
+func checkCollector(ctx context.Context, cfg services.Config, server *web.HttpServer) error {
+	wait := cfg.Api.WaitTimeout
+	policy := cfg.Api.VersionCheck
+
+	state, err := server.CheckCollector(ctx, wait)
+	if err != nil {
+		// When we were asked to wait, the collector is required to start.
+		if wait > 0 {
+			return err
+		}
+		slog.Warn("unable to reach the collector, pages will fail until it is up", "address", state.Address, "error", err)
+		return nil
+	}
+
+	if state.VersionError == nil || policy == "off" {
+		return nil
+	}
+
+	if policy == "strict" {
+		return fmt.Errorf("refusing to start: %w", state.VersionError)
+	}
+	slog.Warn("the collector version is not supported", "error", state.VersionError)
+
+	return nil
+}
+
+// Returns the users that can log in with a password, or nil when there is no users file.
+// The admin from the config is created when there is nobody who could log in yet.
+func openUsers(cfg services.AuthConfig) (*services.UserStore, error) {
+	if !cfg.Enabled() {
+		slog.Warn("logins are turned off, anyone who can reach the portal can change the settings", "setting", services.Config_Auth_UsersFile)
+		return nil, nil
+	}
+	if cfg.UsersFile == "" {
+		return nil, nil
+	}
+
+	users, err := services.OpenUserStore(cfg.UsersFile)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := users.Bootstrap(cfg.AdminUser, cfg.AdminPassword)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		slog.Info("created the admin user, the password can now be removed from the config", "user", cfg.AdminUser, "path", cfg.UsersFile)
+	}
+	return users, nil
+}
+
+// This passes a reloaded config on to the parts of the portal that can change while running.
+func applyConfig(old services.Config, new services.Config, server *web.HttpServer, features *services.FeatureFlags) {
+	err := services.SetLogLevel(new.Log.Level)
+	if err != nil {
+		slog.Error("unable to change the log level", "error", err)
+	}
+
+	server.ReconfigureApi(RestClientOptions(new))
+
+	// Responses cached under the old settings should not be served back under the new ones.
+	if old.Cache != new.Cache || old.Api.Address != new.Api.Address {
+		slog.Info("cleared the collector response cache", "responses", server.PurgeApiCache())
+	}
+
+	for _, name := range features.Refresh(new.Features) {
+		slog.Info("feature flag changed", "feature", name, "enabled", features.Enabled(name))
+	}
+
+	if !reflect.DeepEqual(old.Server, new.Server) || old.Api.Address != new.Api.Address || old.Log.Format != new.Log.Format || old.Tracing != new.Tracing || !reflect.DeepEqual(old.Auth, new.Auth) {
+		slog.Warn("the server settings, api address, log format, tracing exporter and logins only change after a restart")
+	}
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+// portal sources <list|enable|disable|delete>
+func (a App) sources(ctx context.Context, args []string) error {
+	return a.runAction(ctx, "sources", args, map[string]func(a App, ctx context.Context, args []string) error{
+		"list":    App.listSources,
+		"enable":  App.enableSources,
+		"disable": App.disableSources,
+		"delete":  App.deleteSources,
+	})
+}
+
+// portal sources list [-source reddit] [-o json]
+func (a App) listSources(ctx context.Context, args []string) error {
+	flags := a.newFlags("sources list", true)
+	source := flags.fs.String("source", "", "only list sources from this site, like reddit or youtube")
+	cfg, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+
+	err = setupCommandLogger(a, cfg)
+	if err != nil {
+		return err
+	}
+
+	client := newApiClient(cfg)
+
+	var items *[]api.Source
+	if *source != "" {
+		items, err = client.Sources().ListBySource(ctx, *source)
+	} else {
+		items, err = client.Sources().List(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	records := []api.Source{}
+	if items != nil {
+		records = *items
+	}
+
+	var rows [][]string
+	for _, item := range records {
+		rows = append(rows, []string{
+			item.ID.String(),
+			item.Source,
+			item.Name,
+			fmt.Sprint(item.Enabled),
+			strings.Join(item.Tags, ","),
+			item.Url,
+		})
+	}
+
+	return a.print(*flags.output, records, []string{"ID", "SOURCE", "NAME", "ENABLED", "TAGS", "URL"}, rows)
+}
+
+// portal sources enable <id>...
+func (a App) enableSources(ctx context.Context, args []string) error {
+	return a.changeByID(ctx, "sources enable", "enabled", args, func(c api.CollectorApi) idAction {
+		return c.Sources().Enable
+	})
+}
+
+// portal sources disable <id>...
+func (a App) disableSources(ctx context.Context, args []string) error {
+	return a.changeByID(ctx, "sources disable", "disabled", args, func(c api.CollectorApi) idAction {
+		return c.Sources().Disable
+	})
+}
+
+// portal sources delete <id>...
+func (a App) deleteSources(ctx context.Context, args []string) error {
+	return a.changeByID(ctx, "sources delete", "deleted", args, func(c api.CollectorApi) idAction {
+		return c.Sources().Delete
+	})
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+// portal subscriptions <list|add|delete>
+func (a App) subscriptions(ctx context.Context, args []string) error {
+	return a.runAction(ctx, "subscriptions", args, map[string]func(a App, ctx context.Context, args []string) error{
+		"list":   App.listSubscriptions,
+		"add":    App.addSubscription,
+		"delete": App.deleteSubscriptions,
+	})
+}
+
+// portal subscriptions list [-webhook <id>] [-source <id>] [-o json]
+func (a App) listSubscriptions(ctx context.Context, args []string) error {
+	flags := a.newFlags("subscriptions list", true)
+	webhook := flags.fs.String("webhook", "", "only list subscriptions for this Discord web hook ID")
+	source := flags.fs.String("source", "", "only list subscriptions for this source ID")
+	cfg, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+
+	err = setupCommandLogger(a, cfg)
+	if err != nil {
+		return err
+	}
+
+	client := newApiClient(cfg)
+
+	var records []api.Subscription
+	switch {
+	case *webhook != "":
+		id, err := uuid.Parse(*webhook)
+		if err != nil {
+			return usageError("-webhook '%v' is not a valid ID", *webhook)
+		}
+		items, err := client.Subscriptions().GetByDiscordID(ctx, id)
+		if err != nil {
+			return err
+		}
+		records = derefSubscriptions(items)
+	case *source != "":
+		id, err := uuid.Parse(*source)
+		if err != nil {
+			return usageError("-source '%v' is not a valid ID", *source)
+		}
+		items, err := client.Subscriptions().GetBySourceID(ctx, id)
+		if err != nil {
+			return err
+		}
+		records = derefSubscriptions(items)
+	default:
+		records, err = client.Subscriptions().List(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if records == nil {
+		records = []api.Subscription{}
+	}
+
+	var rows [][]string
+	for _, item := range records {
+		rows = append(rows, []string{
+			item.ID.String(),
+			item.DiscordWebhookId.String(),
+			item.SourceId.String(),
+		})
+	}
+
+	return a.print(*flags.output, records, []string{"ID", "WEBHOOK", "SOURCE"}, rows)
+}
+
+func derefSubscriptions(items *[]api.Subscription) []api.Subscription {
+	if items == nil {
+		return nil
+	}
+	return *items
+}
+
+// portal subscriptions add -webhook <id> -source <id>
+func (a App) addSubscription(ctx context.Context, args []string) error {
+	flags := a.newFlags("subscriptions add", false)
+	webhook := flags.fs.String("webhook", "", "ID of the Discord web hook to send to")
+	source := flags.fs.String("source", "", "ID of the source to send articles from")
+	cfg, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+
+	webhookID, err := uuid.Parse(*webhook)
+	if err != nil {
+		return usageError("-webhook needs to be a valid ID")
+	}
+	sourceID, err := uuid.Parse(*source)
+	if err != nil {
+		return usageError("-source needs to be a valid ID")
+	}
+
+	err = setupCommandLogger(a, cfg)
+	if err != nil {
+		return err
+	}
+
+	err = newApiClient(cfg).Subscriptions().New(ctx, webhookID, sourceID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.Stdout, "subscribed %v to %v\n", webhookID, sourceID)
+	return nil
+}
+
+// portal subscriptions delete <id>...
+func (a App) deleteSubscriptions(ctx context.Context, args []string) error {
+	return a.changeByID(ctx, "subscriptions delete", "deleted", args, func(c api.CollectorApi) idAction {
+		return c.Subscriptions().Delete
+	})
+}
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jtom38/newsbot/portal/services"
+)
+
+// portal users <list|add|passwd|role|delete>
+//
+// These change the users file directly, a running portal has to be restarted to see the changes.
+func (a App) users(ctx context.Context, args []string) error {
+	return a.runAction(ctx, "users", args, map[string]func(a App, ctx context.Context, args []string) error{
+		"list":   App.listUsers,
+		"add":    App.addUser,
+		"passwd": App.setUserPassword,
+		"role":   App.setUserRole,
+		"delete": App.deleteUsers,
+	})
+}
+
+// This parses the flags and opens the users file from the config.
+func (a App) openUserStore(flags commandFlags, args []string) (*services.UserStore, error) {
+	cfg, err := flags.parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Auth.UsersFile == "" {
+		return nil, usageError("there is no users file, set auth.usersFile or -users-file")
+	}
+
+	err = setupCommandLogger(a, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return services.OpenUserStore(cfg.Auth.UsersFile)
+}
+
+// The password is read from stdin so it does not end up in the shell history.
+func (a App) readPassword() (string, error) {
+	line, err := bufio.NewReader(a.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.New("expected the password on stdin")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// portal users list [-o json]
+func (a App) listUsers(ctx context.Context, args []string) error {
+	flags := a.newFlags("users list", true)
+	store, err := a.openUserStore(flags, args)
+	if err != nil {
+		return err
+	}
+
+	type record struct {
+		Name      string        `json:"name"`
+		Role      services.Role `json:"role"`
+		CreatedAt time.Time     `json:"createdAt"`
+	}
+
+	records := []record{}
+	var rows [][]string
+	for _, user := range store.List() {
+		records = append(records, record{Name: user.Name, Role: user.Role, CreatedAt: user.CreatedAt})
+		rows = append(rows, []string{user.Name, string(user.Role), user.CreatedAt.Format(time.RFC3339)})
+	}
+
+	return a.print(*flags.output, records, []string{"NAME", "ROLE", "CREATED"}, rows)
+}
+
+// portal users add [-role editor] <name> < password
+func (a App) addUser(ctx context.Context, args []string) error {
+	flags := a.newFlags("users add", false)
+	role := flags.fs.String("role", string(services.RoleViewer), "viewer, editor or admin")
+	store, err := a.openUserStore(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if flags.fs.NArg() != 1 {
+		return usageError("portal users add <name>, the password is read from stdin")
+	}
+	name := flags.fs.Arg(0)
+
+	parsed, err := services.ParseRole(*role)
+	if err != nil {
+		return usageError("-role: %v", err)
+	}
+
+	password, err := a.readPassword()
+	if err != nil {
+		return err
+	}
+
+	err = store.Add(name, password, parsed)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.Stdout, "added %v as %v\n", name, parsed)
+	return nil
+}
+
+// portal users passwd <name> < password
+func (a App) setUserPassword(ctx context.Context, args []string) error {
+	flags := a.newFlags("users passwd", false)
+	store, err := a.openUserStore(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if flags.fs.NArg() != 1 {
+		return usageError("portal users passwd <name>, the password is read from stdin")
+	}
+	name := flags.fs.Arg(0)
+
+	password, err := a.readPassword()
+	if err != nil {
+		return err
+	}
+
+	err = store.SetPassword(name, password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.Stdout, "changed the password for %v\n", name)
+	return nil
+}
+
+// portal users role <name> <viewer|editor|admin>
+func (a App) setUserRole(ctx context.Context, args []string) error {
+	flags := a.newFlags("users role", false)
+	store, err := a.openUserStore(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if flags.fs.NArg() != 2 {
+		return usageError("portal users role <name> <viewer|editor|admin>")
+	}
+	name := flags.fs.Arg(0)
+
+	role, err := services.ParseRole(flags.fs.Arg(1))
+	if err != nil {
+		return usageError("%v", err)
+	}
+
+	err = store.SetRole(name, role)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.Stdout, "%v is now %v\n", name, role)
+	return nil
+}
+
+// portal users delete <name>...
+func (a App) deleteUsers(ctx context.Context, args []string) error {
+	flags := a.newFlags("users delete", false)
+	store, err := a.openUserStore(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if flags.fs.NArg() == 0 {
+		return usageError("at least one user name is required")
+	}
+
+	var errs []error
+	for _, name := range flags.fs.Args() {
+		err := store.Delete(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", name, err))
+			continue
+		}
+		fmt.Fprintf(a.Stdout, "deleted %v\n", name)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+// portal webhooks <list|add|enable|disable|delete>
+func (a App) webhooks(ctx context.Context, args []string) error {
+	return a.runAction(ctx, "webhooks", args, map[string]func(a App, ctx context.Context, args []string) error{
+		"list":    App.listWebhooks,
+		"add":     App.addWebhook,
+		"enable":  App.enableWebhooks,
+		"disable": App.disableWebhooks,
+		"delete":  App.deleteWebhooks,
+	})
+}
+
+// portal webhooks list [-o json]
+func (a App) listWebhooks(ctx context.Context, args []string) error {
+	flags := a.newFlags("webhooks list", true)
+	cfg, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+
+	err = setupCommandLogger(a, cfg)
+	if err != nil {
+		return err
+	}
+
+	items, err := newApiClient(cfg).Outputs().DiscordWebHook().List(ctx)
+	if err != nil {
+		return err
+	}
+
+	records := []api.DiscordWebHooks{}
+	if items != nil {
+		records = *items
+	}
+
+	var rows [][]string
+	for _, item := range records {
+		rows = append(rows, []string{
+			item.ID.String(),
+			item.Server,
+			item.Channel,
+			fmt.Sprint(item.Enabled),
+		})
+	}
+
+	return a.print(*flags.output, records, []string{"ID", "SERVER", "CHANNEL", "ENABLED"}, rows)
+}
+
+// portal webhooks add -server <name> -channel <name> -url <url>
+func (a App) addWebhook(ctx context.Context, args []string) error {
+	flags := a.newFlags("webhooks add", false)
+	server := flags.fs.String("server", "", "name of the Discord server")
+	channel := flags.fs.String("channel", "", "name of the channel in the server")
+	url := flags.fs.String("url", "", "the web hook url Discord gave you")
+	cfg, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *server == "" || *channel == "" || *url == "" {
+		return usageError("-server, -channel and -url are required")
+	}
+
+	err = setupCommandLogger(a, cfg)
+	if err != nil {
+		return err
+	}
+
+	err = newApiClient(cfg).Outputs().DiscordWebHook().New(ctx, *server, *channel, *url)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.Stdout, "added %v/%v\n", *server, *channel)
+	return nil
+}
+
+// portal webhooks enable <id>...
+func (a App) enableWebhooks(ctx context.Context, args []string) error {
+	return a.changeByID(ctx, "webhooks enable", "enabled", args, func(c api.CollectorApi) idAction {
+		return c.Outputs().DiscordWebHook().Enable
+	})
+}
+
+// portal webhooks disable <id>...
+func (a App) disableWebhooks(ctx context.Context, args []string) error {
+	return a.changeByID(ctx, "webhooks disable", "disabled", args, func(c api.CollectorApi) idAction {
+		return c.Outputs().DiscordWebHook().Disable
+	})
+}
+
+// portal webhooks delete <id>...
+func (a App) deleteWebhooks(ctx context.Context, args []string) error {
+	return a.changeByID(ctx, "webhooks delete", "deleted", args, func(c api.CollectorApi) idAction {
+		return c.Outputs().DiscordWebHook().Delete
+	})
+}
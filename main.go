@@ -2,26 +2,18 @@ package main
 
 import (
 	"context"
-	"log"
-	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
-	//"github.com/jtom38/newsbot/portal/routes"
-	"github.com/jtom38/newsbot/portal/services"
-	"github.com/jtom38/newsbot/portal/web"
+	"github.com/jtom38/newsbot/portal/cli"
 )
 
 func main() {
-	ctx := context.Background()
+	// Stopping the portal cancels ctx, which lets running requests finish before it exits.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := cli.Default().Run(ctx, os.Args[1:])
+	stop()
 
-	c := services.NewConfigClient()
-	apiAddress := c.MustGet(services.Config_API_Address)
-
-	//server := routes.NewServer(&ctx, apiAddress)
-	server := web.NewServer(ctx, apiAddress)
-
-	log.Print("Starting portal on http://localhost:8080")
-	err := http.ListenAndServe(":8080", server.Router)
-	if err != nil {
-		panic(err)
-	}
+	os.Exit(code)
 }
@@ -3,15 +3,102 @@ package services
 import (
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/joho/godotenv"
 )
 
 const (
+	// The env file that is loaded from the working directory.
+	DotEnvFile = ".env"
+
+	// The path to the yaml config file.
+	Config_File = "CONFIG_FILE"
+
+	// The address the portal listens on, like ":8080" or "unix:/run/portal.sock".
+	Config_Listen_Address = "LISTEN_ADDRESS"
+
+	// Timeouts for the http server, like "15s".
+	Config_Server_ReadTimeout  = "SERVER_READ_TIMEOUT"
+	Config_Server_WriteTimeout = "SERVER_WRITE_TIMEOUT"
+	Config_Server_IdleTimeout  = "SERVER_IDLE_TIMEOUT"
+
+	// How long running requests get to finish when the portal is stopped, like "30s".
+	Config_Server_ShutdownTimeout = "SERVER_SHUTDOWN_TIMEOUT"
+
+	// The path the portal is served under behind a reverse proxy, like "/newsbot".
+	Config_Server_BasePath = "BASE_PATH"
+
+	// Proxies allowed to set the X-Forwarded headers, like "10.0.0.0/8,127.0.0.1".
+	Config_Server_TrustedProxies = "TRUSTED_PROXIES"
+
+	// The certificate and key used to serve HTTPS.
+	Config_TLS_CertFile = "TLS_CERT_FILE"
+	Config_TLS_KeyFile  = "TLS_KEY_FILE"
+
 	Config_API_Address = "API_ADDRESS"
+
+	// How long a single request to the API can take, like "30s".
+	Config_API_Timeout = "API_TIMEOUT"
+
+	// How many API responses are kept for conditional requests, 0 turns the cache off.
+	Config_API_CacheSize = "API_CACHE_SIZE"
+
+	// Requests per second and burst size for all calls made to the API.
+	Config_API_RateLimit = "API_RATE_LIMIT"
+	Config_API_RateBurst = "API_RATE_BURST"
+
+	// Per route limits, like "/api/sources=5:10,/api/articles=20:40".
+	Config_API_RateLimitRoutes = "API_RATE_LIMIT_ROUTES"
+
+	// How the portal reacts when the collector version is not supported, "warn", "strict" or "off".
+	Config_API_VersionCheck = "API_VERSION_CHECK"
+
+	// How long to wait for the collector to come up before serving pages, like "30s".
+	Config_API_WaitTimeout = "API_WAIT_TIMEOUT"
+
+	// Features to turn on or off, like "subscriptions=true,cards=false".
+	Config_Features = "FEATURES"
+
+	// Where to send spans, "none", "stdout" or "otlp".
+	Config_Tracing_Exporter = "TRACING_EXPORTER"
+
+	// The lowest level that gets logged, "debug", "info", "warn" or "error".
+	Config_Log_Level = "LOG_LEVEL"
+
+	// How log lines are written, "text" or "json".
+	Config_Log_Format = "LOG_FORMAT"
+
+	// The json file with the users that can log in to the settings pages.
+	Config_Auth_UsersFile = "AUTH_USERS_FILE"
+
+	// The user created when the users file is empty.
+	Config_Auth_AdminUser     = "AUTH_ADMIN_USER"
+	Config_Auth_AdminPassword = "AUTH_ADMIN_PASSWORD"
+
+	// How long a login lasts, like "12h".
+	Config_Auth_SessionTimeout = "AUTH_SESSION_TIMEOUT"
+
+	// The OpenID Connect provider to log in with and the client the portal has with it.
+	Config_OIDC_Issuer       = "AUTH_OIDC_ISSUER"
+	Config_OIDC_ClientID     = "AUTH_OIDC_CLIENT_ID"
+	Config_OIDC_ClientSecret = "AUTH_OIDC_CLIENT_SECRET"
+	Config_OIDC_RedirectURL  = "AUTH_OIDC_REDIRECT_URL"
+
+	// Scopes to ask for, like "openid,profile,email,groups".
+	Config_OIDC_Scopes = "AUTH_OIDC_SCOPES"
+
+	// The ID token claim with the groups, and the role for each group like "portal-admins=admin".
+	Config_OIDC_GroupsClaim = "AUTH_OIDC_GROUPS_CLAIM"
+	Config_OIDC_Groups      = "AUTH_OIDC_GROUPS"
+	Config_OIDC_DefaultRole = "AUTH_OIDC_DEFAULT_ROLE"
+
+	// The name of the provider on the login button.
+	Config_OIDC_Name = "AUTH_OIDC_NAME"
 )
 
 type ConfigClient struct{}
@@ -37,7 +124,16 @@ func (c ConfigClient) MustGet(key string) string {
 func (cc *ConfigClient) Get(key string) string {
 	res, filled := os.LookupEnv(key)
 	if !filled {
-		log.Printf("Missing the a value for '%v'.  Could generate errors.", key)
+		slog.Warn("missing a value, could generate errors", "key", key)
+	}
+	return res
+}
+
+// This returns the value for the key or the fallback when the key has not been set.
+func (cc *ConfigClient) GetOrDefault(key string, fallback string) string {
+	res, filled := os.LookupEnv(key)
+	if !filled {
+		return fallback
 	}
 	return res
 }
@@ -59,22 +155,62 @@ func (cc *ConfigClient) GetFeature(flag string) (bool, error) {
 }
 
 // Use this when your ConfigClient has been opened for awhile and you want to ensure you have the most recent env changes.
+// If the .env file can not be read the values from the last good read are kept.
 func (cc *ConfigClient) RefreshEnv() {
-	// Check to see if we have the env file on the system
-	_, err := os.Stat(".env")
-
-	// We have the file, load it.
-	if err == nil {
-		_, err := os.Open(".env")
-		if err == nil {
-			loadEnvFile()
+	err := loadEnvFile()
+	if err != nil {
+		slog.Warn("unable to load the .env file, keeping the previous values", "error", err)
+	}
+}
+
+var (
+	envMu sync.Mutex
+
+	// Keys that were set before the portal started, the .env file will not replace these.
+	processEnv = envKeys()
+
+	// Keys that were set by the .env file the last time it was loaded.
+	dotEnvKeys = map[string]bool{}
+)
+
+// This loads the .env file if one exists.
+// Unlike godotenv.Load, a value that changed in the file replaces the one we loaded before
+// and a key that was removed from the file is unset.
+func loadEnvFile() error {
+	envMu.Lock()
+	defer envMu.Unlock()
+
+	values, err := godotenv.Read(DotEnvFile)
+	if errors.Is(err, os.ErrNotExist) {
+		values = map[string]string{}
+	} else if err != nil {
+		return err
+	}
+
+	for key := range dotEnvKeys {
+		if _, ok := values[key]; !ok {
+			os.Unsetenv(key)
 		}
 	}
+
+	loaded := make(map[string]bool)
+	for key, value := range values {
+		if processEnv[key] {
+			continue
+		}
+		os.Setenv(key, value)
+		loaded[key] = true
+	}
+	dotEnvKeys = loaded
+
+	return nil
 }
 
-func loadEnvFile() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatalln(err)
+func envKeys() map[string]bool {
+	keys := make(map[string]bool)
+	for _, item := range os.Environ() {
+		key, _, _ := strings.Cut(item, "=")
+		keys[key] = true
 	}
+	return keys
 }
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	Feature_RedditSources  = "reddit"
+	Feature_YoutubeSources = "youtube"
+	Feature_TwitchSources  = "twitch"
+	Feature_FfxivSources   = "ffxiv"
+	Feature_Subscriptions  = "subscriptions"
+	Feature_CardView       = "cards"
+
+	// Flags can be set one at a time with env vars that start with this, like FEATURE_TWITCH=false.
+	FeatureEnvPrefix = "FEATURE_"
+
+	// Where the value of a flag came from.
+	FeatureSourceDefault = "default"
+	FeatureSourceConfig  = "config"
+	FeatureSourceEnv     = "env"
+)
+
+// Feature describes a part of the portal that can be turned on or off.
+type Feature struct {
+	Name        string
+	Description string
+	Default     bool
+}
+
+// Every feature the portal knows about.
+var KnownFeatures = []Feature{
+	{Name: Feature_RedditSources, Description: "Manage Reddit sources", Default: true},
+	{Name: Feature_YoutubeSources, Description: "Manage YouTube sources", Default: true},
+	{Name: Feature_TwitchSources, Description: "Manage Twitch sources", Default: true},
+	{Name: Feature_FfxivSources, Description: "List Final Fantasy XIV sources", Default: true},
+	{Name: Feature_Subscriptions, Description: "Manage Discord web hook subscriptions", Default: true},
+	{Name: Feature_CardView, Description: "Show articles as cards", Default: true},
+}
+
+// This is the current value of a flag and where it came from.
+type FeatureState struct {
+	Feature
+	Enabled bool
+	Source  string
+}
+
+// FeatureFlags holds the current value of every feature.
+// The values can be replaced while pages are being served.
+type FeatureFlags struct {
+	mu     sync.RWMutex
+	config ConfigClient
+	states map[string]FeatureState
+}
+
+// Creates the flags from the features in the config.
+// A FEATURE_<NAME> env var will override what the config has.
+func NewFeatureFlags(features map[string]bool) *FeatureFlags {
+	f := FeatureFlags{
+		config: ConfigClient{},
+	}
+	f.Refresh(features)
+	return &f
+}
+
+// Returns true when the feature is turned on.
+// Features that are not known are always off.
+func (f *FeatureFlags) Enabled(name string) bool {
+	if f == nil {
+		return false
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.states[name].Enabled
+}
+
+// Returns every known flag sorted by name.
+func (f *FeatureFlags) List() []FeatureState {
+	if f == nil {
+		return nil
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var items []FeatureState
+	for _, item := range f.states {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+	return items
+}
+
+// This works out the value of every flag again from the config and the env.
+// Returns the names of the flags that changed.
+func (f *FeatureFlags) Refresh(features map[string]bool) []string {
+	states := make(map[string]FeatureState)
+	for _, feature := range KnownFeatures {
+		state := FeatureState{
+			Feature: feature,
+			Enabled: feature.Default,
+			Source:  FeatureSourceDefault,
+		}
+
+		if value, ok := features[feature.Name]; ok {
+			state.Enabled = value
+			state.Source = FeatureSourceConfig
+		}
+
+		value, err := f.config.GetFeature(FeatureEnvPrefix + strings.ToUpper(feature.Name))
+		if err == nil {
+			state.Enabled = value
+			state.Source = FeatureSourceEnv
+		}
+
+		states[feature.Name] = state
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var changed []string
+	for name, state := range states {
+		if f.states[name].Enabled != state.Enabled {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	f.states = states
+
+	return changed
+}
+
+// Returns an error listing any feature names that are not known.
+func ValidateFeatures(features map[string]bool) error {
+	var unknown []string
+	for name := range features {
+		found := false
+		for _, feature := range KnownFeatures {
+			if feature.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, name)
+		}
+	}
+
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("unknown features '%v'", strings.Join(unknown, ", "))
+}
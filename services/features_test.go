@@ -0,0 +1,49 @@
+package services_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jtom38/newsbot/portal/services"
+)
+
+func TestFeatureFlagsRefresh(t *testing.T) {
+	t.Setenv("FEATURE_CARDS", "false")
+
+	flags := services.NewFeatureFlags(map[string]bool{
+		services.Feature_TwitchSources: false,
+		services.Feature_CardView:      true,
+	})
+
+	if flags.Enabled(services.Feature_TwitchSources) {
+		t.Error("expected twitch to be turned off by the config")
+	}
+	if flags.Enabled(services.Feature_CardView) {
+		t.Error("expected the env to override the config for cards")
+	}
+	if !flags.Enabled(services.Feature_Subscriptions) {
+		t.Error("expected subscriptions to use the default")
+	}
+	if flags.Enabled("unknown") {
+		t.Error("expected unknown features to be off")
+	}
+
+	changed := flags.Refresh(map[string]bool{})
+	if !reflect.DeepEqual(changed, []string{services.Feature_TwitchSources}) {
+		t.Errorf("expected only twitch to change, got %v", changed)
+	}
+}
+
+func TestValidateFeatures(t *testing.T) {
+	err := services.ValidateFeatures(map[string]bool{services.Feature_CardView: true, "rss": true})
+	if err == nil {
+		t.Fatal("expected rss to be reported as unknown")
+	}
+}
+
+func TestFeatureFlagsNil(t *testing.T) {
+	var flags *services.FeatureFlags
+	if flags.Enabled(services.Feature_CardView) || flags.List() != nil {
+		t.Error("expected nil flags to have every feature off")
+	}
+}
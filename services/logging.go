@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const (
+	// The header used to pass the request ID between the portal, its callers and the collector.
+	RequestIDHeader = "X-Request-ID"
+
+	LogFormatText = "text"
+	LogFormatJson = "json"
+)
+
+type requestIDKey struct{}
+
+// Returns a copy of the context that carries the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// Returns the request ID stored on the context, or a blank string if there is not one.
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// The level used by every logger made with NewLogger, so it can be changed while running.
+var logLevel slog.LevelVar
+
+// This creates the logger for the portal.
+// Level can be debug, info, warn or error and format can be text or json.
+// Any log line written with a context that has a request ID will include it.
+func NewLogger(w io.Writer, level string, format string) (*slog.Logger, error) {
+	err := SetLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := slog.HandlerOptions{Level: &logLevel}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case LogFormatText, "":
+		handler = slog.NewTextHandler(w, &opts)
+	case LogFormatJson:
+		handler = slog.NewJSONHandler(w, &opts)
+	default:
+		return nil, fmt.Errorf("invalid log format '%v', expected text or json", format)
+	}
+
+	return slog.New(requestIDHandler{handler}), nil
+}
+
+// Changes the lowest level that gets logged by the loggers made with NewLogger.
+func SetLogLevel(level string) error {
+	var lvl slog.Level
+	err := lvl.UnmarshalText([]byte(level))
+	if err != nil {
+		return fmt.Errorf("invalid log level '%v', expected debug, info, warn or error", level)
+	}
+
+	logLevel.Set(lvl)
+	return nil
+}
+
+// This wraps a slog.Handler and adds the request ID from the context to every record.
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
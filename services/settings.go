@@ -0,0 +1,768 @@
+package services
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// The config file that is loaded when one is not given.
+	DefaultConfigFile = "config.yaml"
+
+	// Listen addresses that start with this are a path to a unix socket.
+	UnixSocketPrefix = "unix:"
+)
+
+// Config holds every setting the portal uses.
+//
+// Values are loaded from the config file first, then environment variables and then command line flags,
+// so a flag will override the same setting from the environment or the file.
+type Config struct {
+	Server   ServerConfig    `yaml:"server"`
+	Api      ApiConfig       `yaml:"api"`
+	Cache    CacheConfig     `yaml:"cache"`
+	Log      LogConfig       `yaml:"log"`
+	Tracing  TracingConfig   `yaml:"tracing"`
+	Auth     AuthConfig      `yaml:"auth"`
+	Features map[string]bool `yaml:"features"`
+
+	// The config file that was loaded, if there was one.
+	File string `yaml:"-"`
+}
+
+type ServerConfig struct {
+	// The address the portal listens on, like ":8080", or a unix socket like "unix:/run/portal.sock".
+	Address      string        `yaml:"address"`
+	ReadTimeout  time.Duration `yaml:"readTimeout"`
+	WriteTimeout time.Duration `yaml:"writeTimeout"`
+	IdleTimeout  time.Duration `yaml:"idleTimeout"`
+
+	// How long requests that are still running get to finish when the portal is stopped.
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+
+	// The path the portal is served under behind a reverse proxy, like "/newsbot".
+	BasePath string `yaml:"basePath"`
+
+	// Addresses or CIDR ranges of the reverse proxies that are trusted to set the X-Forwarded headers.
+	TrustedProxies []string `yaml:"trustedProxies"`
+
+	TLS TLSConfig `yaml:"tls"`
+}
+
+// When both files are set the portal serves HTTPS.
+// The files are loaded again when they change, so a renewed certificate is used without a restart.
+type TLSConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+// Returns true when HTTPS has been turned on.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// Returns the unix socket path when the address is for one.
+func (c ServerConfig) UnixSocket() (string, bool) {
+	return strings.CutPrefix(c.Address, UnixSocketPrefix)
+}
+
+type ApiConfig struct {
+	// The address of the collector api, like "http://localhost:8081".
+	Address string `yaml:"address"`
+
+	// How long a single request to the collector can take.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// How the portal reacts when the collector version is not supported, "warn", "strict" or "off".
+	VersionCheck string `yaml:"versionCheck"`
+
+	// How long to wait for the collector to come up before serving pages.
+	WaitTimeout time.Duration `yaml:"waitTimeout"`
+
+	RateLimit RateLimitConfig `yaml:"rateLimit"`
+
+	// Limits for requests where the path starts with the key, like "/api/sources".
+	RateLimitRoutes map[string]RateLimitConfig `yaml:"rateLimitRoutes"`
+}
+
+type RateLimitConfig struct {
+	// Requests per second, 0 turns the limit off.
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+type CacheConfig struct {
+	// The number of collector responses to keep for conditional requests, 0 turns the cache off.
+	Size int `yaml:"size"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type TracingConfig struct {
+	Exporter string `yaml:"exporter"`
+}
+
+// When a users file or an OpenID Connect issuer is set, the settings pages need a login.
+type AuthConfig struct {
+	// The json file the local users are kept in, it is created when it does not exist.
+	UsersFile string `yaml:"usersFile"`
+
+	// The user that is created when the users file is empty.
+	// Once someone can log in the password can be removed from the config.
+	AdminUser     string `yaml:"adminUser"`
+	AdminPassword string `yaml:"adminPassword"`
+
+	// How long a login lasts.
+	SessionTimeout time.Duration `yaml:"sessionTimeout"`
+
+	OIDC OIDCConfig `yaml:"oidc"`
+}
+
+// Returns true when logins have been turned on.
+func (c AuthConfig) Enabled() bool {
+	return c.UsersFile != "" || c.OIDC.Enabled()
+}
+
+// Lets people log in with an OpenID Connect provider instead of a password kept by the portal.
+type OIDCConfig struct {
+	// The url of the provider, like "https://auth.example.com/realms/home".
+	Issuer       string `yaml:"issuer"`
+	ClientID     string `yaml:"clientId"`
+	ClientSecret string `yaml:"clientSecret"`
+
+	// Where the provider sends people back to, it defaults to <portal url>/login/oidc/callback.
+	RedirectURL string `yaml:"redirectUrl"`
+
+	Scopes []string `yaml:"scopes"`
+
+	// The ID token claim with the groups the user is in.
+	GroupsClaim string `yaml:"groupsClaim"`
+
+	// The role each group gets, a user in more than one gets the one with the most access.
+	Groups map[string]Role `yaml:"groups"`
+
+	// The role for users who are not in any of the groups, when blank they can not log in.
+	DefaultRole Role `yaml:"defaultRole"`
+
+	// Shown on the login button, like "Log in with Keycloak".
+	Name string `yaml:"name"`
+}
+
+// Returns true when OpenID Connect logins have been turned on.
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != ""
+}
+
+// Returns the role for a user in the groups, false when none of them can use the portal.
+func (c OIDCConfig) RoleFor(groups []string) (Role, bool) {
+	role := c.DefaultRole
+	for _, group := range groups {
+		mapped, ok := c.Groups[group]
+		if ok && (role == "" || mapped.Allows(role)) {
+			role = mapped
+		}
+	}
+	return role, role != ""
+}
+
+// Returns the settings the portal uses when nothing else has been given.
+func DefaultConfig() Config {
+	return Config{
+		Server: ServerConfig{
+			Address:      ":8080",
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 60 * time.Second,
+			IdleTimeout:  120 * time.Second,
+
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Api: ApiConfig{
+			Timeout:      30 * time.Second,
+			VersionCheck: "warn",
+		},
+		Cache: CacheConfig{
+			Size: 256,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: LogFormatText,
+		},
+		Tracing: TracingConfig{
+			Exporter: TracingExporterNone,
+		},
+		Auth: AuthConfig{
+			AdminUser:      "admin",
+			SessionTimeout: 12 * time.Hour,
+			OIDC: OIDCConfig{
+				Scopes:      []string{"openid", "profile", "email"},
+				GroupsClaim: "groups",
+				Groups:      map[string]Role{},
+				Name:        "single sign-on",
+			},
+		},
+		Features: map[string]bool{},
+	}
+}
+
+// This is a single setting that can come from the environment or a flag.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	apply func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{Config_Listen_Address, "listen", "address the portal listens on, like :8080 or unix:/run/portal.sock", func(c *Config, v string) error {
+		c.Server.Address = v
+		return nil
+	}},
+	{Config_Server_ReadTimeout, "read-timeout", "how long reading a request can take", func(c *Config, v string) error {
+		return parseDuration(&c.Server.ReadTimeout, v)
+	}},
+	{Config_Server_WriteTimeout, "write-timeout", "how long writing a response can take", func(c *Config, v string) error {
+		return parseDuration(&c.Server.WriteTimeout, v)
+	}},
+	{Config_Server_IdleTimeout, "idle-timeout", "how long an idle keep-alive connection is kept open", func(c *Config, v string) error {
+		return parseDuration(&c.Server.IdleTimeout, v)
+	}},
+	{Config_Server_ShutdownTimeout, "shutdown-timeout", "how long running requests get to finish when the portal stops", func(c *Config, v string) error {
+		return parseDuration(&c.Server.ShutdownTimeout, v)
+	}},
+	{Config_Server_BasePath, "base-path", "path the portal is served under behind a reverse proxy, like /newsbot", func(c *Config, v string) error {
+		c.Server.BasePath = v
+		return nil
+	}},
+	{Config_Server_TrustedProxies, "trusted-proxies", "proxies allowed to set X-Forwarded headers, like 10.0.0.0/8,127.0.0.1", func(c *Config, v string) error {
+		c.Server.TrustedProxies = nil
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				c.Server.TrustedProxies = append(c.Server.TrustedProxies, item)
+			}
+		}
+		return nil
+	}},
+	{Config_TLS_CertFile, "tls-cert", "certificate file to serve HTTPS with", func(c *Config, v string) error {
+		c.Server.TLS.CertFile = v
+		return nil
+	}},
+	{Config_TLS_KeyFile, "tls-key", "private key file for the certificate", func(c *Config, v string) error {
+		c.Server.TLS.KeyFile = v
+		return nil
+	}},
+	{Config_API_Address, "api-address", "address of the collector api", func(c *Config, v string) error {
+		c.Api.Address = v
+		return nil
+	}},
+	{Config_API_Timeout, "api-timeout", "how long a request to the collector can take", func(c *Config, v string) error {
+		return parseDuration(&c.Api.Timeout, v)
+	}},
+	{Config_API_VersionCheck, "api-version-check", "warn, strict or off", func(c *Config, v string) error {
+		c.Api.VersionCheck = v
+		return nil
+	}},
+	{Config_API_WaitTimeout, "api-wait-timeout", "how long to wait for the collector before serving pages", func(c *Config, v string) error {
+		return parseDuration(&c.Api.WaitTimeout, v)
+	}},
+	{Config_API_RateLimit, "api-rate-limit", "requests per second sent to the collector, like 10 or 10:20 with a burst, 0 is unlimited", func(c *Config, v string) error {
+		limit, err := ParseRateLimit(v)
+		if err != nil {
+			return err
+		}
+		c.Api.RateLimit.Rate = limit.Rate
+		if strings.Contains(v, ":") {
+			c.Api.RateLimit.Burst = limit.Burst
+		}
+		return nil
+	}},
+	{Config_API_RateBurst, "api-rate-burst", "requests that can be sent at once before the rate limit applies", func(c *Config, v string) error {
+		return parseInt(&c.Api.RateLimit.Burst, v)
+	}},
+	{Config_API_RateLimitRoutes, "api-rate-limit-routes", "per route limits, like /api/sources=5:10,/api/articles=20:40", func(c *Config, v string) error {
+		routes, err := ParseRouteRateLimits(v)
+		if err != nil {
+			return err
+		}
+		c.Api.RateLimitRoutes = routes
+		return nil
+	}},
+	{Config_API_CacheSize, "cache-size", "collector responses to keep for conditional requests, 0 turns it off", func(c *Config, v string) error {
+		return parseInt(&c.Cache.Size, v)
+	}},
+	{Config_Log_Level, "log-level", "debug, info, warn or error", func(c *Config, v string) error {
+		c.Log.Level = v
+		return nil
+	}},
+	{Config_Log_Format, "log-format", "text or json", func(c *Config, v string) error {
+		c.Log.Format = v
+		return nil
+	}},
+	{Config_Features, "features", "turns features on or off, like subscriptions=true,cards=false", func(c *Config, v string) error {
+		return parseFeatures(c.Features, v)
+	}},
+	{Config_Tracing_Exporter, "tracing-exporter", "none, stdout or otlp", func(c *Config, v string) error {
+		c.Tracing.Exporter = v
+		return nil
+	}},
+	{Config_Auth_UsersFile, "users-file", "json file with the users that can log in, the settings pages are open without one", func(c *Config, v string) error {
+		c.Auth.UsersFile = v
+		return nil
+	}},
+	{Config_Auth_AdminUser, "admin-user", "user created when the users file is empty", func(c *Config, v string) error {
+		c.Auth.AdminUser = v
+		return nil
+	}},
+	{Config_Auth_AdminPassword, "admin-password", "password for the admin user when it is created", func(c *Config, v string) error {
+		c.Auth.AdminPassword = v
+		return nil
+	}},
+	{Config_Auth_SessionTimeout, "session-timeout", "how long a login lasts", func(c *Config, v string) error {
+		return parseDuration(&c.Auth.SessionTimeout, v)
+	}},
+	{Config_OIDC_Issuer, "oidc-issuer", "url of the OpenID Connect provider to log in with", func(c *Config, v string) error {
+		c.Auth.OIDC.Issuer = v
+		return nil
+	}},
+	{Config_OIDC_ClientID, "oidc-client-id", "client ID the portal has with the provider", func(c *Config, v string) error {
+		c.Auth.OIDC.ClientID = v
+		return nil
+	}},
+	{Config_OIDC_ClientSecret, "oidc-client-secret", "client secret, leave it out for a public client", func(c *Config, v string) error {
+		c.Auth.OIDC.ClientSecret = v
+		return nil
+	}},
+	{Config_OIDC_RedirectURL, "oidc-redirect-url", "where the provider sends people back to, defaults to <portal url>/login/oidc/callback", func(c *Config, v string) error {
+		c.Auth.OIDC.RedirectURL = v
+		return nil
+	}},
+	{Config_OIDC_Scopes, "oidc-scopes", "scopes to ask for, like openid,profile,email,groups", func(c *Config, v string) error {
+		c.Auth.OIDC.Scopes = splitList(v)
+		return nil
+	}},
+	{Config_OIDC_GroupsClaim, "oidc-groups-claim", "ID token claim with the groups of the user", func(c *Config, v string) error {
+		c.Auth.OIDC.GroupsClaim = v
+		return nil
+	}},
+	{Config_OIDC_Groups, "oidc-groups", "role for each group, like portal-admins=admin,portal-editors=editor", func(c *Config, v string) error {
+		return parseGroupRoles(c.Auth.OIDC.Groups, v)
+	}},
+	{Config_OIDC_DefaultRole, "oidc-default-role", "role for users in none of the groups, blank turns them away", func(c *Config, v string) error {
+		c.Auth.OIDC.DefaultRole = Role(v)
+		return nil
+	}},
+	{Config_OIDC_Name, "oidc-name", "name of the provider on the login button", func(c *Config, v string) error {
+		c.Auth.OIDC.Name = v
+		return nil
+	}},
+}
+
+// LoadConfig builds the Config from the config file, the environment and the command line args.
+// Every problem that is found is returned at once so they can all be fixed in one go.
+func LoadConfig(args []string) (Config, error) {
+	fs := flag.NewFlagSet("portal", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	flags := NewConfigFlags(fs)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return DefaultConfig(), err
+	}
+
+	return flags.Load()
+}
+
+// ConfigFlags adds the config settings to a FlagSet, so a command can have its own flags next to them.
+type ConfigFlags struct {
+	fs     *flag.FlagSet
+	file   *string
+	values map[string]*string
+}
+
+func NewConfigFlags(fs *flag.FlagSet) *ConfigFlags {
+	f := ConfigFlags{
+		fs:     fs,
+		file:   fs.String("config", "", fmt.Sprintf("path to the config file, %v is used if it exists (env %v)", DefaultConfigFile, Config_File)),
+		values: make(map[string]*string),
+	}
+	for _, s := range settings {
+		f.values[s.flag] = fs.String(s.flag, "", fmt.Sprintf("%v (env %v)", s.usage, s.env))
+	}
+	return &f
+}
+
+// This builds the Config once the FlagSet has been parsed.
+func (f *ConfigFlags) Load() (Config, error) {
+	cfg := DefaultConfig()
+	var errs []error
+
+	// Pick up anything in the .env file before we look at the environment.
+	cc := NewConfigClient()
+
+	cfg.File = *f.file
+	if cfg.File == "" {
+		cfg.File = cc.GetOrDefault(Config_File, "")
+	}
+	if cfg.File == "" {
+		if _, err := os.Stat(DefaultConfigFile); err == nil {
+			cfg.File = DefaultConfigFile
+		}
+	}
+
+	if cfg.File != "" {
+		err := readConfigFile(cfg.File, &cfg)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if cfg.Features == nil {
+		cfg.Features = map[string]bool{}
+	}
+	if cfg.Auth.OIDC.Groups == nil {
+		cfg.Auth.OIDC.Groups = map[string]Role{}
+	}
+
+	for _, s := range settings {
+		value, ok := os.LookupEnv(s.env)
+		if !ok {
+			continue
+		}
+		err := s.apply(&cfg, value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", s.env, err))
+		}
+	}
+
+	f.fs.Visit(func(fl *flag.Flag) {
+		for _, s := range settings {
+			if s.flag != fl.Name {
+				continue
+			}
+			err := s.apply(&cfg, *f.values[s.flag])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("-%v: %w", s.flag, err))
+			}
+		}
+	})
+
+	// A limiter without a burst would never let a request through.
+	if cfg.Api.RateLimit.Rate > 0 && cfg.Api.RateLimit.Burst == 0 {
+		cfg.Api.RateLimit.Burst = max(1, int(cfg.Api.RateLimit.Rate))
+	}
+
+	errs = append(errs, cfg.Validate()...)
+
+	return cfg, errors.Join(errs...)
+}
+
+func readConfigFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open the config file: %w", err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(cfg)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("%v: %w", path, err)
+	}
+
+	return nil
+}
+
+// Validate checks every setting and returns all the problems it found.
+func (c Config) Validate() []error {
+	var errs []error
+
+	if c.Server.Address == "" {
+		errs = append(errs, errors.New("server.address is required"))
+	} else if path, ok := c.Server.UnixSocket(); ok && path == "" {
+		errs = append(errs, errors.New("server.address needs a path after unix:"))
+	}
+
+	if c.Server.BasePath != "" && (!strings.HasPrefix(c.Server.BasePath, "/") || strings.HasSuffix(c.Server.BasePath, "/")) {
+		errs = append(errs, fmt.Errorf("server.basePath '%v' needs to start with / and not end with one, like /newsbot", c.Server.BasePath))
+	}
+
+	_, err := ParseTrustedProxies(c.Server.TrustedProxies)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("server.trustedProxies: %w", err))
+	}
+
+	if c.Server.TLS.Enabled() {
+		if c.Server.TLS.CertFile == "" || c.Server.TLS.KeyFile == "" {
+			errs = append(errs, errors.New("server.tls needs both certFile and keyFile"))
+		}
+		for _, path := range []string{c.Server.TLS.CertFile, c.Server.TLS.KeyFile} {
+			if path == "" {
+				continue
+			}
+			if _, err := os.Stat(path); err != nil {
+				errs = append(errs, fmt.Errorf("server.tls: %w", err))
+			}
+		}
+	}
+
+	for name, value := range map[string]time.Duration{
+		"server.readTimeout":     c.Server.ReadTimeout,
+		"server.writeTimeout":    c.Server.WriteTimeout,
+		"server.idleTimeout":     c.Server.IdleTimeout,
+		"server.shutdownTimeout": c.Server.ShutdownTimeout,
+		"api.timeout":            c.Api.Timeout,
+		"api.waitTimeout":        c.Api.WaitTimeout,
+		"auth.sessionTimeout":    c.Auth.SessionTimeout,
+	} {
+		if value < 0 {
+			errs = append(errs, fmt.Errorf("%v can not be negative", name))
+		}
+	}
+
+	if c.Api.Address == "" {
+		errs = append(errs, fmt.Errorf("api.address is required, set it in the config file or with %v", Config_API_Address))
+	} else if u, err := url.Parse(c.Api.Address); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("api.address '%v' is not a valid url, like http://localhost:8081", c.Api.Address))
+	}
+
+	switch c.Api.VersionCheck {
+	case "warn", "strict", "off":
+	default:
+		errs = append(errs, fmt.Errorf("api.versionCheck '%v' is not valid, expected warn, strict or off", c.Api.VersionCheck))
+	}
+
+	if c.Api.RateLimit.Rate < 0 || c.Api.RateLimit.Burst < 0 {
+		errs = append(errs, errors.New("api.rateLimit can not be negative"))
+	}
+	for prefix, limit := range c.Api.RateLimitRoutes {
+		if !strings.HasPrefix(prefix, "/") {
+			errs = append(errs, fmt.Errorf("api.rateLimitRoutes '%v' needs to start with /", prefix))
+		}
+		if limit.Rate < 0 || limit.Burst < 0 {
+			errs = append(errs, fmt.Errorf("api.rateLimitRoutes '%v' can not be negative", prefix))
+		}
+	}
+
+	if c.Cache.Size < 0 {
+		errs = append(errs, errors.New("cache.size can not be negative"))
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level '%v' is not valid, expected debug, info, warn or error", c.Log.Level))
+	}
+
+	switch c.Log.Format {
+	case LogFormatText, LogFormatJson:
+	default:
+		errs = append(errs, fmt.Errorf("log.format '%v' is not valid, expected text or json", c.Log.Format))
+	}
+
+	err = ValidateFeatures(c.Features)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("features: %w", err))
+	}
+
+	switch c.Tracing.Exporter {
+	case TracingExporterNone, TracingExporterStdout, TracingExporterOtlp:
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter '%v' is not valid, expected none, stdout or otlp", c.Tracing.Exporter))
+	}
+
+	if c.Auth.Enabled() {
+		if c.Auth.SessionTimeout == 0 {
+			errs = append(errs, errors.New("auth.sessionTimeout is required when logins are turned on"))
+		}
+		if c.Auth.AdminPassword != "" && len(c.Auth.AdminPassword) < MinPasswordLength {
+			errs = append(errs, fmt.Errorf("auth.adminPassword: %v", ErrShortPassword))
+		}
+	}
+
+	errs = append(errs, c.Auth.OIDC.validate()...)
+
+	return errs
+}
+
+func (c OIDCConfig) validate() []error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	var errs []error
+	if u, err := url.Parse(c.Issuer); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("auth.oidc.issuer '%v' is not a valid url", c.Issuer))
+	}
+	if c.ClientID == "" {
+		errs = append(errs, errors.New("auth.oidc.clientId is required"))
+	}
+	if c.RedirectURL != "" {
+		if u, err := url.Parse(c.RedirectURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("auth.oidc.redirectUrl '%v' is not a valid url", c.RedirectURL))
+		}
+	}
+	if c.GroupsClaim == "" {
+		errs = append(errs, errors.New("auth.oidc.groupsClaim is required"))
+	}
+	for group, role := range c.Groups {
+		if _, err := ParseRole(string(role)); err != nil {
+			errs = append(errs, fmt.Errorf("auth.oidc.groups '%v': %v", group, err))
+		}
+	}
+	if c.DefaultRole != "" {
+		if _, err := ParseRole(string(c.DefaultRole)); err != nil {
+			errs = append(errs, fmt.Errorf("auth.oidc.defaultRole: %v", err))
+		}
+	}
+	if len(c.Groups) == 0 && c.DefaultRole == "" {
+		errs = append(errs, errors.New("auth.oidc needs groups or a defaultRole, otherwise nobody can log in"))
+	}
+	return errs
+}
+
+// Parses proxy addresses like "10.0.0.1" or ranges like "10.0.0.0/8".
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, value := range values {
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return prefixes, fmt.Errorf("invalid range '%v'", value)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return prefixes, fmt.Errorf("invalid address '%v'", value)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// Parses a rate limit in the form of "rate:burst", like "10:20".
+// The burst can be left off and will default to the rate.
+func ParseRateLimit(value string) (RateLimitConfig, error) {
+	var limit RateLimitConfig
+
+	parts := strings.SplitN(strings.TrimSpace(value), ":", 2)
+	r, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return limit, fmt.Errorf("invalid rate '%v'", parts[0])
+	}
+	limit.Rate = r
+	limit.Burst = int(r)
+
+	if len(parts) == 2 {
+		b, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return limit, fmt.Errorf("invalid burst '%v'", parts[1])
+		}
+		limit.Burst = b
+	}
+
+	return limit, nil
+}
+
+// Parses a comma separated list of route limits, like "/api/sources=5:10,/api/articles=20".
+func ParseRouteRateLimits(value string) (map[string]RateLimitConfig, error) {
+	routes := make(map[string]RateLimitConfig)
+	if strings.TrimSpace(value) == "" {
+		return routes, nil
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return routes, fmt.Errorf("invalid route rate limit '%v', expected prefix=rate:burst", item)
+		}
+
+		limit, err := ParseRateLimit(parts[1])
+		if err != nil {
+			return routes, err
+		}
+		routes[strings.TrimSpace(parts[0])] = limit
+	}
+
+	return routes, nil
+}
+
+// Parses a comma separated list of features, like "subscriptions=true,cards=false".
+// A feature without a value is turned on.
+func parseFeatures(features map[string]bool, value string) error {
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, raw, found := strings.Cut(item, "=")
+		enabled := true
+		if found {
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("feature '%v' has an invalid value '%v'", name, raw)
+			}
+			enabled = b
+		}
+		features[strings.TrimSpace(name)] = enabled
+	}
+	return nil
+}
+
+// Parses a comma separated list of group roles, like "portal-admins=admin,portal-editors=editor".
+func parseGroupRoles(groups map[string]Role, value string) error {
+	for _, item := range splitList(value) {
+		group, role, found := strings.Cut(item, "=")
+		if !found {
+			return fmt.Errorf("invalid group '%v', expected group=role", item)
+		}
+		groups[strings.TrimSpace(group)] = Role(strings.TrimSpace(role))
+	}
+	return nil
+}
+
+// Splits a comma separated list and drops the blank items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseDuration(out *time.Duration, value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("'%v' is not a duration, like 30s", value)
+	}
+	*out = d
+	return nil
+}
+
+func parseInt(out *int, value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("'%v' is not a whole number", value)
+	}
+	*out = n
+	return nil
+}
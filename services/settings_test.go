@@ -0,0 +1,128 @@
+package services_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jtom38/newsbot/portal/services"
+)
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "portal.yaml")
+	err := os.WriteFile(path, []byte(`
+server:
+  address: ":9000"
+api:
+  address: http://file:8081
+  timeout: 10s
+cache:
+  size: 50
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(services.Config_API_Address, "http://env:8081")
+	t.Setenv(services.Config_API_CacheSize, "100")
+
+	cfg, err := services.LoadConfig([]string{"-config", path, "-cache-size", "200"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Server.Address != ":9000" {
+		t.Errorf("expected the listen address from the file, got '%v'", cfg.Server.Address)
+	}
+	if cfg.Api.Timeout != 10*time.Second {
+		t.Errorf("expected the timeout from the file, got '%v'", cfg.Api.Timeout)
+	}
+	if cfg.Api.Address != "http://env:8081" {
+		t.Errorf("expected the env to override the file, got '%v'", cfg.Api.Address)
+	}
+	if cfg.Cache.Size != 200 {
+		t.Errorf("expected the flag to override the env, got '%v'", cfg.Cache.Size)
+	}
+	if cfg.Server.IdleTimeout != services.DefaultConfig().Server.IdleTimeout {
+		t.Errorf("expected the default idle timeout, got '%v'", cfg.Server.IdleTimeout)
+	}
+}
+
+func TestLoadConfigReportsEveryProblem(t *testing.T) {
+	t.Setenv(services.Config_API_Address, "localhost")
+	t.Setenv(services.Config_Log_Format, "xml")
+
+	_, err := services.LoadConfig([]string{"-api-timeout", "soon", "-cache-size", "-1"})
+	if err == nil {
+		t.Fatal("expected the config to be invalid")
+	}
+
+	for _, want := range []string{"-api-timeout", "api.address", "log.format", "cache.size"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected '%v' to be reported, got %v", want, err)
+		}
+	}
+}
+
+func TestParseRouteRateLimits(t *testing.T) {
+	routes, err := services.ParseRouteRateLimits("/api/sources=5:10, /api/articles=20")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if routes["/api/sources"] != (services.RateLimitConfig{Rate: 5, Burst: 10}) {
+		t.Errorf("unexpected limit for sources, %v", routes["/api/sources"])
+	}
+
+	if routes["/api/articles"] != (services.RateLimitConfig{Rate: 20, Burst: 20}) {
+		t.Errorf("unexpected limit for articles, %v", routes["/api/articles"])
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := services.ParseTrustedProxies([]string{"10.1.2.3/8", "127.0.0.1", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"10.0.0.0/8", "127.0.0.1/32", "::1/128"}
+	for i, prefix := range prefixes {
+		if prefix.String() != want[i] {
+			t.Errorf("expected %v, got %v", want[i], prefix)
+		}
+	}
+
+	_, err = services.ParseTrustedProxies([]string{"proxy.local"})
+	if err == nil {
+		t.Error("expected host names to be rejected")
+	}
+}
+
+func TestOIDCRoleFor(t *testing.T) {
+	t.Setenv(services.Config_API_Address, "http://localhost:8081")
+	t.Setenv(services.Config_OIDC_Groups, "portal-admins=admin, portal-editors=editor")
+
+	cfg, err := services.LoadConfig([]string{"-oidc-issuer", "http://localhost:9000", "-oidc-client-id", "portal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oidc := cfg.Auth.OIDC
+
+	role, ok := oidc.RoleFor([]string{"portal-editors", "portal-admins", "staff"})
+	if !ok || role != services.RoleAdmin {
+		t.Errorf("expected the role with the most access, got '%v'", role)
+	}
+
+	_, ok = oidc.RoleFor([]string{"staff"})
+	if ok {
+		t.Error("expected users in none of the groups to be turned away")
+	}
+
+	oidc.DefaultRole = services.RoleViewer
+	role, ok = oidc.RoleFor(nil)
+	if !ok || role != services.RoleViewer {
+		t.Errorf("expected the default role, got '%v'", role)
+	}
+}
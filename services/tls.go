@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertificateReloader serves a TLS certificate from files on disk and picks up new ones when they change,
+// like after a renewal, without dropping open connections.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modified time.Time
+}
+
+// Loads the certificate and key, returns an error if they can not be used.
+func NewCertificateReloader(certFile string, keyFile string) (*CertificateReloader, error) {
+	c := CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	err := c.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// This is used as tls.Config.GetCertificate.
+func (c *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// This reads the files again.
+// If they can not be loaded the current certificate is kept.
+func (c *CertificateReloader) Reload() error {
+	modified := c.modTime()
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Only try files that failed again once they change.
+	c.modified = modified
+	if err != nil {
+		return fmt.Errorf("unable to load the tls certificate: %w", err)
+	}
+	c.cert = &cert
+
+	return nil
+}
+
+// This checks the files on an interval and reloads them when they change, until the context is done.
+func (c *CertificateReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		c.mu.RLock()
+		changed := !c.modTime().Equal(c.modified)
+		c.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		err := c.Reload()
+		if err != nil {
+			slog.Error("keeping the current tls certificate", "error", err)
+			continue
+		}
+		slog.Info("reloaded the tls certificate", "cert", c.certFile)
+	}
+}
+
+// Returns the newest change to either file.
+func (c *CertificateReloader) modTime() time.Time {
+	var newest time.Time
+	for _, path := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest
+}
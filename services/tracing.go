@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+const (
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterOtlp   = "otlp"
+
+	tracingServiceName = "newsbot.portal"
+)
+
+// This sets up the global OpenTelemetry tracer and the W3C trace context propagator.
+//
+// "otlp" sends spans over http to the endpoint in OTEL_EXPORTER_OTLP_ENDPOINT, localhost:4318 by default.
+// "stdout" writes them to the console and "none" leaves tracing off.
+//
+// The returned func flushes anything left in the buffer and should be called before the portal exits.
+func SetupTracing(ctx context.Context, exporter string) (func(context.Context) error, error) {
+	shutdown := func(context.Context) error { return nil }
+
+	// Even with tracing turned off we keep passing along the trace context we are given.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "", TracingExporterNone:
+		return shutdown, nil
+	case TracingExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case TracingExporterOtlp:
+		spanExporter, err = otlptracehttp.New(ctx)
+	default:
+		return shutdown, fmt.Errorf("unknown tracing exporter '%v', expected none, stdout or otlp", exporter)
+	}
+	if err != nil {
+		return shutdown, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(tracingServiceName),
+	))
+	if err != nil {
+		return shutdown, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
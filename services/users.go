@@ -0,0 +1,398 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	ErrInvalidLogin  = "the user name or password is not correct"
+	ErrUserExists    = "the user already exists"
+	ErrUserNotFound  = "the user does not exist"
+	ErrLastAdmin     = "there has to be at least one admin"
+	ErrShortPassword = "the password needs to be at least 8 characters"
+	ErrUserName      = "user names can only have letters, numbers, dots, dashes and underscores"
+	ErrRole          = "the role needs to be viewer, editor or admin"
+
+	MinPasswordLength = 8
+
+	usersFileVersion = 1
+)
+
+var validUserName = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Role decides which settings pages a user can see.
+// Each role can do everything the ones before it can.
+type Role string
+
+const (
+	// Can browse the articles.
+	RoleViewer Role = "viewer"
+
+	// Can also change sources and subscriptions.
+	RoleEditor Role = "editor"
+
+	// Can also change web hooks and users, apply manifests and make or restore backups.
+	RoleAdmin Role = "admin"
+)
+
+// Every role from the least to the most access.
+var Roles = []Role{RoleViewer, RoleEditor, RoleAdmin}
+
+func ParseRole(value string) (Role, error) {
+	for _, role := range Roles {
+		if string(role) == value {
+			return role, nil
+		}
+	}
+	return "", errors.New(ErrRole)
+}
+
+// Returns true when the role has at least the access of need.
+func (r Role) Allows(need Role) bool {
+	have, want := -1, -1
+	for i, role := range Roles {
+		if role == r {
+			have = i
+		}
+		if role == need {
+			want = i
+		}
+	}
+	return want >= 0 && have >= want
+}
+
+// Used to check passwords for users that do not exist, so the time a login takes does not give away which names are real.
+var missingUserHash, _ = bcrypt.GenerateFromPassword([]byte("missing user"), bcrypt.DefaultCost)
+
+// A local account that can log in to the portal.
+type User struct {
+	Name         string    `json:"name"`
+	PasswordHash string    `json:"passwordHash"`
+	Role         Role      `json:"role"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type usersFile struct {
+	Version int    `json:"version"`
+	Users   []User `json:"users"`
+}
+
+// UserStore keeps the local users in a json file.
+// Every change is written out straight away, so the file can be backed up like the config.
+type UserStore struct {
+	path string
+
+	mu       sync.RWMutex
+	users    map[string]User
+	modified time.Time
+}
+
+// This reads the users file, a file that does not exist yet is an empty store.
+func OpenUserStore(path string) (*UserStore, error) {
+	s := UserStore{
+		path:  path,
+		users: make(map[string]User),
+	}
+
+	err := s.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// This reads the users file again, so changes made with portal users reach a portal that is running.
+// If the file can not be read the current users are kept.
+func (s *UserStore) Reload() error {
+	modified := s.modTime()
+	users, err := readUsersFile(s.path)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Only try a file that failed again once it changes.
+	s.modified = modified
+	if err != nil {
+		return err
+	}
+	s.users = users
+	return nil
+}
+
+// This checks the users file on an interval and reloads it when it changes, until the context is done.
+func (s *UserStore) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.RLock()
+		changed := !s.modTime().Equal(s.modified)
+		s.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		err := s.Reload()
+		if err != nil {
+			slog.Error("keeping the current users", "error", err)
+			continue
+		}
+		slog.Info("reloaded the users file", "path", s.path)
+	}
+}
+
+func (s *UserStore) modTime() time.Time {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func readUsersFile(path string) (map[string]User, error) {
+	users := make(map[string]User)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return users, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the users file: %w", err)
+	}
+
+	var file usersFile
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	if file.Version != usersFileVersion {
+		return nil, fmt.Errorf("%v: version %v is not supported", path, file.Version)
+	}
+
+	for _, user := range file.Users {
+		// Before there were roles everyone who could log in could change everything.
+		if user.Role == "" {
+			user.Role = RoleAdmin
+		}
+		users[user.Name] = user
+	}
+	return users, nil
+}
+
+// This creates the first user when there are none, so a new portal has someone who can log in.
+// Returns true when the user was created.
+func (s *UserStore) Bootstrap(name, password string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.users) > 0 {
+		return false, nil
+	}
+	if name == "" || password == "" {
+		return false, errors.New("there are no users yet, set auth.adminUser and auth.adminPassword to create the first one")
+	}
+
+	err := s.add(name, password, RoleAdmin)
+	return err == nil, err
+}
+
+func (s *UserStore) Add(name, password string, role Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.add(name, password, role)
+}
+
+func (s *UserStore) add(name, password string, role Role) error {
+	if !validUserName.MatchString(name) {
+		return errors.New(ErrUserName)
+	}
+	if _, err := ParseRole(string(role)); err != nil {
+		return err
+	}
+	if _, ok := s.users[name]; ok {
+		return errors.New(ErrUserExists)
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	s.users[name] = User{Name: name, PasswordHash: hash, Role: role, CreatedAt: time.Now().UTC()}
+	return s.save()
+}
+
+func (s *UserStore) SetPassword(name, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[name]
+	if !ok {
+		return errors.New(ErrUserNotFound)
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	user.PasswordHash = hash
+	s.users[name] = user
+	return s.save()
+}
+
+func (s *UserStore) SetRole(name string, role Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[name]
+	if !ok {
+		return errors.New(ErrUserNotFound)
+	}
+	if _, err := ParseRole(string(role)); err != nil {
+		return err
+	}
+	if role != RoleAdmin && s.lastAdmin(name) {
+		return errors.New(ErrLastAdmin)
+	}
+
+	user.Role = role
+	s.users[name] = user
+	return s.save()
+}
+
+func (s *UserStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[name]; !ok {
+		return errors.New(ErrUserNotFound)
+	}
+	if s.lastAdmin(name) {
+		return errors.New(ErrLastAdmin)
+	}
+
+	delete(s.users, name)
+	return s.save()
+}
+
+// Returns true when the user is the only admin, so nobody would be left to manage the users without them.
+func (s *UserStore) lastAdmin(name string) bool {
+	if s.users[name].Role != RoleAdmin {
+		return false
+	}
+	for _, user := range s.users {
+		if user.Name != name && user.Role == RoleAdmin {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the users sorted by name.
+func (s *UserStore) List() []User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	return users
+}
+
+func (s *UserStore) Get(name string) (User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[name]
+	return user, ok
+}
+
+// Returns the user when the password matches.
+func (s *UserStore) Authenticate(name, password string) (User, error) {
+	user, ok := s.Get(name)
+	if !ok {
+		bcrypt.CompareHashAndPassword(missingUserHash, []byte(password))
+		return User{}, errors.New(ErrInvalidLogin)
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	if err != nil {
+		return User{}, errors.New(ErrInvalidLogin)
+	}
+	return user, nil
+}
+
+// The file holds password hashes, so it is only readable by the portal.
+// It is written next to the old one and renamed over it so a crash never leaves half a file.
+func (s *UserStore) save() error {
+	file := usersFile{Version: usersFileVersion, Users: make([]User, 0, len(s.users))}
+	for _, user := range s.users {
+		file.Users = append(file.Users, user)
+	}
+	sort.Slice(file.Users, func(i, j int) bool { return file.Users[i].Name < file.Users[j].Name })
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".users-*.json")
+	if err != nil {
+		return fmt.Errorf("unable to write the users file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(0o600)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write the users file: %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), s.path)
+	if err != nil {
+		return err
+	}
+
+	// The store already has what was written, so Watch does not need to read it back.
+	s.modified = s.modTime()
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", errors.New(ErrShortPassword)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,204 @@
+package services_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jtom38/newsbot/portal/services"
+)
+
+func TestUserStoreBootstrap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+
+	store, err := services.OpenUserStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.Bootstrap("admin", "")
+	if err == nil {
+		t.Fatal("expected an empty store without a password to fail")
+	}
+
+	created, err := store.Bootstrap("admin", "correct horse")
+	if err != nil || !created {
+		t.Fatalf("expected the admin to be created, got %v %v", created, err)
+	}
+
+	created, err = store.Bootstrap("other", "battery staple")
+	if err != nil || created {
+		t.Fatalf("expected a store with users to be left alone, got %v %v", created, err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the users file to only be readable by the portal, got %v", info.Mode().Perm())
+	}
+
+	reopened, err := services.OpenUserStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Authenticate("admin", "correct horse"); err != nil {
+		t.Errorf("expected the admin to log in after a reload, got %v", err)
+	}
+}
+
+func TestUserStoreAuthenticate(t *testing.T) {
+	store, err := services.OpenUserStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Add("jamie", "short", services.RoleEditor)
+	if err == nil || err.Error() != services.ErrShortPassword {
+		t.Errorf("expected a short password to be rejected, got %v", err)
+	}
+	err = store.Add("jamie smith", "long enough", services.RoleEditor)
+	if err == nil || err.Error() != services.ErrUserName {
+		t.Errorf("expected a name with a space to be rejected, got %v", err)
+	}
+
+	err = store.Add("jamie", "long enough", services.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct{ name, password string }{
+		{"jamie", "wrong password"},
+		{"nobody", "long enough"},
+	} {
+		_, err := store.Authenticate(tc.name, tc.password)
+		if err == nil || err.Error() != services.ErrInvalidLogin {
+			t.Errorf("expected %v to fail to log in, got %v", tc.name, err)
+		}
+	}
+
+	err = store.SetPassword("jamie", "a new password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Authenticate("jamie", "a new password"); err != nil {
+		t.Errorf("expected the new password to work, got %v", err)
+	}
+}
+
+func TestUserStoreRoles(t *testing.T) {
+	store, err := services.OpenUserStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Add("admin", "long enough", services.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Add("jamie", "long enough", "owner")
+	if err == nil || err.Error() != services.ErrRole {
+		t.Errorf("expected an unknown role to be rejected, got %v", err)
+	}
+	err = store.Add("jamie", "long enough", services.RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, change := range []func() error{
+		func() error { return store.Delete("admin") },
+		func() error { return store.SetRole("admin", services.RoleEditor) },
+	} {
+		err := change()
+		if err == nil || err.Error() != services.ErrLastAdmin {
+			t.Errorf("expected the last admin to be kept, got %v", err)
+		}
+	}
+
+	err = store.SetRole("jamie", services.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Delete("admin")
+	if err != nil {
+		t.Errorf("expected an admin to be deleted once there is another, got %v", err)
+	}
+
+	if !services.RoleEditor.Allows(services.RoleViewer) || services.RoleEditor.Allows(services.RoleAdmin) {
+		t.Error("expected editors to have more access than viewers and less than admins")
+	}
+	if services.Role("").Allows(services.RoleViewer) {
+		t.Error("expected a blank role to have no access")
+	}
+}
+
+func TestUserStoreDefaultsToAdmin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	err := os.WriteFile(path, []byte(`{"version":1,"users":[{"name":"old","passwordHash":"x"}]}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := services.OpenUserStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ := store.Get("old")
+	if user.Role != services.RoleAdmin {
+		t.Errorf("expected users from before roles to be admins, got '%v'", user.Role)
+	}
+}
+
+func TestUserStoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+
+	portal, err := services.OpenUserStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = portal.Bootstrap("admin", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// portal users runs in its own process with its own store.
+	cli, err := services.OpenUserStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cli.SetPassword("admin", "battery staple")
+	if err == nil {
+		err = cli.Add("jamie", "jamie's password", services.RoleViewer)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = portal.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := portal.Authenticate("admin", "correct horse"); err == nil {
+		t.Error("expected the old password to stop working")
+	}
+	if _, err := portal.Authenticate("admin", "battery staple"); err != nil {
+		t.Errorf("expected the new password to work, %v", err)
+	}
+	if _, ok := portal.Get("jamie"); !ok {
+		t.Error("expected the new user to be found")
+	}
+
+	// A broken file keeps the users that were there.
+	err = os.WriteFile(path, []byte("{"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := portal.Reload(); err == nil {
+		t.Error("expected a broken users file to fail")
+	}
+	if _, ok := portal.Get("jamie"); !ok {
+		t.Error("expected the users to be kept")
+	}
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// How often the config file and the .env file are checked for changes.
+	DefaultConfigPollInterval = 5 * time.Second
+)
+
+// ConfigWatcher keeps the Config up to date while the portal is running.
+//
+// The config is loaded again when the config file or the .env file changes, or when the process gets a SIGHUP.
+// A new config is only used once it passes validation, until then the previous one is kept.
+type ConfigWatcher struct {
+	args    []string
+	current atomic.Pointer[Config]
+
+	mu          sync.Mutex
+	subscribers []func(old Config, new Config)
+	modified    map[string]time.Time
+}
+
+// Creates a watcher that starts with cfg.
+// The args are the command line args LoadConfig is called with on every reload.
+func NewConfigWatcher(cfg Config, args []string) *ConfigWatcher {
+	w := ConfigWatcher{
+		args: args,
+	}
+	w.current.Store(&cfg)
+	w.modified = w.modTimes(cfg)
+
+	return &w
+}
+
+// Returns the config that is in use right now.
+func (w *ConfigWatcher) Current() Config {
+	return *w.current.Load()
+}
+
+// This registers fn to be called after a new config has been swapped in.
+// Subscribers are called in the order they were added.
+func (w *ConfigWatcher) Subscribe(fn func(old Config, new Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// This loads the config again and swaps it in if it is valid.
+// On an error the current config is kept and the error is returned.
+//
+// Subscribers are called after the lock is let go, so they are free to call back into the watcher.
+func (w *ConfigWatcher) Reload() error {
+	w.mu.Lock()
+	cfg, err := LoadConfig(w.args)
+	w.modified = w.modTimes(cfg)
+	if err != nil {
+		w.mu.Unlock()
+		return err
+	}
+
+	old := w.current.Swap(&cfg)
+	subscribers := append([]func(old Config, new Config){}, w.subscribers...)
+	w.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(*old, cfg)
+	}
+
+	return nil
+}
+
+// This reloads the config when a file changes or a SIGHUP comes in, until the context is done.
+func (w *ConfigWatcher) Watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("reloading the config, got SIGHUP")
+		case <-ticker.C:
+			if !w.changed() {
+				continue
+			}
+			slog.Info("reloading the config, a file changed")
+		}
+
+		err := w.Reload()
+		if err != nil {
+			slog.Error("the new config is not valid, keeping the current one", "error", err)
+			continue
+		}
+		slog.Info("config reloaded")
+	}
+}
+
+// Returns true when a watched file was changed, added or removed since the last load.
+func (w *ConfigWatcher) changed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.modTimes(w.Current())
+	if len(now) != len(w.modified) {
+		return true
+	}
+	for path, t := range now {
+		if !w.modified[path].Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *ConfigWatcher) modTimes(cfg Config) map[string]time.Time {
+	times := make(map[string]time.Time)
+	for _, path := range []string{cfg.File, DotEnvFile, DefaultConfigFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		times[path] = info.ModTime()
+	}
+	return times
+}
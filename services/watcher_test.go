@@ -0,0 +1,91 @@
+package services_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jtom38/newsbot/portal/services"
+)
+
+func TestConfigWatcherReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "portal.yaml")
+	write := func(body string) {
+		err := os.WriteFile(path, []byte(body), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("api:\n  address: http://localhost:8081\ncache:\n  size: 10\n")
+
+	args := []string{"-config", path}
+	cfg, err := services.LoadConfig(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := services.NewConfigWatcher(cfg, args)
+	var notified []int
+	watcher.Subscribe(func(old services.Config, new services.Config) {
+		notified = append(notified, old.Cache.Size, new.Cache.Size)
+	})
+
+	write("api:\n  address: http://localhost:8081\ncache:\n  size: 40\n")
+	err = watcher.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if watcher.Current().Cache.Size != 40 {
+		t.Errorf("expected the new config to be used, got a cache size of %v", watcher.Current().Cache.Size)
+	}
+	if len(notified) != 2 || notified[0] != 10 || notified[1] != 40 {
+		t.Errorf("expected subscribers to get the old and new config, got %v", notified)
+	}
+
+	write("api:\n  address: http://localhost:8081\ncache:\n  size: -1\n")
+	err = watcher.Reload()
+	if err == nil {
+		t.Fatal("expected the invalid config to be rejected")
+	}
+	if watcher.Current().Cache.Size != 40 {
+		t.Errorf("expected the previous config to be kept, got a cache size of %v", watcher.Current().Cache.Size)
+	}
+	if len(notified) != 2 {
+		t.Errorf("expected subscribers to not be told about an invalid config, got %v", notified)
+	}
+}
+
+func TestConfigWatcherSubscriberCallsBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "portal.yaml")
+	err := os.WriteFile(path, []byte("api:\n  address: http://localhost:8081\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-config", path}
+	cfg, err := services.LoadConfig(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A subscriber that adds another one would hang if the watcher was still locked.
+	watcher := services.NewConfigWatcher(cfg, args)
+	watcher.Subscribe(func(old services.Config, new services.Config) {
+		watcher.Subscribe(func(old services.Config, new services.Config) {})
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- watcher.Reload()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the reload never finished")
+	}
+}
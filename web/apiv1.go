@@ -0,0 +1,453 @@
+package web
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/jtom38/newsbot/portal/api"
+	"github.com/jtom38/newsbot/portal/services"
+)
+
+const (
+	ApiVersion = "v1"
+
+	apiDefaultLimit = 20
+	apiMaxLimit     = 100
+
+	// Filtered article lists can skip a lot of articles, so a request stops after this many collector pages
+	// and hands back a cursor to carry on from.
+	apiMaxScanPages = 5
+)
+
+// Every list in the api is returned in this, Next is the cursor for the following page and blank on the last one.
+type ApiList struct {
+	Items interface{} `json:"items"`
+	Next  string      `json:"next"`
+}
+
+type ApiError struct {
+	Error     string `json:"error"`
+	RequestID string `json:"requestId"`
+}
+
+// The position in a list, Page is the collector page for articles and Offset the item in it.
+// Clients get it base64 encoded and should not build their own.
+type apiCursor struct {
+	Page   int
+	Offset int
+}
+
+func (c apiCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%v.%v", c.Page, c.Offset)))
+}
+
+func parseCursor(value string) (apiCursor, error) {
+	var c apiCursor
+	if value == "" {
+		return c, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, errors.New("the cursor is not valid")
+	}
+
+	page, offset, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return c, errors.New("the cursor is not valid")
+	}
+	c.Page, err = strconv.Atoi(page)
+	if err != nil || c.Page < 0 {
+		return c, errors.New("the cursor is not valid")
+	}
+	c.Offset, err = strconv.Atoi(offset)
+	if err != nil || c.Offset < 0 {
+		return c, errors.New("the cursor is not valid")
+	}
+
+	return c, nil
+}
+
+// The paging and filter parameters that every list takes.
+type apiQuery struct {
+	Cursor apiCursor
+	Limit  int
+
+	Source    string
+	SourceID  uuid.UUID
+	WebhookID uuid.UUID
+	Tag       string
+	// Nil when the list is not filtered on it.
+	Enabled *bool
+}
+
+func parseApiQuery(r *http.Request) (apiQuery, error) {
+	values := r.URL.Query()
+	q := apiQuery{
+		Limit:  apiDefaultLimit,
+		Source: values.Get("source"),
+		Tag:    values.Get("tag"),
+	}
+
+	var errs []error
+	var err error
+	q.Cursor, err = parseCursor(values.Get("cursor"))
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if value := values.Get("limit"); value != "" {
+		q.Limit, err = strconv.Atoi(value)
+		if err != nil || q.Limit < 1 || q.Limit > apiMaxLimit {
+			errs = append(errs, fmt.Errorf("limit has to be a number from 1 to %v", apiMaxLimit))
+		}
+	}
+
+	if value := values.Get("enabled"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, errors.New("enabled has to be true or false"))
+		}
+		q.Enabled = &enabled
+	}
+
+	for name, id := range map[string]*uuid.UUID{"sourceId": &q.SourceID, "webhookId": &q.WebhookID} {
+		if value := values.Get(name); value != "" {
+			*id, err = uuid.Parse(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%v is not a valid ID", name))
+			}
+		}
+	}
+
+	return q, errors.Join(errs...)
+}
+
+// This is a json api for apps and bots that would rather talk to the portal than the collector.
+// Articles and subscriptions come back joined with their sources and web hooks so a list is a single request.
+func (s *HttpServer) apiV1Router() http.Handler {
+	r := chi.NewRouter()
+
+	r.Get("/articles", s.ApiListArticles)
+	r.Get("/articles/{ID}", s.ApiGetArticle)
+	r.Get("/sources", s.ApiListSources)
+	r.Get("/sources/{ID}", s.ApiGetSource)
+
+	// Web hooks are only shown to admins on the settings pages as well, once there are logins.
+	r.Group(func(r chi.Router) {
+		r.Use(requireApiRole(services.RoleAdmin))
+		r.Get("/webhooks", s.ApiListWebhooks)
+		r.With(requireFeature(services.Feature_Subscriptions)).Get("/subscriptions", s.ApiListSubscriptions)
+	})
+
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		writeApiError(w, r, http.StatusNotFound, errors.New("the route does not exist"))
+	})
+
+	return r
+}
+
+// Middleware that answers with a json error when the request does not have the role.
+// Without logins everything is allowed, like on the settings pages.
+func requireApiRole(need services.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if roleAllows(r.Context(), need) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if userFrom(r.Context()) == "" {
+				writeApiError(w, r, http.StatusUnauthorized, errors.New("log in to use this route"))
+				return
+			}
+			writeApiError(w, r, http.StatusForbidden, fmt.Errorf("this route needs the %v role", need))
+		})
+	}
+}
+
+// /api/v1/articles?source=&sourceId=&tag=&limit=&cursor=
+func (s *HttpServer) ApiListArticles(w http.ResponseWriter, r *http.Request) {
+	q, err := parseApiQuery(r)
+	if err != nil {
+		writeApiError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	l := newLoader(s.api, 0)
+	sources, _, err := l.Sources(r.Context())
+	if err != nil {
+		writeApiError(w, r, http.StatusBadGateway, err)
+		return
+	}
+
+	articles, next, err := findArticles(r.Context(), l.ArticlesPage, q, sources)
+	if err != nil {
+		writeApiError(w, r, http.StatusBadGateway, err)
+		return
+	}
+
+	items := []ArticleJson{}
+	for _, item := range articles {
+		source := sources[item.SourceID]
+		items = append(items, newArticleJson(item, &source))
+	}
+
+	writeApi(w, r, ApiList{Items: items, Next: next})
+}
+
+// Reads one page of articles from the collector, from every source when the ID is blank.
+type articlePager func(ctx context.Context, sourceID uuid.UUID, page int) ([]api.Article, error)
+
+// Returns up to the limit of articles from the cursor on that match the filters, and the cursor for the ones after them.
+// The collector can only page through articles, so the source type and tag are filtered here.
+func findArticles(ctx context.Context, pager articlePager, q apiQuery, sources map[uuid.UUID]api.Source) ([]api.Article, string, error) {
+	match := func(item api.Article) bool {
+		if q.Source != "" && !strings.EqualFold(sources[item.SourceID].Source, q.Source) {
+			return false
+		}
+		if q.Tag != "" && !containsFold(item.Tags, q.Tag) {
+			return false
+		}
+		return true
+	}
+
+	items := []api.Article{}
+	cursor := q.Cursor
+	for scanned := 0; scanned < apiMaxScanPages; scanned++ {
+		batch, err := pager(ctx, q.SourceID, cursor.Page)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(batch) == 0 {
+			return items, "", nil
+		}
+
+		for i := cursor.Offset; i < len(batch); i++ {
+			if !match(batch[i]) {
+				continue
+			}
+
+			items = append(items, batch[i])
+			if len(items) == q.Limit {
+				if i+1 == len(batch) {
+					return items, apiCursor{Page: cursor.Page + 1}.String(), nil
+				}
+				return items, apiCursor{Page: cursor.Page, Offset: i + 1}.String(), nil
+			}
+		}
+
+		cursor = apiCursor{Page: cursor.Page + 1}
+	}
+
+	return items, cursor.String(), nil
+}
+
+// /api/v1/articles/{ID}
+func (s *HttpServer) ApiGetArticle(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "ID"))
+	if err != nil {
+		writeApiError(w, r, http.StatusNotFound, errors.New("the ID is not valid"))
+		return
+	}
+
+	article, err := s.api.Articles().Get(r.Context(), id)
+	if err != nil {
+		writeApiLookupError(w, r, "article", err)
+		return
+	}
+
+	source, err := s.api.Sources().GetById(r.Context(), article.SourceID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "article has an invalid source", "article_id", article.ID, "source_id", article.SourceID, "error", err)
+		source = nil
+	}
+
+	writeApi(w, r, newArticleJson(*article, source))
+}
+
+// /api/v1/sources?source=&enabled=&tag=&limit=&cursor=
+func (s *HttpServer) ApiListSources(w http.ResponseWriter, r *http.Request) {
+	q, err := parseApiQuery(r)
+	if err != nil {
+		writeApiError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	items, err := s.api.Sources().List(r.Context())
+	if err != nil {
+		writeApiError(w, r, http.StatusBadGateway, err)
+		return
+	}
+
+	res := []SourceJson{}
+	if items != nil {
+		for _, item := range *items {
+			if item.Deleted ||
+				(q.Source != "" && !strings.EqualFold(item.Source, q.Source)) ||
+				(q.Enabled != nil && item.Enabled != *q.Enabled) ||
+				(q.Tag != "" && !containsFold(item.Tags, q.Tag)) {
+				continue
+			}
+			res = append(res, newSourceJson(item))
+		}
+	}
+
+	start, end, next := pageSlice(len(res), q)
+	writeApi(w, r, ApiList{Items: res[start:end], Next: next})
+}
+
+// /api/v1/sources/{ID}
+func (s *HttpServer) ApiGetSource(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "ID"))
+	if err != nil {
+		writeApiError(w, r, http.StatusNotFound, errors.New("the ID is not valid"))
+		return
+	}
+
+	source, err := s.api.Sources().GetById(r.Context(), id)
+	if err != nil {
+		writeApiLookupError(w, r, "source", err)
+		return
+	}
+
+	writeApi(w, r, newSourceJson(*source))
+}
+
+// /api/v1/webhooks?enabled=&limit=&cursor=
+func (s *HttpServer) ApiListWebhooks(w http.ResponseWriter, r *http.Request) {
+	q, err := parseApiQuery(r)
+	if err != nil {
+		writeApiError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	items, err := s.api.Outputs().DiscordWebHook().List(r.Context())
+	if err != nil {
+		writeApiError(w, r, http.StatusBadGateway, err)
+		return
+	}
+
+	res := []WebhookJson{}
+	if items != nil {
+		for _, item := range *items {
+			if q.Enabled != nil && item.Enabled != *q.Enabled {
+				continue
+			}
+			res = append(res, newWebhookJson(item))
+		}
+	}
+
+	start, end, next := pageSlice(len(res), q)
+	writeApi(w, r, ApiList{Items: res[start:end], Next: next})
+}
+
+// /api/v1/subscriptions?sourceId=&webhookId=&limit=&cursor=
+func (s *HttpServer) ApiListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	q, err := parseApiQuery(r)
+	if err != nil {
+		writeApiError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	items, err := s.api.Subscriptions().List(r.Context())
+	if err != nil {
+		writeApiError(w, r, http.StatusBadGateway, err)
+		return
+	}
+
+	l := newLoader(s.api, 0)
+	sources, _, err := l.Sources(r.Context())
+	if err != nil {
+		writeApiError(w, r, http.StatusBadGateway, err)
+		return
+	}
+
+	webhooks, _, err := l.Webhooks(r.Context())
+	if err != nil {
+		writeApiError(w, r, http.StatusBadGateway, err)
+		return
+	}
+
+	res := []SubscriptionJson{}
+	for _, item := range items {
+		if (q.SourceID != uuid.Nil && item.SourceId != q.SourceID) ||
+			(q.WebhookID != uuid.Nil && item.DiscordWebhookId != q.WebhookID) {
+			continue
+		}
+		res = append(res, SubscriptionJson{
+			ID:      item.ID,
+			Source:  newSourceJson(sources[item.SourceId]),
+			Webhook: newWebhookJson(webhooks[item.DiscordWebhookId]),
+		})
+	}
+
+	start, end, next := pageSlice(len(res), q)
+	writeApi(w, r, ApiList{Items: res[start:end], Next: next})
+}
+
+// Returns where the page starts and ends in a list the portal already has all of, and the cursor for the next one.
+// Only the offset of the cursor is used for these.
+func pageSlice(length int, q apiQuery) (int, int, string) {
+	start := q.Cursor.Offset
+	if start > length {
+		start = length
+	}
+
+	end := start + q.Limit
+	if end >= length {
+		return start, length, ""
+	}
+	return start, end, apiCursor{Offset: end}.String()
+}
+
+func containsFold(items []string, value string) bool {
+	for _, item := range items {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// The collector answers with an unexpected status code when there is no record with the ID.
+func writeApiLookupError(w http.ResponseWriter, r *http.Request, kind string, err error) {
+	if strings.HasPrefix(err.Error(), api.ErrInvalidStatusCode) {
+		writeApiError(w, r, http.StatusNotFound, fmt.Errorf("the %v does not exist", kind))
+		return
+	}
+	writeApiError(w, r, http.StatusBadGateway, err)
+}
+
+func writeApi(w http.ResponseWriter, r *http.Request, value interface{}) {
+	writeApiStatus(w, r, http.StatusOK, value)
+}
+
+func writeApiError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "api request failed", "path", r.URL.Path, "error", err)
+	}
+	writeApiStatus(w, r, status, ApiError{Error: err.Error(), RequestID: services.RequestID(r.Context())})
+}
+
+func writeApiStatus(w http.ResponseWriter, r *http.Request, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(value)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to write the api response", "path", r.URL.Path, "error", err)
+	}
+}
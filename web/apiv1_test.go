@@ -0,0 +1,206 @@
+package web_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/jtom38/newsbot/portal/web"
+)
+
+type apiArticles struct {
+	Items []web.ArticleJson `json:"items"`
+	Next  string            `json:"next"`
+}
+
+// Follows the cursors from the first page to the last and returns the titles in the order they came back.
+func walkArticles(t *testing.T, portal string, query url.Values) ([]string, int) {
+	var titles []string
+	requests := 0
+	for {
+		requests++
+		if requests > 50 {
+			t.Fatal("the cursors never reached the end of the list")
+		}
+
+		var page apiArticles
+		getJson(t, portal+"/api/v1/articles?"+query.Encode(), http.StatusOK, &page)
+		for _, item := range page.Items {
+			titles = append(titles, item.Title)
+		}
+
+		if page.Next == "" {
+			return titles, requests
+		}
+		query.Set("cursor", page.Next)
+	}
+}
+
+func TestApiCursorSpansCollectorPages(t *testing.T) {
+	collector := newMockCollector(t)
+	reddit := collector.addSource("reddit", "golang")
+	var expected []string
+	for i := 0; i < 7; i++ {
+		expected = append(expected, collector.addArticle(reddit, fmt.Sprintf("article %v", i)).Title)
+	}
+	portal := newPortal(t, collector, web.ServerOptions{})
+
+	// 2 does not divide the page size of 3, so cursors land in the middle of collector pages.
+	titles, requests := walkArticles(t, portal.URL, url.Values{"limit": {"2"}})
+	if !reflect.DeepEqual(titles, expected) {
+		t.Errorf("expected every article once and in order, got %v", titles)
+	}
+	if requests != 4 {
+		t.Errorf("expected 4 requests for 7 articles, got %v", requests)
+	}
+}
+
+func TestApiCursorWithFilters(t *testing.T) {
+	collector := newMockCollector(t)
+	reddit := collector.addSource("reddit", "golang")
+	youtube := collector.addSource("youtube", "gophers")
+	var expected []string
+	for i := 0; i < 10; i++ {
+		source := youtube
+		if i%3 == 0 {
+			source = reddit
+		}
+		item := collector.addArticle(source, fmt.Sprintf("article %v", i), "go")
+		if i%3 == 0 {
+			expected = append(expected, item.Title)
+		}
+	}
+	portal := newPortal(t, collector, web.ServerOptions{})
+
+	titles, _ := walkArticles(t, portal.URL, url.Values{"limit": {"1"}, "source": {"Reddit"}, "tag": {"GO"}})
+	if !reflect.DeepEqual(titles, expected) {
+		t.Errorf("expected %v, got %v", expected, titles)
+	}
+}
+
+func TestApiCursorStopsScanning(t *testing.T) {
+	collector := newMockCollector(t)
+	reddit := collector.addSource("reddit", "golang")
+	for i := 0; i < 20; i++ {
+		collector.addArticle(reddit, fmt.Sprintf("article %v", i))
+	}
+	collector.addArticle(reddit, "tagged", "go")
+	portal := newPortal(t, collector, web.ServerOptions{})
+
+	// Nothing matches on the first pages, so the portal hands back a cursor instead of reading every page at once.
+	var page apiArticles
+	getJson(t, portal.URL+"/api/v1/articles?tag=go", http.StatusOK, &page)
+	if len(page.Items) != 0 || page.Next == "" {
+		t.Fatalf("expected no articles and a cursor, got %v and '%v'", len(page.Items), page.Next)
+	}
+
+	titles, _ := walkArticles(t, portal.URL, url.Values{"tag": {"go"}})
+	if !reflect.DeepEqual(titles, []string{"tagged"}) {
+		t.Errorf("expected the tagged article, got %v", titles)
+	}
+}
+
+func TestApiBadQuery(t *testing.T) {
+	collector := newMockCollector(t)
+	collector.addArticle(collector.addSource("reddit", "golang"), "article")
+	portal := newPortal(t, collector, web.ServerOptions{})
+
+	cursor := func(value string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	queries := []string{
+		"cursor=!!!",
+		"cursor=" + cursor("1"),
+		"cursor=" + cursor("a.0"),
+		"cursor=" + cursor("-1.0"),
+		"cursor=" + cursor("0.-3"),
+		"limit=0",
+		"limit=-5",
+		"limit=101",
+		"limit=ten",
+		"sourceId=nope",
+		"enabled=maybe",
+	}
+	for _, query := range queries {
+		for _, route := range []string{"articles", "sources", "webhooks"} {
+			var res web.ApiError
+			getJson(t, portal.URL+"/api/v1/"+route+"?"+query, http.StatusBadRequest, &res)
+			if res.Error == "" || res.RequestID == "" {
+				t.Errorf("%v?%v: expected an error and the request ID, got %+v", route, query, res)
+			}
+		}
+	}
+
+	for _, limit := range []string{"1", "100"} {
+		getJson(t, portal.URL+"/api/v1/articles?limit="+limit, http.StatusOK, nil)
+	}
+}
+
+func TestApiLookupErrors(t *testing.T) {
+	collector := newMockCollector(t)
+	reddit := collector.addSource("reddit", "golang")
+	article := collector.addArticle(reddit, "article")
+	portal := newPortal(t, collector, web.ServerOptions{})
+
+	var found web.ArticleJson
+	getJson(t, portal.URL+"/api/v1/articles/"+article.ID.String(), http.StatusOK, &found)
+	if found.ID != article.ID || found.Source == nil || found.Source.ID != reddit.ID {
+		t.Errorf("expected the article with its source, got %+v", found)
+	}
+
+	// The collector does not know these IDs.
+	for _, route := range []string{"articles", "sources"} {
+		var res web.ApiError
+		getJson(t, portal.URL+"/api/v1/"+route+"/"+uuid.New().String(), http.StatusNotFound, &res)
+		if res.Error == "" {
+			t.Errorf("%v: expected an error", route)
+		}
+	}
+
+	getJson(t, portal.URL+"/api/v1/articles/not-an-id", http.StatusNotFound, nil)
+	getJson(t, portal.URL+"/api/v1/nothing", http.StatusNotFound, nil)
+
+	// A collector that is down is not the same as a missing record.
+	collector.Close()
+	getJson(t, portal.URL+"/api/v1/sources/"+reddit.ID.String(), http.StatusBadGateway, nil)
+}
+
+func TestApiWebhooksNeedAdmin(t *testing.T) {
+	collector := newMockCollector(t)
+	reddit := collector.addSource("reddit", "golang")
+	collector.addArticle(reddit, "article")
+	collector.subscribe(collector.addWebhook("home", "news"), reddit)
+	portal, _ := newUsersPortal(t, collector)
+
+	_, _, viewer := login(t, portal, "viewer", "viewer password")
+	_, _, admin := login(t, portal, "admin", "admin password")
+
+	cases := []struct {
+		name    string
+		browser *http.Client
+		status  int
+	}{
+		{name: "nobody", browser: http.DefaultClient, status: http.StatusUnauthorized},
+		{name: "viewer", browser: viewer, status: http.StatusForbidden},
+		{name: "admin", browser: admin, status: http.StatusOK},
+	}
+	for _, c := range cases {
+		for _, route := range []string{"webhooks", "subscriptions"} {
+			res, body := do(t, c.browser, http.MethodGet, portal+"/api/v1/"+route, nil)
+			if res.StatusCode != c.status {
+				t.Errorf("%v %v: expected %v, got %v: %v", c.name, route, c.status, res.StatusCode, body)
+			}
+		}
+
+		// The articles and sources stay open.
+		res, body := do(t, c.browser, http.MethodGet, portal+"/api/v1/articles", nil)
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("%v articles: expected 200, got %v: %v", c.name, res.StatusCode, body)
+		}
+	}
+}
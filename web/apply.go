@@ -0,0 +1,123 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jtom38/newsbot/portal/admin"
+)
+
+const (
+	// Manifests and backups are small, this only keeps a bad upload from using up memory.
+	maxUploadSize = 1 << 20
+
+	applyModePlan  = "plan"
+	applyModeApply = "apply"
+)
+
+var (
+	pageSettingsApply = parseSettings("templates/settings/apply.html")
+)
+
+type ApplyParam struct {
+	Title    string
+	Subtitle string
+	Errors   []string
+	Manifest string
+	Prune    bool
+	Plan     *admin.Plan
+	Applied  []admin.Change
+
+	// The hash of the plan that was shown, it is sent back with the apply button.
+	PlanHash string
+}
+
+// /settings/apply
+func (s SettingsRouter) ApplyForm(w http.ResponseWriter, r *http.Request) {
+	param := ApplyParam{
+		Title:    "Apply a Manifest",
+		Subtitle: "Describe your sources, web hooks and subscriptions in one file",
+	}
+	render(w, r, pageSettingsApply, param)
+}
+
+// This plans the uploaded or pasted manifest, and applies it when the apply button was used.
+// The manifest is sent back with the plan so it can be applied after it has been reviewed.
+func (s SettingsRouter) ApplyPost(w http.ResponseWriter, r *http.Request) {
+	param := ApplyParam{
+		Title:    "Apply a Manifest",
+		Subtitle: "Review the plan before applying it",
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	err := r.ParseMultipartForm(maxUploadSize)
+	if err != nil && err != http.ErrNotMultipart {
+		param.Errors = append(param.Errors, err.Error())
+		render(w, r, pageSettingsApply, param)
+		return
+	}
+
+	param.Manifest = r.FormValue("manifest")
+	param.Prune = r.FormValue("prune") == "on"
+
+	file, _, err := r.FormFile("file")
+	if err == nil {
+		defer file.Close()
+		body, err := io.ReadAll(file)
+		if err != nil {
+			param.Errors = append(param.Errors, err.Error())
+			render(w, r, pageSettingsApply, param)
+			return
+		}
+		if len(body) > 0 {
+			param.Manifest = string(body)
+		}
+	}
+
+	if param.Manifest == "" {
+		param.Errors = append(param.Errors, "Upload a manifest or paste one in")
+		render(w, r, pageSettingsApply, param)
+		return
+	}
+
+	m, err := admin.ParseManifest(bytes.NewBufferString(param.Manifest))
+	if err != nil {
+		param.Errors = append(param.Errors, err.Error())
+		render(w, r, pageSettingsApply, param)
+		return
+	}
+
+	plan, err := admin.NewPlan(r.Context(), s._api, m, admin.PlanOptions{Prune: param.Prune})
+	if err != nil {
+		param.Errors = append(param.Errors, err.Error())
+		render(w, r, pageSettingsApply, param)
+		return
+	}
+	param.Plan = &plan
+	param.PlanHash = plan.Hash()
+	for _, conflict := range plan.Conflicts {
+		param.Errors = append(param.Errors, fmt.Sprintf("Conflict: %v", conflict))
+	}
+
+	if r.FormValue("mode") != applyModeApply || len(plan.Conflicts) > 0 {
+		render(w, r, pageSettingsApply, param)
+		return
+	}
+
+	// The collector or the manifest could have changed since the plan was shown, only apply what was reviewed.
+	if r.FormValue("plan") != param.PlanHash {
+		param.Errors = append(param.Errors, "The plan changed since it was reviewed, check the new plan before applying it")
+		render(w, r, pageSettingsApply, param)
+		return
+	}
+
+	param.Applied, err = plan.Apply(r.Context(), s._api)
+	if err != nil {
+		param.Errors = append(param.Errors, err.Error())
+	}
+	param.Subtitle = fmt.Sprintf("Applied %v of %v changes", len(param.Applied), len(plan.Changes))
+
+	render(w, r, pageSettingsApply, param)
+}
@@ -3,7 +3,7 @@ package web
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 
@@ -11,6 +11,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/jtom38/newsbot/portal/api"
+	"github.com/jtom38/newsbot/portal/services"
 )
 
 var (
@@ -27,7 +28,7 @@ func (s *HttpServer) articlesRouter() http.Handler {
 	r.Get("/", s.ArticleIndex)
 	r.Get("/list", s.ArticleList)
 	r.Get("/newest", s.ArticleList)
-	r.Get("/list/card", s.ArticleListCards)
+	r.With(requireFeature(services.Feature_CardView)).Get("/list/card", s.ArticleListCards)
 
 	r.Route("/{ID}", func(r chi.Router) {
 		r.Get("/", s.DisplayArticleById)
@@ -36,7 +37,7 @@ func (s *HttpServer) articlesRouter() http.Handler {
 	r.Get("/sources", s.ListArticleSources)
 	r.Route("/sources/{ID}", func(r chi.Router) {
 		r.Get("/list", s.ListArticlesBySource)
-		r.Get("/card", s.CardArticlesBySource)
+		r.With(requireFeature(services.Feature_CardView)).Get("/card", s.CardArticlesBySource)
 	})
 	return r
 }
@@ -50,21 +51,19 @@ type TitlesParam struct {
 type ErrorParam struct {
 	Title    string
 	Subtitle string
+	Errors   []string
 	Code     int
 	Error    string
 }
 
 // /articles
 func (s *HttpServer) ArticleIndex(w http.ResponseWriter, r *http.Request) {
-	var err error
 	param := TitlesParam{
 		Title:    "Articles",
 		Subtitle: "Placeholder",
 	}
 
-	if pageArticlesIndex.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageArticlesIndex, param)
 }
 
 type ListArticleParam struct {
@@ -88,7 +87,7 @@ func (s *HttpServer) ArticleList(w http.ResponseWriter, r *http.Request) {
 	items, err := s.api.Articles().List(r.Context(), api.ArticlesListParam{})
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageArticlesList.Execute(w, param)
+		render(w, r, pageArticlesList, param)
 		return
 	}
 
@@ -96,7 +95,8 @@ func (s *HttpServer) ArticleList(w http.ResponseWriter, r *http.Request) {
 	for _, item := range items {
 		source, err := s.api.Sources().GetById(r.Context(), item.SourceID)
 		if err != nil {
-			//log.Printf("Article '%v', has a invalid SourceID", item.ID)
+			slog.WarnContext(r.Context(), "article has an invalid source", "article_id", item.ID, "source_id", item.SourceID, "error", err)
+			continue
 		}
 		//var s api.Source
 		s := *source
@@ -109,7 +109,7 @@ func (s *HttpServer) ArticleList(w http.ResponseWriter, r *http.Request) {
 	}
 	param.Items = &details
 
-	pageArticlesList.Execute(w, param)
+	render(w, r, pageArticlesList, param)
 }
 
 func (s *HttpServer) ArticleListCards(w http.ResponseWriter, r *http.Request) {
@@ -120,7 +120,7 @@ func (s *HttpServer) ArticleListCards(w http.ResponseWriter, r *http.Request) {
 
 	items, err := s.api.Articles().List(r.Context(), api.ArticlesListParam{})
 	if err != nil {
-		errorPage.Execute(w, ErrorParam{
+		render(w, r, errorPage, ErrorParam{
 			Title: "This didn't load correctly...",
 			Error: err.Error(),
 		})
@@ -131,7 +131,8 @@ func (s *HttpServer) ArticleListCards(w http.ResponseWriter, r *http.Request) {
 	for _, item := range items {
 		source, err := s.api.Sources().GetById(r.Context(), item.SourceID)
 		if err != nil {
-			//log.Printf("Article '%v', has a invalid SourceID", item.ID)
+			slog.WarnContext(r.Context(), "article has an invalid source", "article_id", item.ID, "source_id", item.SourceID, "error", err)
+			continue
 		}
 		//var s api.Source
 		s := *source
@@ -144,7 +145,7 @@ func (s *HttpServer) ArticleListCards(w http.ResponseWriter, r *http.Request) {
 	}
 	param.Items = &details
 
-	pageArticlesListCards.Execute(w, param)
+	render(w, r, pageArticlesListCards, param)
 }
 
 // This struct contains extra details not exposed by the API
@@ -172,7 +173,7 @@ func (s *HttpServer) ListArticleSources(w http.ResponseWriter, r *http.Request)
 	records, err := s.api.Sources().List(r.Context())
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageArticlesListSources.Execute(w, param)
+		render(w, r, pageArticlesListSources, param)
 		return
 	}
 
@@ -185,21 +186,21 @@ func (s *HttpServer) ListArticleSources(w http.ResponseWriter, r *http.Request)
 
 	param.Items = &activeItems
 
-	pageArticlesListSources.Execute(w, param)
+	render(w, r, pageArticlesListSources, param)
 }
 
-func (s *HttpServer) getArticlesBySourceId(ID uuid.UUID) ([]ListArticlesDetailsParam, error) {
+func (s *HttpServer) getArticlesBySourceId(ctx context.Context, ID uuid.UUID) ([]ListArticlesDetailsParam, error) {
 	var details []ListArticlesDetailsParam
 
-	items, err := s.api.Articles().ListBySourceId(context.TODO(), ID, 0)
+	items, err := s.api.Articles().ListBySourceId(ctx, ID, 0)
 	if err != nil {
 		return details, err
 	}
 
 	for _, item := range *items {
-		source, err := s.api.Sources().GetById(context.TODO(), item.SourceID)
+		source, err := s.api.Sources().GetById(ctx, item.SourceID)
 		if err != nil {
-			log.Printf("Article '%v', has a invalid SourceID", item.ID)
+			slog.WarnContext(ctx, "article has an invalid source", "article_id", item.ID, "source_id", item.SourceID, "error", err)
 		}
 		//var s api.Source
 		s := *source
@@ -228,7 +229,7 @@ func (s *HttpServer) ListArticlesBySource(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	details, err := s.getArticlesBySourceId(uid)
+	details, err := s.getArticlesBySourceId(r.Context(), uid)
 	if err != nil {
 		w.Write([]byte(err.Error()))
 	}
@@ -238,7 +239,7 @@ func (s *HttpServer) ListArticlesBySource(w http.ResponseWriter, r *http.Request
 	}
 
 	param.Items = &details
-	pageArticlesList.Execute(w, param)
+	render(w, r, pageArticlesList, param)
 }
 
 func (s *HttpServer) CardArticlesBySource(w http.ResponseWriter, r *http.Request) {
@@ -254,7 +255,7 @@ func (s *HttpServer) CardArticlesBySource(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	details, err := s.getArticlesBySourceId(uid)
+	details, err := s.getArticlesBySourceId(r.Context(), uid)
 	if err != nil {
 		w.Write([]byte(err.Error()))
 	}
@@ -262,7 +263,7 @@ func (s *HttpServer) CardArticlesBySource(w http.ResponseWriter, r *http.Request
 	param.Title = fmt.Sprintf("Newest posts from %v", details[0].Source.Name)
 
 	param.Items = &details
-	pageArticlesListCards.Execute(w, param)
+	render(w, r, pageArticlesListCards, param)
 }
 
 type DisplayArticleParams struct {
@@ -282,14 +283,14 @@ func (s *HttpServer) DisplayArticleById(w http.ResponseWriter, r *http.Request)
 	uuid, err := uuid.Parse(id)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageArticlesDisplay.Execute(w, param)
+		render(w, r, pageArticlesDisplay, param)
 		return
 	}
 
 	article, err := s.api.Articles().Get(r.Context(), uuid)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageArticlesDisplay.Execute(w, param)
+		render(w, r, pageArticlesDisplay, param)
 		return
 	}
 	param.Article = article
@@ -298,11 +299,11 @@ func (s *HttpServer) DisplayArticleById(w http.ResponseWriter, r *http.Request)
 	source, err := s.api.Sources().GetById(r.Context(), article.SourceID)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageArticlesDisplay.Execute(w, param)
+		render(w, r, pageArticlesDisplay, param)
 		return
 	}
 
 	param.Source = source
 	param.Subtitle = fmt.Sprintf("%v - %v", strings.ToUpper(source.Name), strings.ToUpper(source.Source))
-	pageArticlesDisplay.Execute(w, param)
+	render(w, r, pageArticlesDisplay, param)
 }
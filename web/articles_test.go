@@ -0,0 +1,30 @@
+package web_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/jtom38/newsbot/portal/api"
+	"github.com/jtom38/newsbot/portal/web"
+)
+
+func TestArticleListSkipsMissingSources(t *testing.T) {
+	collector := newMockCollector(t)
+	collector.addArticle(collector.addSource("reddit", "golang"), "Go 1.22 is out")
+	collector.addArticle(api.Source{ID: uuid.New()}, "Nobody knows the source")
+	portal := newPortal(t, collector, web.ServerOptions{})
+
+	// The article without a source is left out instead of taking the page down.
+	for _, path := range []string{"/articles/list", "/articles/list/card"} {
+		res, body := do(t, http.DefaultClient, http.MethodGet, portal.URL+path, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%v: expected 200, got %v: %v", path, res.StatusCode, body)
+		}
+		if !strings.Contains(body, "Go 1.22 is out") || strings.Contains(body, "Nobody knows the source") {
+			t.Errorf("%v: expected only the article with a source, got %v", path, body)
+		}
+	}
+}
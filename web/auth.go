@@ -0,0 +1,356 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jtom38/newsbot/portal/services"
+)
+
+const (
+	sessionCookie = "newsbot_session"
+
+	// How many bytes of randomness go into a session token.
+	sessionTokenSize = 32
+)
+
+var (
+	pageLogin = parse("templates/login.html")
+)
+
+type session struct {
+	user services.User
+
+	// Users from the users file are read again on every request, users from a provider keep the role they logged in with.
+	local bool
+
+	expires time.Time
+}
+
+// sessionStore keeps the logins in memory, so everyone has to log in again after a restart.
+type sessionStore struct {
+	timeout time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]session
+}
+
+func newSessionStore(timeout time.Duration) *sessionStore {
+	return &sessionStore{
+		timeout:  timeout,
+		sessions: make(map[string]session),
+	}
+}
+
+// Returns a random string that can not be guessed, used for session tokens and login states.
+func randomToken() (string, error) {
+	b := make([]byte, sessionTokenSize)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Returns a new token for the user and when it stops working.
+func (s *sessionStore) create(user services.User, local bool) (string, time.Time, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expires := time.Now().Add(s.timeout)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Logins that were never logged out of would pile up, so clear them out while we are here.
+	now := time.Now()
+	for key, item := range s.sessions {
+		if now.After(item.expires) {
+			delete(s.sessions, key)
+		}
+	}
+
+	s.sessions[token] = session{user: user, local: local, expires: expires}
+	return token, expires, nil
+}
+
+// Returns the session for the token when it has not expired.
+func (s *sessionStore) lookup(token string) (session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.sessions[token]
+	if !ok {
+		return session{}, false
+	}
+	if time.Now().After(item.expires) {
+		delete(s.sessions, token)
+		return session{}, false
+	}
+	return item, true
+}
+
+func (s *sessionStore) remove(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, token)
+}
+
+type authKey struct{}
+
+// What the pages need to know about the login for a request.
+type authState struct {
+	// False when there is no way to log in and everyone can change everything.
+	enabled bool
+
+	// True when there is a users file, so there are users to manage.
+	localUsers bool
+
+	// Nil when nobody has logged in.
+	user *services.User
+}
+
+// Middleware that puts the logged in user on the context so pages can show who it is and what they can change.
+// Local users are read from the store on every request, so a new role or a deleted user takes effect right away.
+// A new password logs out everyone who logged in with the old one.
+func (s *HttpServer) withSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := authState{enabled: s.loginsEnabled(), localUsers: s.users != nil}
+
+		if cookie, err := r.Cookie(sessionCookie); err == nil && state.enabled {
+			if item, ok := s.sessions.lookup(cookie.Value); ok {
+				user := item.user
+				if item.local {
+					user, ok = s.users.Get(item.user.Name)
+					ok = ok && user.PasswordHash == item.user.PasswordHash
+				}
+				if ok {
+					state.user = &user
+				} else {
+					s.sessions.remove(cookie.Value)
+				}
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), authKey{}, state)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func authFrom(ctx context.Context) authState {
+	state, _ := ctx.Value(authKey{}).(authState)
+	return state
+}
+
+// Returns the name of the logged in user, or a blank string when nobody is.
+func userFrom(ctx context.Context) string {
+	state := authFrom(ctx)
+	if state.user == nil {
+		return ""
+	}
+	return state.user.Name
+}
+
+// Returns true when the request can see pages that need the role.
+// Without a users file there is nobody to check, so everything is allowed.
+func roleAllows(ctx context.Context, need services.Role) bool {
+	state := authFrom(ctx)
+	if !state.enabled {
+		return true
+	}
+	return state.user != nil && state.user.Role.Allows(need)
+}
+
+// Middleware that sends anyone who has not logged in to the login page.
+// Requests that want json get a 401 instead, a redirect to a form is no use to a script.
+func (s *HttpServer) requireLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.loginsEnabled() || userFrom(r.Context()) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if wantsJson(r) {
+			render(w, r, pageError, ErrorParam{
+				Title:    "Log in",
+				Subtitle: "You need to log in to see this page",
+				Code:     http.StatusUnauthorized,
+				Error:    http.StatusText(http.StatusUnauthorized),
+			})
+			return
+		}
+
+		login := basePathFrom(r.Context()) + "/login?next=" + url.QueryEscape(r.URL.RequestURI())
+		http.Redirect(w, r, login, http.StatusSeeOther)
+	})
+}
+
+// Middleware that turns away users whose role does not allow the route.
+// It goes after requireLogin, so anyone who gets here without a role is logged in.
+func requireRole(need services.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if roleAllows(r.Context(), need) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			render(w, r, pageError, ErrorParam{
+				Title:    "Not allowed",
+				Subtitle: "Ask an admin if you need to change this",
+				Code:     http.StatusForbidden,
+				Error:    fmt.Sprintf("this page needs the %v role", need),
+			})
+		})
+	}
+}
+
+type LoginParam struct {
+	Title    string
+	Subtitle string
+	Errors   []string
+
+	// Where to go once the login worked.
+	Next string
+	Name string
+
+	// Shows the user name and password form when there is a users file.
+	Password bool
+
+	// The name on the button to log in with a provider, blank when there is none.
+	Provider string
+}
+
+func (s *HttpServer) newLoginParam(next string) LoginParam {
+	param := LoginParam{
+		Title:    "Log in",
+		Subtitle: "The settings pages need a login",
+		Next:     next,
+		Password: s.users != nil,
+	}
+	if s.oidc != nil {
+		param.Provider = s.oidc.cfg.Name
+	}
+	return param
+}
+
+// Returns true when there is some way to log in, otherwise everyone can change everything.
+func (s *HttpServer) loginsEnabled() bool {
+	return s.users != nil || s.oidc != nil
+}
+
+// /login
+func (s *HttpServer) LoginForm(w http.ResponseWriter, r *http.Request) {
+	next := safeNext(r, r.URL.Query().Get("next"))
+	if userFrom(r.Context()) != "" {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+
+	render(w, r, pageLogin, s.newLoginParam(next))
+}
+
+// /login
+func (s *HttpServer) LoginPost(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	param := s.newLoginParam(safeNext(r, r.PostForm.Get("next")))
+	param.Name = r.PostForm.Get("name")
+
+	keys := loginKeys(r, param.Name)
+	if wait := s.logins.wait(keys); wait > 0 {
+		slog.WarnContext(r.Context(), "turned away a login after too many failures", "user", param.Name, "remote", r.RemoteAddr)
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		param.Errors = append(param.Errors, fmt.Sprintf("too many failed logins, try again in %v", wait.Round(time.Second)))
+		render(w, r, pageLogin, param)
+		return
+	}
+
+	user, err := s.users.Authenticate(param.Name, r.PostForm.Get("password"))
+	if err != nil {
+		slog.WarnContext(r.Context(), "failed login", "user", param.Name, "remote", r.RemoteAddr)
+		s.logins.fail(keys)
+		param.Errors = append(param.Errors, err.Error())
+		render(w, r, pageLogin, param)
+		return
+	}
+	s.logins.succeed(param.Name)
+
+	s.startSession(w, r, user, true, param.Next)
+}
+
+// This logs the user in and sends them on to next.
+func (s *HttpServer) startSession(w http.ResponseWriter, r *http.Request, user services.User, local bool, next string) {
+	token, expires, err := s.sessions.create(user, local)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "unable to create a session", "error", err)
+		http.Error(w, "unable to log in", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, authCookie(r, sessionCookie, token, expires))
+	slog.InfoContext(r.Context(), "logged in", "user", user.Name, "role", user.Role, "local", local, "remote", r.RemoteAddr)
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// /logout
+func (s *HttpServer) Logout(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err == nil {
+		s.sessions.remove(cookie.Value)
+	}
+
+	// An expired cookie with the same name and path makes the browser drop it.
+	http.SetCookie(w, authCookie(r, sessionCookie, "", time.Unix(0, 0)))
+	http.Redirect(w, r, basePathFrom(r.Context())+"/", http.StatusSeeOther)
+}
+
+// The cookies are only sent back to the portal and only over https when the portal is reached that way.
+// SameSite keeps other sites from posting to the settings forms with them.
+func authCookie(r *http.Request, name string, value string, expires time.Time) *http.Cookie {
+	path := basePathFrom(r.Context())
+	if path == "" {
+		path = "/"
+	}
+
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.URL.Scheme == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// Returns the page to go to after logging in.
+// Only paths on the portal are allowed, so the login form can not be used to send someone to another site.
+func safeNext(r *http.Request, next string) string {
+	base := basePathFrom(r.Context())
+	fallback := base + "/"
+
+	u, err := url.Parse(next)
+	if err != nil || u.Scheme != "" || u.Host != "" || strings.HasPrefix(next, "//") || strings.Contains(next, "\\") {
+		return fallback
+	}
+	if !strings.HasPrefix(u.Path, base+"/") {
+		return fallback
+	}
+	return next
+}
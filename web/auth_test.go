@@ -0,0 +1,221 @@
+package web_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jtom38/newsbot/portal/services"
+	"github.com/jtom38/newsbot/portal/web"
+)
+
+// Starts a portal with a users file that has an admin, an editor and a viewer.
+func newUsersPortal(t *testing.T, collector *mockCollector) (string, *services.UserStore) {
+	users, err := services.OpenUserStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = users.Add("admin", "admin password", services.RoleAdmin)
+	if err == nil {
+		err = users.Add("editor", "editor password", services.RoleEditor)
+	}
+	if err == nil {
+		err = users.Add("viewer", "viewer password", services.RoleViewer)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	portal := newPortal(t, collector, web.ServerOptions{Users: users})
+	return portal.URL, users
+}
+
+// Logs in with the password form and returns the response and a browser that has the session.
+func login(t *testing.T, portal, name, password string) (*http.Response, string, *http.Client) {
+	jar, _ := cookiejar.New(nil)
+	browser := &http.Client{Jar: jar}
+
+	res, err := browser.PostForm(portal+"/login", url.Values{"name": {name}, "password": {password}, "next": {"/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, string(body), browser
+}
+
+// Returns true when the browser is still logged in, the settings answer a 401 to json requests otherwise.
+func loggedIn(t *testing.T, browser *http.Client, portal string) bool {
+	res, _ := do(t, browser, http.MethodGet, portal+"/settings", http.Header{"Accept": {"application/json"}})
+	return res.StatusCode != http.StatusUnauthorized
+}
+
+func TestSessionEndsWithPasswordChange(t *testing.T) {
+	portal, users := newUsersPortal(t, newMockCollector(t))
+
+	_, _, browser := login(t, portal, "viewer", "viewer password")
+	if !loggedIn(t, browser, portal) {
+		t.Fatal("expected the login to work")
+	}
+
+	err := users.SetRole("viewer", services.RoleEditor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loggedIn(t, browser, portal) {
+		t.Error("expected a new role to keep the session")
+	}
+
+	err = users.SetPassword("viewer", "a new password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loggedIn(t, browser, portal) {
+		t.Error("expected a new password to end the session")
+	}
+}
+
+func TestSessionEndsWithDeletedUser(t *testing.T) {
+	portal, users := newUsersPortal(t, newMockCollector(t))
+
+	_, _, browser := login(t, portal, "viewer", "viewer password")
+	if !loggedIn(t, browser, portal) {
+		t.Fatal("expected the login to work")
+	}
+
+	err := users.Delete("viewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loggedIn(t, browser, portal) {
+		t.Error("expected a deleted user to be logged out")
+	}
+
+	// Someone new with the same name does not get the old session back.
+	err = users.Add("viewer", "viewer password", services.RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loggedIn(t, browser, portal) {
+		t.Error("expected the old session to stay gone")
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	portal, _ := newUsersPortal(t, newMockCollector(t))
+
+	for i := 0; i < 5; i++ {
+		_, body, _ := login(t, portal, "admin", "guess")
+		if !strings.Contains(body, services.ErrInvalidLogin) {
+			t.Fatalf("expected the wrong password to fail, got %v", body)
+		}
+	}
+
+	res, body, browser := login(t, portal, "admin", "admin password")
+	if !strings.Contains(body, "too many failed logins") {
+		t.Errorf("expected the login to be turned away, got %v", body)
+	}
+	if res.Header.Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+	if loggedIn(t, browser, portal) {
+		t.Error("expected no session while the login is turned away")
+	}
+
+	// The guesses came from one address, so other users from it have to wait as well.
+	_, body, _ = login(t, portal, "viewer", "viewer password")
+	if !strings.Contains(body, "too many failed logins") {
+		t.Errorf("expected the address to be turned away, got %v", body)
+	}
+}
+
+func TestSettingsRoles(t *testing.T) {
+	portal, _ := newUsersPortal(t, newMockCollector(t))
+
+	browsers := make(map[string]*http.Client)
+	for _, name := range []string{"viewer", "editor", "admin"} {
+		_, _, browsers[name] = login(t, portal, name, name+" password")
+	}
+
+	cases := []struct {
+		user   string
+		path   string
+		status int
+	}{
+		{user: "viewer", path: "/settings/sources/reddit", status: http.StatusForbidden},
+		{user: "editor", path: "/settings/sources/reddit", status: http.StatusOK},
+		{user: "editor", path: "/settings/outputs/discord/webhooks", status: http.StatusForbidden},
+		{user: "editor", path: "/settings/export", status: http.StatusForbidden},
+		{user: "editor", path: "/settings/users", status: http.StatusForbidden},
+		{user: "editor", path: "/settings/features", status: http.StatusForbidden},
+		{user: "admin", path: "/settings/sources/reddit", status: http.StatusOK},
+		{user: "admin", path: "/settings/outputs/discord/webhooks", status: http.StatusOK},
+		{user: "admin", path: "/settings/export", status: http.StatusOK},
+		{user: "admin", path: "/settings/users", status: http.StatusOK},
+		{user: "admin", path: "/settings/features", status: http.StatusOK},
+	}
+	for _, c := range cases {
+		res, body := do(t, browsers[c.user], http.MethodGet, portal+c.path, nil)
+		if res.StatusCode != c.status {
+			t.Errorf("%v %v: expected %v, got %v", c.user, c.path, c.status, res.StatusCode)
+		}
+		if c.status == http.StatusForbidden && !strings.Contains(body, "Not allowed") {
+			t.Errorf("%v %v: expected the not allowed page, got %v", c.user, c.path, body)
+		}
+		if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") {
+			t.Errorf("%v %v: expected html, got '%v'", c.user, c.path, res.Header.Get("Content-Type"))
+		}
+	}
+}
+
+func TestMonitoringNeedsAdmin(t *testing.T) {
+	collector := newMockCollector(t)
+	portal, _ := newUsersPortal(t, collector)
+
+	_, _, viewer := login(t, portal, "viewer", "viewer password")
+	_, _, admin := login(t, portal, "admin", "admin password")
+	scraper := http.Header{"Accept": {"application/json"}}
+
+	cases := []struct {
+		name    string
+		browser *http.Client
+		status  int
+	}{
+		{name: "nobody", browser: http.DefaultClient, status: http.StatusUnauthorized},
+		{name: "viewer", browser: viewer, status: http.StatusForbidden},
+		{name: "admin", browser: admin, status: http.StatusOK},
+	}
+	for _, c := range cases {
+		for _, path := range []string{"/status", "/metrics"} {
+			res, body := do(t, c.browser, http.MethodGet, portal+path, scraper)
+			if res.StatusCode != c.status {
+				t.Errorf("%v %v: expected %v, got %v: %v", c.name, path, c.status, res.StatusCode, body)
+			}
+		}
+
+		// The probes never need a login.
+		for _, path := range []string{"/healthz", "/readyz"} {
+			res, body := do(t, c.browser, http.MethodGet, portal+path, nil)
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("%v %v: expected 200, got %v: %v", c.name, path, res.StatusCode, body)
+			}
+		}
+	}
+
+	// Without logins the portal is open, so are the status and metrics.
+	open := newPortal(t, collector, web.ServerOptions{})
+	for _, path := range []string{"/status", "/metrics"} {
+		res, body := do(t, http.DefaultClient, http.MethodGet, open.URL+path, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("%v without logins: expected 200, got %v: %v", path, res.StatusCode, body)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jtom38/newsbot/portal/admin"
+)
+
+var (
+	pageSettingsBackup = parseSettings("templates/settings/backup.html")
+)
+
+type BackupParam struct {
+	Title    string
+	Subtitle string
+	Errors   []string
+	Result   *admin.ImportResult
+}
+
+// /settings/export
+//
+// Shows the export and import forms, or downloads the backup when a format is given.
+func (s SettingsRouter) Export(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		param := BackupParam{
+			Title:    "Export and Import",
+			Subtitle: "Keep a copy of your sources, web hooks and subscriptions",
+		}
+		render(w, r, pageSettingsBackup, param)
+		return
+	}
+
+	param := ErrorParam{
+		Title:    "Failed to export",
+		Subtitle: "See the error for details.",
+		Code:     500,
+	}
+
+	if format != admin.FormatJson && format != admin.FormatYaml {
+		param.Code = http.StatusBadRequest
+		param.Error = admin.ErrBackupFormat
+		render(w, r, pageError, param)
+		return
+	}
+
+	b, err := admin.Export(r.Context(), s._api)
+	if err != nil {
+		param.Error = err.Error()
+		render(w, r, pageError, param)
+		return
+	}
+
+	contentType := "application/json"
+	if format == admin.FormatYaml {
+		contentType = "application/yaml"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"newsbot-%v.%v\"", b.ExportedAt.Format("20060102-150405"), format))
+	err = b.Write(w, format)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to write the backup", "format", format, "error", err)
+	}
+}
+
+// /settings/import
+func (s SettingsRouter) Import(w http.ResponseWriter, r *http.Request) {
+	param := BackupParam{
+		Title:    "Export and Import",
+		Subtitle: "The backup was not imported",
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		param.Errors = append(param.Errors, fmt.Sprintf("Upload a backup to import, %v", err))
+		render(w, r, pageSettingsBackup, param)
+		return
+	}
+	defer file.Close()
+
+	b, err := admin.ParseBackup(file)
+	if err != nil {
+		param.Errors = append(param.Errors, err.Error())
+		render(w, r, pageSettingsBackup, param)
+		return
+	}
+
+	res, err := admin.Import(r.Context(), s._api, b, admin.ImportOptions{})
+	param.Result = &res
+	if err != nil {
+		param.Errors = append(param.Errors, err.Error())
+		render(w, r, pageSettingsBackup, param)
+		return
+	}
+
+	param.Subtitle = fmt.Sprintf("Imported the backup from %v", b.ExportedAt.Format(time.RFC1123))
+	render(w, r, pageSettingsBackup, param)
+}
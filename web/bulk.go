@@ -0,0 +1,88 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jtom38/newsbot/portal/admin"
+)
+
+var (
+	pageSettingsSourcesBulk = parseSettings("templates/settings/sources/bulk.html", "templates/settings/sources/entries.html")
+)
+
+type BulkSourcesParam struct {
+	Title      string
+	Subtitle   string
+	Errors     []string
+	SourceName string
+	List       string
+	Entries    []admin.SourceEntry
+
+	// The number of entries that would be created, and if any of them have tags.
+	New     int
+	HasTags bool
+}
+
+// /settings/sources/{source}/bulk
+func (s SettingsRouter) BulkSourcesForm(source string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		param := BulkSourcesParam{
+			Title:      fmt.Sprintf("Add %v sources in bulk", source),
+			Subtitle:   "One per line, or CSV with the name, url and tags",
+			SourceName: source,
+		}
+		render(w, r, pageSettingsSourcesBulk, param)
+	}
+}
+
+// This checks every row of the list, and adds the new sources when the add button was used.
+func (s SettingsRouter) BulkSourcesPost(source string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		param := BulkSourcesParam{
+			Title:      fmt.Sprintf("Add %v sources in bulk", source),
+			Subtitle:   "Check every row before adding them",
+			SourceName: source,
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+		err := r.ParseForm()
+		if err != nil {
+			param.Errors = append(param.Errors, err.Error())
+			render(w, r, pageSettingsSourcesBulk, param)
+			return
+		}
+
+		param.List = r.Form.Get("list")
+		if strings.TrimSpace(param.List) == "" {
+			param.Errors = append(param.Errors, "The list is empty")
+			render(w, r, pageSettingsSourcesBulk, param)
+			return
+		}
+
+		entries, err := admin.ParseSourceList(source, strings.NewReader(param.List))
+		if err != nil {
+			param.Errors = append(param.Errors, err.Error())
+			render(w, r, pageSettingsSourcesBulk, param)
+			return
+		}
+
+		param.Entries, err = admin.CheckSources(r.Context(), s._api, entries)
+		if err != nil {
+			param.Errors = append(param.Errors, err.Error())
+			render(w, r, pageSettingsSourcesBulk, param)
+			return
+		}
+
+		param.New, param.HasTags = countNew(param.Entries)
+
+		if r.Form.Get("mode") == importModeImport {
+			param.Entries = admin.AddSources(r.Context(), s._api, param.Entries)
+			param.Subtitle = "Here is what was added"
+			param.New = 0
+		}
+
+		render(w, r, pageSettingsSourcesBulk, param)
+	}
+}
@@ -0,0 +1,87 @@
+package web
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+// This is what the portal knows about the collector it talks to.
+type CollectorState struct {
+	Address   string
+	Info      api.CollectorInfo
+	CheckedAt time.Time
+
+	// Set when the collector could not be reached.
+	Error error
+
+	// Set when the collector responded with a version the portal does not support.
+	VersionError error
+}
+
+// Holds the CollectorState so it can be updated while pages are being served.
+type collectorStatus struct {
+	mu    sync.RWMutex
+	state CollectorState
+}
+
+func newCollectorStatus(address string) *collectorStatus {
+	return &collectorStatus{
+		state: CollectorState{Address: address},
+	}
+}
+
+func (c *collectorStatus) set(info api.CollectorInfo, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.state.Info = info
+	c.state.CheckedAt = time.Now()
+	c.state.Error = nil
+	c.state.VersionError = nil
+	switch {
+	case api.IsVersionUnavailable(err):
+		c.state.VersionError = err
+	case err != nil:
+		c.state.Error = err
+	default:
+		c.state.VersionError = api.CheckCollectorVersion(info.Version)
+	}
+}
+
+func (c *collectorStatus) get() CollectorState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.state
+}
+
+// This checks in with the collector and records its version.
+// When wait is more than 0 it will keep trying with a backoff until the collector responds or the time runs out.
+func (s *HttpServer) CheckCollector(ctx context.Context, wait time.Duration) (CollectorState, error) {
+	var info api.CollectorInfo
+	var err error
+
+	if wait > 0 {
+		info, err = api.WaitForCollector(ctx, s.api, wait)
+	} else {
+		info, err = s.api.Info(ctx)
+	}
+	s.collector.set(info, err)
+
+	// A collector that could not say what version it runs is still up, the caller decides what to do with the VersionError.
+	state := s.collector.get()
+	if state.Error != nil {
+		return state, state.Error
+	}
+
+	version := info.Version
+	if version == "" {
+		version = "unknown"
+	}
+	slog.InfoContext(ctx, "connected to the collector", "address", state.Address, "version", version, "latency", info.Latency)
+
+	return state, nil
+}
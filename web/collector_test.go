@@ -0,0 +1,218 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jtom38/newsbot/portal/api"
+	"github.com/jtom38/newsbot/portal/services"
+	"github.com/jtom38/newsbot/portal/web"
+)
+
+// How many articles the mock collector returns on a page.
+const mockPageSize = 3
+
+// mockCollector is an in memory collector api for the portal to talk to.
+// Articles are returned newest first, in the order they were added.
+type mockCollector struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	sources       []api.Source
+	articles      []api.Article
+	webhooks      []api.DiscordWebHooks
+	subscriptions []api.Subscription
+
+	// Every request the portal made, like "/api/articles?page=1".
+	calls []string
+}
+
+func newMockCollector(t *testing.T) *mockCollector {
+	c := mockCollector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(c.serve))
+	t.Cleanup(c.Close)
+	return &c
+}
+
+func (c *mockCollector) addSource(source, name string, tags ...string) api.Source {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item := api.Source{ID: uuid.New(), Source: source, Name: name, Url: "https://example.com/" + name, Enabled: true, Tags: tags}
+	c.sources = append(c.sources, item)
+	return item
+}
+
+func (c *mockCollector) addArticle(source api.Source, title string, tags ...string) api.Article {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item := api.Article{
+		ID:       uuid.New(),
+		SourceID: source.ID,
+		Title:    title,
+		Url:      "https://example.com/articles/" + strings.ReplaceAll(title, " ", "-"),
+		Pubdate:  time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC).Add(-time.Duration(len(c.articles)) * time.Hour),
+		Tags:     tags,
+	}
+	c.articles = append(c.articles, item)
+	return item
+}
+
+func (c *mockCollector) addWebhook(server, channel string) api.DiscordWebHooks {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item := api.DiscordWebHooks{ID: uuid.New(), Server: server, Channel: channel, Url: "https://discord.com/api/webhooks/secret", Enabled: true}
+	c.webhooks = append(c.webhooks, item)
+	return item
+}
+
+func (c *mockCollector) subscribe(webhook api.DiscordWebHooks, source api.Source) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.subscriptions = append(c.subscriptions, api.Subscription{ID: uuid.New(), DiscordWebhookId: webhook.ID, SourceId: source.ID})
+}
+
+// Returns the requests that were made since the last time it was called.
+func (c *mockCollector) takeCalls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	calls := c.calls
+	c.calls = nil
+	return calls
+}
+
+func (c *mockCollector) serve(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, r.URL.RequestURI())
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/")
+	switch {
+	case path == "ping":
+		w.Write([]byte(`{"status":200}`))
+	case path == "version":
+		writePayload(w, map[string]string{"version": "0.2.0"})
+	case path == "articles":
+		writePayload(w, pageOf(c.articles, page))
+	case path == "articles/by/sourceid":
+		var items []api.Article
+		for _, item := range c.articles {
+			if item.SourceID.String() == r.URL.Query().Get("id") {
+				items = append(items, item)
+			}
+		}
+		writePayload(w, pageOf(items, page))
+	case strings.HasPrefix(path, "articles/"):
+		for _, item := range c.articles {
+			if item.ID.String() == strings.TrimPrefix(path, "articles/") {
+				writePayload(w, item)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case path == "sources":
+		writePayload(w, c.sources)
+	case strings.HasPrefix(path, "sources/"):
+		for _, item := range c.sources {
+			if item.ID.String() == strings.TrimPrefix(path, "sources/") {
+				writePayload(w, item)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case path == "discord/webhooks":
+		writePayload(w, c.webhooks)
+	case path == "subscriptions":
+		writePayload(w, c.subscriptions)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func pageOf(items []api.Article, page int) []api.Article {
+	start := page * mockPageSize
+	if start >= len(items) {
+		return []api.Article{}
+	}
+	end := start + mockPageSize
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
+
+func writePayload(w http.ResponseWriter, payload interface{}) {
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": 200, "payload": payload})
+}
+
+// Starts a portal in front of the collector, filling in the options it needs.
+func newPortal(t *testing.T, collector *mockCollector, opts web.ServerOptions) *httptest.Server {
+	opts.ApiEndpoint = collector.URL
+	if opts.Features == nil {
+		opts.Features = services.NewFeatureFlags(map[string]bool{})
+	}
+	if opts.SessionTimeout == 0 {
+		opts.SessionTimeout = time.Hour
+	}
+
+	server := web.NewServer(context.Background(), opts)
+	portal := httptest.NewServer(server.Router)
+	t.Cleanup(portal.Close)
+	return portal
+}
+
+// Sends the request and returns the response with the body read.
+func do(t *testing.T, client *http.Client, method, url string, header http.Header) (*http.Response, string) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, string(body)
+}
+
+// Decodes a json response into value, failing the test when the status is not the one expected.
+func getJson(t *testing.T, url string, status int, value interface{}) {
+	res, body := do(t, http.DefaultClient, http.MethodGet, url, http.Header{"Accept": {"application/json"}})
+	if res.StatusCode != status {
+		t.Fatalf("GET %v: expected %v, got %v: %v", url, status, res.StatusCode, body)
+	}
+	if value == nil {
+		return
+	}
+
+	err := json.Unmarshal([]byte(body), value)
+	if err != nil {
+		t.Fatal(fmt.Errorf("GET %v: %w: %v", url, err, body))
+	}
+}
@@ -0,0 +1,62 @@
+package web
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jtom38/newsbot/portal/services"
+)
+
+var (
+	pageSettingsFeatures = parseSettings("templates/settings/features.html")
+)
+
+type featuresKey struct{}
+
+// Middleware that makes the feature flags available to render.
+func withFeatures(flags *services.FeatureFlags) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), featuresKey{}, flags)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Returns the feature flags for the request, or nil when there are none.
+func featuresFrom(ctx context.Context) *services.FeatureFlags {
+	flags, _ := ctx.Value(featuresKey{}).(*services.FeatureFlags)
+	return flags
+}
+
+// Middleware that responds with a 404 when the feature is turned off.
+// The flag is checked on every request so a reload takes effect right away.
+func requireFeature(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !featuresFrom(r.Context()).Enabled(name) {
+				http.NotFound(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type FeaturesParam struct {
+	Title    string
+	Subtitle string
+	Errors   []string
+	Items    []services.FeatureState
+}
+
+// /settings/features
+func (s SettingsRouter) ListFeatures(w http.ResponseWriter, r *http.Request) {
+	param := FeaturesParam{
+		Title:    "Features",
+		Subtitle: "Parts of the portal that can be turned on or off",
+		Items:    featuresFrom(r.Context()).List(),
+	}
+
+	render(w, r, pageSettingsFeatures, param)
+}
@@ -0,0 +1,226 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+const (
+	FeedRss  = "rss"
+	FeedAtom = "atom"
+	FeedJson = "json"
+
+	// Feed readers poll, this lets them and any cache in front of the portal skip a few of those.
+	feedCacheControl = "public, max-age=300"
+
+	// The API can not filter articles by tag, so this many pages of the newest articles are searched.
+	feedTagPages = 3
+)
+
+var feedContentTypes = map[string]string{
+	FeedRss:  "application/rss+xml; charset=utf-8",
+	FeedAtom: "application/atom+xml; charset=utf-8",
+	FeedJson: "application/feed+json; charset=utf-8",
+}
+
+// This is what every feed format is written from.
+type feed struct {
+	Title       string
+	Description string
+	// The portal page the feed is about, and the url of the feed itself.
+	Link    string
+	Self    string
+	Updated time.Time
+	Items   []feedItem
+}
+
+type feedItem struct {
+	ID          uuid.UUID
+	Title       string
+	Link        string
+	Description string
+	Published   time.Time
+	Author      string
+	AuthorImage string
+	Thumbnail   string
+	Tags        []string
+}
+
+func (s *HttpServer) feedsRouter() http.Handler {
+	r := chi.NewRouter()
+	// Some readers check for changes with HEAD before they fetch the feed.
+	r.Use(middleware.GetHead)
+
+	r.Group(func(r chi.Router) {
+		r.Use(feedFormat)
+		r.Get("/all.{format}", s.AllFeed)
+		r.Get("/sources/{ID}.{format}", s.SourceFeed)
+		r.Get("/tags/{tag}.{format}", s.TagFeed)
+	})
+
+	return r
+}
+
+// Turns away formats we can not write before the handlers ask the collector for anything.
+func feedFormat(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := feedContentTypes[chi.URLParam(r, "format")]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// /feeds/all.{rss,atom,json}
+func (s *HttpServer) AllFeed(w http.ResponseWriter, r *http.Request) {
+	items, err := s.api.Articles().List(r.Context(), api.ArticlesListParam{})
+	if err != nil {
+		feedError(w, r, err)
+		return
+	}
+
+	base := baseURL(r)
+	writeFeed(w, r, newFeed(base, "newsbot", "The newest articles from every source", base+"/articles/list", items))
+}
+
+// /feeds/sources/{ID}.{rss,atom,json}
+func (s *HttpServer) SourceFeed(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "ID"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	source, err := s.api.Sources().GetById(r.Context(), id)
+	if err != nil {
+		feedError(w, r, err)
+		return
+	}
+
+	items, err := s.api.Articles().ListBySourceId(r.Context(), id, 0)
+	if err != nil {
+		feedError(w, r, err)
+		return
+	}
+
+	var articles []api.Article
+	if items != nil {
+		articles = *items
+	}
+
+	base := baseURL(r)
+	title := fmt.Sprintf("newsbot - %v %v", source.Source, source.Name)
+	description := fmt.Sprintf("The newest articles from %v", source.Name)
+	writeFeed(w, r, newFeed(base, title, description, fmt.Sprintf("%v/articles/sources/%v/list", base, id), articles))
+}
+
+// /feeds/tags/{tag}.{rss,atom,json}
+func (s *HttpServer) TagFeed(w http.ResponseWriter, r *http.Request) {
+	tag := chi.URLParam(r, "tag")
+
+	var articles []api.Article
+	for page := 0; page < feedTagPages; page++ {
+		items, err := s.api.Articles().List(r.Context(), api.ArticlesListParam{Page: int32(page)})
+		if err != nil {
+			feedError(w, r, err)
+			return
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		for _, item := range items {
+			if containsFold(item.Tags, tag) {
+				articles = append(articles, item)
+			}
+		}
+	}
+
+	base := baseURL(r)
+	title := fmt.Sprintf("newsbot - %v", tag)
+	description := fmt.Sprintf("The newest articles tagged %v", tag)
+	writeFeed(w, r, newFeed(base, title, description, base+"/articles/list", articles))
+}
+
+func newFeed(base, title, description, link string, articles []api.Article) feed {
+	f := feed{
+		Title:       title,
+		Description: description,
+		Link:        link,
+	}
+
+	for _, article := range articles {
+		item := feedItem{
+			ID:          article.ID,
+			Title:       article.Title,
+			Link:        article.Url,
+			Description: article.Description,
+			Published:   article.Pubdate,
+			Author:      article.AuthorName,
+			AuthorImage: article.AuthorImage,
+			Thumbnail:   article.Thumbnail,
+			Tags:        article.Tags,
+		}
+		if item.Link == "" {
+			item.Link = fmt.Sprintf("%v/articles/%v", base, article.ID)
+		}
+
+		if article.Pubdate.After(f.Updated) {
+			f.Updated = article.Pubdate
+		}
+		f.Items = append(f.Items, item)
+	}
+
+	return f
+}
+
+// This writes the feed in the format from the url, which feedFormat has already checked.
+// The ETag is a hash of the body so readers get a 304 until a new article shows up.
+func writeFeed(w http.ResponseWriter, r *http.Request, f feed) {
+	format := chi.URLParam(r, "format")
+	contentType := feedContentTypes[format]
+
+	self := *r.URL
+	self.RawQuery = ""
+	f.Self = strings.TrimSuffix(baseURL(r), basePathFrom(r.Context())) + self.Path
+
+	var body bytes.Buffer
+	var err error
+	switch format {
+	case FeedRss:
+		err = writeRss(&body, f)
+	case FeedAtom:
+		err = writeAtom(&body, f)
+	case FeedJson:
+		err = writeJsonFeed(&body, f)
+	}
+	if err != nil {
+		feedError(w, r, err)
+		return
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", feedCacheControl)
+	w.Header().Set("ETag", fmt.Sprintf(`"%v"`, hex.EncodeToString(sum[:16])))
+
+	http.ServeContent(w, r, "", f.Updated, bytes.NewReader(body.Bytes()))
+}
+
+func feedError(w http.ResponseWriter, r *http.Request, err error) {
+	slog.ErrorContext(r.Context(), "failed to build the feed", "error", err)
+	http.Error(w, "the feed could not be loaded from the collector", http.StatusBadGateway)
+}
@@ -0,0 +1,216 @@
+package web_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jtom38/newsbot/portal/web"
+)
+
+func newFeedPortal(t *testing.T) (*mockCollector, string) {
+	collector := newMockCollector(t)
+	reddit := collector.addSource("reddit", "golang", "go")
+	youtube := collector.addSource("youtube", "gophers")
+	collector.addArticle(reddit, "Go 1.22 is out", "go", "release")
+	collector.addArticle(youtube, "Talk about generics")
+	collector.addArticle(reddit, "Fuzzing in practice", "go")
+
+	portal := newPortal(t, collector, web.ServerOptions{})
+	return collector, portal.URL
+}
+
+// Returns the calls that asked the collector for articles or sources, the health checks are left out.
+func feedCalls(collector *mockCollector) []string {
+	var calls []string
+	for _, call := range collector.takeCalls() {
+		if strings.HasPrefix(call, "/api/articles") || strings.HasPrefix(call, "/api/sources") {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+func TestFeedRss(t *testing.T) {
+	_, url := newFeedPortal(t)
+
+	res, body := do(t, http.DefaultClient, http.MethodGet, url+"/feeds/all.rss", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %v: %v", res.StatusCode, body)
+	}
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "application/rss+xml") {
+		t.Errorf("expected an rss content type, got '%v'", res.Header.Get("Content-Type"))
+	}
+
+	var doc struct {
+		Version string `xml:"version,attr"`
+		Channel struct {
+			Title string `xml:"title"`
+			Items []struct {
+				Title      string   `xml:"title"`
+				GUID       string   `xml:"guid"`
+				PubDate    string   `xml:"pubDate"`
+				Categories []string `xml:"category"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	err := xml.Unmarshal([]byte(body), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Version != "2.0" {
+		t.Errorf("expected rss 2.0, got '%v'", doc.Version)
+	}
+	if len(doc.Channel.Items) != 3 {
+		t.Fatalf("expected 3 items, got %v", len(doc.Channel.Items))
+	}
+	first := doc.Channel.Items[0]
+	if first.Title != "Go 1.22 is out" || first.PubDate != "Thu, 01 Feb 2024 12:00:00 +0000" {
+		t.Errorf("unexpected first item %+v", first)
+	}
+	if len(first.Categories) != 2 || first.Categories[1] != "release" {
+		t.Errorf("expected the tags as categories, got %v", first.Categories)
+	}
+}
+
+func TestFeedAtom(t *testing.T) {
+	collector, url := newFeedPortal(t)
+	reddit := collector.sources[0]
+
+	res, body := do(t, http.DefaultClient, http.MethodGet, url+"/feeds/sources/"+reddit.ID.String()+".atom", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %v: %v", res.StatusCode, body)
+	}
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "application/atom+xml") {
+		t.Errorf("expected an atom content type, got '%v'", res.Header.Get("Content-Type"))
+	}
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		ID      string   `xml:"id"`
+		Title   string   `xml:"title"`
+		Updated string   `xml:"updated"`
+		Entries []struct {
+			ID    string `xml:"id"`
+			Title string `xml:"title"`
+		} `xml:"entry"`
+	}
+	err := xml.Unmarshal([]byte(body), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Title != "newsbot - reddit golang" {
+		t.Errorf("unexpected title '%v'", doc.Title)
+	}
+	if !strings.HasSuffix(doc.ID, "/feeds/sources/"+reddit.ID.String()+".atom") {
+		t.Errorf("expected the feed url as the id, got '%v'", doc.ID)
+	}
+	if doc.Updated != "2024-02-01T12:00:00Z" {
+		t.Errorf("expected the newest article as updated, got '%v'", doc.Updated)
+	}
+	if len(doc.Entries) != 2 {
+		t.Fatalf("expected the 2 reddit articles, got %v", len(doc.Entries))
+	}
+	if !strings.HasPrefix(doc.Entries[1].ID, "urn:uuid:") || doc.Entries[1].Title != "Fuzzing in practice" {
+		t.Errorf("unexpected entry %+v", doc.Entries[1])
+	}
+}
+
+func TestFeedJson(t *testing.T) {
+	_, url := newFeedPortal(t)
+
+	res, body := do(t, http.DefaultClient, http.MethodGet, url+"/feeds/tags/GO.json", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %v: %v", res.StatusCode, body)
+	}
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "application/feed+json") {
+		t.Errorf("expected a json feed content type, got '%v'", res.Header.Get("Content-Type"))
+	}
+
+	var doc struct {
+		Version string `json:"version"`
+		Title   string `json:"title"`
+		FeedUrl string `json:"feed_url"`
+		Items   []struct {
+			Title         string   `json:"title"`
+			DatePublished string   `json:"date_published"`
+			Tags          []string `json:"tags"`
+		} `json:"items"`
+	}
+	err := json.Unmarshal([]byte(body), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Version != "https://jsonfeed.org/version/1.1" {
+		t.Errorf("unexpected version '%v'", doc.Version)
+	}
+	if !strings.HasSuffix(doc.FeedUrl, "/feeds/tags/GO.json") {
+		t.Errorf("unexpected feed url '%v'", doc.FeedUrl)
+	}
+	// Tags are matched without caring about case.
+	if len(doc.Items) != 2 || doc.Items[0].Title != "Go 1.22 is out" || doc.Items[1].Title != "Fuzzing in practice" {
+		t.Errorf("expected the 2 articles tagged go, got %+v", doc.Items)
+	}
+}
+
+func TestFeedETag(t *testing.T) {
+	collector, url := newFeedPortal(t)
+	feed := url + "/feeds/sources/" + collector.sources[1].ID.String() + ".rss"
+
+	res, body := do(t, http.DefaultClient, http.MethodGet, feed, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %v: %v", res.StatusCode, body)
+	}
+	etag := res.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+	if res.Header.Get("Cache-Control") == "" {
+		t.Error("expected a Cache-Control header")
+	}
+
+	res, body = do(t, http.DefaultClient, http.MethodGet, feed, http.Header{"If-None-Match": {etag}})
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("expected 304 for the same ETag, got %v", res.StatusCode)
+	}
+	if body != "" {
+		t.Errorf("expected no body with a 304, got %v", body)
+	}
+
+	// A new article changes the feed, so the old ETag no longer matches.
+	collector.addArticle(collector.sources[1], "Something new")
+	res, _ = do(t, http.DefaultClient, http.MethodGet, feed, http.Header{"If-None-Match": {etag}})
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 once the feed changed, got %v", res.StatusCode)
+	}
+	if res.Header.Get("ETag") == etag {
+		t.Error("expected a new ETag once the feed changed")
+	}
+}
+
+func TestFeedUnknownFormat(t *testing.T) {
+	collector, url := newFeedPortal(t)
+	reddit := collector.sources[0]
+	collector.takeCalls()
+
+	paths := []string{
+		"/feeds/all.bogus",
+		"/feeds/sources/" + reddit.ID.String() + ".html",
+		"/feeds/tags/go.xml",
+	}
+	for _, path := range paths {
+		res, _ := do(t, http.DefaultClient, http.MethodGet, url+path, nil)
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("%v: expected 404, got %v", path, res.StatusCode)
+		}
+	}
+
+	if calls := feedCalls(collector); len(calls) > 0 {
+		t.Errorf("expected the collector to not be called for an unknown format, got %v", calls)
+	}
+}
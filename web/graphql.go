@@ -0,0 +1,450 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/graph-gophers/graphql-go"
+
+	"github.com/jtom38/newsbot/portal/api"
+	"github.com/jtom38/newsbot/portal/services"
+)
+
+const (
+	// Sources and articles link to each other, this stops a query from following them forever.
+	graphMaxDepth = 10
+
+	// Nested lists can still ask for the articles of every source, this is how many requests one query can send the collector.
+	graphMaxCollectorCalls = 50
+
+	graphMaxQuerySize = 64 << 10
+)
+
+// The resolvers get everything from the loader on the context, so one schema serves every request.
+var graphSchema = graphql.MustParseSchema(mustReadFile("schema.graphql"), &graphQuery{}, graphql.MaxDepth(graphMaxDepth))
+
+func mustReadFile(name string) string {
+	data, err := files.ReadFile(name)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
+
+type graphRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// /graphql
+//
+// Takes a query as json in a POST, or in the query string of a GET.
+func (s *HttpServer) GraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphRequest
+	if r.Method == http.MethodGet {
+		values := r.URL.Query()
+		req.Query = values.Get("query")
+		req.OperationName = values.Get("operationName")
+		if variables := values.Get("variables"); variables != "" {
+			err := json.Unmarshal([]byte(variables), &req.Variables)
+			if err != nil {
+				writeGraphError(w, r, fmt.Errorf("the variables are not valid json: %w", err))
+				return
+			}
+		}
+	} else {
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, graphMaxQuerySize)).Decode(&req)
+		if err != nil {
+			writeGraphError(w, r, fmt.Errorf("the request is not valid json: %w", err))
+			return
+		}
+	}
+
+	if req.Query == "" {
+		writeGraphError(w, r, errors.New("the query is missing"))
+		return
+	}
+
+	ctx := withLoader(r.Context(), newLoader(s.api, graphMaxCollectorCalls))
+	res := graphSchema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	writeApi(w, r, res)
+}
+
+// Requests that can not be run get the same errors list as a query that failed.
+func writeGraphError(w http.ResponseWriter, r *http.Request, err error) {
+	res := map[string]interface{}{
+		"errors": []map[string]string{{"message": err.Error()}},
+	}
+	writeApiStatus(w, r, http.StatusBadRequest, res)
+}
+
+func graphID(id uuid.UUID) graphql.ID {
+	return graphql.ID(id.String())
+}
+
+func parseGraphID(id graphql.ID) (uuid.UUID, error) {
+	res, err := uuid.Parse(string(id))
+	if err != nil {
+		return res, fmt.Errorf("'%v' is not a valid ID", id)
+	}
+	return res, nil
+}
+
+// Web hooks and the subscriptions that point at them need the same role as on the settings pages.
+func graphRequireAdmin(ctx context.Context) error {
+	if roleAllows(ctx, services.RoleAdmin) {
+		return nil
+	}
+	return fmt.Errorf("web hooks and subscriptions need the %v role", services.RoleAdmin)
+}
+
+func subscriptionsEnabled(ctx context.Context) error {
+	if !featuresFrom(ctx).Enabled(services.Feature_Subscriptions) {
+		return errors.New("subscriptions are turned off")
+	}
+	return nil
+}
+
+type graphQuery struct{}
+
+type articlesArgs struct {
+	Source   *string
+	SourceId *graphql.ID
+	Tag      *string
+	First    int32
+	After    *string
+}
+
+func (graphQuery) Articles(ctx context.Context, args articlesArgs) (*articleConnection, error) {
+	q := apiQuery{}
+	if args.Source != nil {
+		q.Source = *args.Source
+	}
+	if args.SourceId != nil {
+		id, err := parseGraphID(*args.SourceId)
+		if err != nil {
+			return nil, err
+		}
+		q.SourceID = id
+	}
+	return findArticleConnection(ctx, q, args.Tag, args.First, args.After)
+}
+
+func (graphQuery) Article(ctx context.Context, args struct{ ID graphql.ID }) (*articleResolver, error) {
+	id, err := parseGraphID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := loaderFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := l.Article(ctx, id)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), api.ErrInvalidStatusCode) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &articleResolver{*item}, nil
+}
+
+func (graphQuery) Sources(ctx context.Context, args struct {
+	Source  *string
+	Enabled *bool
+	Tag     *string
+}) ([]*sourceResolver, error) {
+	l, err := loaderFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sources, order, err := l.Sources(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*sourceResolver{}
+	for _, id := range order {
+		item := sources[id]
+		if item.Deleted ||
+			(args.Source != nil && !strings.EqualFold(item.Source, *args.Source)) ||
+			(args.Enabled != nil && item.Enabled != *args.Enabled) ||
+			(args.Tag != nil && !containsFold(item.Tags, *args.Tag)) {
+			continue
+		}
+		res = append(res, &sourceResolver{item})
+	}
+	return res, nil
+}
+
+func (graphQuery) Source(ctx context.Context, args struct{ ID graphql.ID }) (*sourceResolver, error) {
+	id, err := parseGraphID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return lookupSource(ctx, id)
+}
+
+func (graphQuery) Webhooks(ctx context.Context, args struct{ Enabled *bool }) ([]*webhookResolver, error) {
+	err := graphRequireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := loaderFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks, order, err := l.Webhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*webhookResolver{}
+	for _, id := range order {
+		item := webhooks[id]
+		if args.Enabled != nil && item.Enabled != *args.Enabled {
+			continue
+		}
+		res = append(res, &webhookResolver{item})
+	}
+	return res, nil
+}
+
+func (graphQuery) Webhook(ctx context.Context, args struct{ ID graphql.ID }) (*webhookResolver, error) {
+	id, err := parseGraphID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return lookupWebhook(ctx, id)
+}
+
+func (graphQuery) Subscriptions(ctx context.Context, args struct {
+	SourceId  *graphql.ID
+	WebhookId *graphql.ID
+}) ([]*subscriptionResolver, error) {
+	var sourceID, webhookID uuid.UUID
+	var err error
+	if args.SourceId != nil {
+		sourceID, err = parseGraphID(*args.SourceId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if args.WebhookId != nil {
+		webhookID, err = parseGraphID(*args.WebhookId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return findSubscriptions(ctx, func(item api.Subscription) bool {
+		return (sourceID == uuid.Nil || item.SourceId == sourceID) &&
+			(webhookID == uuid.Nil || item.DiscordWebhookId == webhookID)
+	})
+}
+
+func findArticleConnection(ctx context.Context, q apiQuery, tag *string, first int32, after *string) (*articleConnection, error) {
+	if first < 1 || first > apiMaxLimit {
+		return nil, fmt.Errorf("first has to be from 1 to %v", apiMaxLimit)
+	}
+	q.Limit = int(first)
+
+	if tag != nil {
+		q.Tag = *tag
+	}
+	if after != nil {
+		cursor, err := parseCursor(*after)
+		if err != nil {
+			return nil, err
+		}
+		q.Cursor = cursor
+	}
+
+	l, err := loaderFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sources, _, err := l.Sources(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items, next, err := findArticles(ctx, l.ArticlesPage, q, sources)
+	if err != nil {
+		return nil, err
+	}
+	return &articleConnection{items: items, next: next}, nil
+}
+
+func findSubscriptions(ctx context.Context, match func(api.Subscription) bool) ([]*subscriptionResolver, error) {
+	err := subscriptionsEnabled(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = graphRequireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := loaderFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := l.Subscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*subscriptionResolver{}
+	for _, item := range items {
+		if match(item) {
+			res = append(res, &subscriptionResolver{item})
+		}
+	}
+	return res, nil
+}
+
+// Returns nil when there is no source with the ID.
+func lookupSource(ctx context.Context, id uuid.UUID) (*sourceResolver, error) {
+	l, err := loaderFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sources, _, err := l.Sources(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	item, ok := sources[id]
+	if !ok {
+		return nil, nil
+	}
+	return &sourceResolver{item}, nil
+}
+
+// Returns nil when there is no web hook with the ID.
+func lookupWebhook(ctx context.Context, id uuid.UUID) (*webhookResolver, error) {
+	err := graphRequireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := loaderFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks, _, err := l.Webhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	item, ok := webhooks[id]
+	if !ok {
+		return nil, nil
+	}
+	return &webhookResolver{item}, nil
+}
+
+type articleConnection struct {
+	items []api.Article
+	next  string
+}
+
+func (c *articleConnection) Items() []*articleResolver {
+	res := make([]*articleResolver, len(c.items))
+	for i, item := range c.items {
+		res[i] = &articleResolver{item}
+	}
+	return res
+}
+
+func (c *articleConnection) Next() *string {
+	if c.next == "" {
+		return nil
+	}
+	return &c.next
+}
+
+type articleResolver struct{ a api.Article }
+
+func (r *articleResolver) ID() graphql.ID        { return graphID(r.a.ID) }
+func (r *articleResolver) Title() string         { return r.a.Title }
+func (r *articleResolver) Url() string           { return r.a.Url }
+func (r *articleResolver) Pubdate() graphql.Time { return graphql.Time{Time: r.a.Pubdate} }
+func (r *articleResolver) Description() string   { return r.a.Description }
+func (r *articleResolver) Thumbnail() string     { return r.a.Thumbnail }
+func (r *articleResolver) Video() string         { return r.a.Video }
+func (r *articleResolver) AuthorName() string    { return r.a.AuthorName }
+func (r *articleResolver) AuthorImage() string   { return r.a.AuthorImage }
+func (r *articleResolver) Tags() []string        { return nonNilTags(r.a.Tags) }
+
+func (r *articleResolver) Source(ctx context.Context) (*sourceResolver, error) {
+	return lookupSource(ctx, r.a.SourceID)
+}
+
+type sourceResolver struct{ s api.Source }
+
+func (r *sourceResolver) ID() graphql.ID { return graphID(r.s.ID) }
+func (r *sourceResolver) Source() string { return r.s.Source }
+func (r *sourceResolver) Name() string   { return r.s.Name }
+func (r *sourceResolver) Url() string    { return r.s.Url }
+func (r *sourceResolver) Enabled() bool  { return r.s.Enabled }
+func (r *sourceResolver) Tags() []string { return nonNilTags(r.s.Tags) }
+
+func (r *sourceResolver) Articles(ctx context.Context, args struct {
+	Tag   *string
+	First int32
+	After *string
+}) (*articleConnection, error) {
+	return findArticleConnection(ctx, apiQuery{SourceID: r.s.ID}, args.Tag, args.First, args.After)
+}
+
+func (r *sourceResolver) Subscriptions(ctx context.Context) ([]*subscriptionResolver, error) {
+	return findSubscriptions(ctx, func(item api.Subscription) bool { return item.SourceId == r.s.ID })
+}
+
+type webhookResolver struct{ w api.DiscordWebHooks }
+
+func (r *webhookResolver) ID() graphql.ID  { return graphID(r.w.ID) }
+func (r *webhookResolver) Server() string  { return r.w.Server }
+func (r *webhookResolver) Channel() string { return r.w.Channel }
+func (r *webhookResolver) Enabled() bool   { return r.w.Enabled }
+
+func (r *webhookResolver) Subscriptions(ctx context.Context) ([]*subscriptionResolver, error) {
+	return findSubscriptions(ctx, func(item api.Subscription) bool { return item.DiscordWebhookId == r.w.ID })
+}
+
+type subscriptionResolver struct{ s api.Subscription }
+
+func (r *subscriptionResolver) ID() graphql.ID { return graphID(r.s.ID) }
+
+func (r *subscriptionResolver) Source(ctx context.Context) (*sourceResolver, error) {
+	return lookupSource(ctx, r.s.SourceId)
+}
+
+func (r *subscriptionResolver) Webhook(ctx context.Context) (*webhookResolver, error) {
+	return lookupWebhook(ctx, r.s.DiscordWebhookId)
+}
+
+func nonNilTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
@@ -0,0 +1,246 @@
+package web_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jtom38/newsbot/portal/web"
+)
+
+type graphResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Posts the query and decodes the data into value.
+func postGraph(t *testing.T, url, query string, value interface{}) graphResponse {
+	gr := postGraphAs(t, http.DefaultClient, url, query)
+	if value != nil && len(gr.Data) > 0 {
+		err := json.Unmarshal(gr.Data, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return gr
+}
+
+// Posts the query with the browser, so it is sent with the session of whoever logged in with it.
+func postGraphAs(t *testing.T, browser *http.Client, url, query string) graphResponse {
+	body, err := json.Marshal(map[string]string{"query": query})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := browser.Post(url+"/graphql", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gr graphResponse
+	err = json.Unmarshal(data, &gr)
+	if err != nil {
+		t.Fatal(fmt.Errorf("%w: %v", err, string(data)))
+	}
+	return gr
+}
+
+// Returns how many times the collector was asked for each route, the query strings are left out.
+func collectorRoutes(collector *mockCollector) map[string]int {
+	routes := make(map[string]int)
+	for _, call := range collector.takeCalls() {
+		path, _, _ := strings.Cut(call, "?")
+		routes[path]++
+	}
+	return routes
+}
+
+func TestGraphQLSources(t *testing.T) {
+	collector := newMockCollector(t)
+	reddit := collector.addSource("reddit", "golang", "go")
+	youtube := collector.addSource("youtube", "gophers")
+	for i := 0; i < 4; i++ {
+		collector.addArticle(reddit, fmt.Sprintf("reddit %v", i))
+		collector.addArticle(youtube, fmt.Sprintf("youtube %v", i))
+	}
+	webhook := collector.addWebhook("home", "news")
+	collector.subscribe(webhook, reddit)
+	portal := newPortal(t, collector, web.ServerOptions{})
+	collector.takeCalls()
+
+	var data struct {
+		Sources []struct {
+			Name     string
+			Articles struct {
+				Items []struct {
+					Title  string
+					Source struct{ Name string }
+				}
+				Next *string
+			}
+			Subscriptions []struct {
+				Webhook struct{ Channel string }
+			}
+		}
+	}
+	res := postGraph(t, portal.URL, `{
+		sources(source: "reddit") {
+			name
+			articles(first: 3) { items { title source { name } } next }
+			subscriptions { webhook { channel } }
+		}
+	}`, &data)
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", res.Errors)
+	}
+
+	if len(data.Sources) != 1 || data.Sources[0].Name != "golang" {
+		t.Fatalf("expected only the reddit source, got %+v", data.Sources)
+	}
+	articles := data.Sources[0].Articles
+	if len(articles.Items) != 3 || articles.Items[0].Title != "reddit 0" || articles.Items[2].Source.Name != "golang" {
+		t.Errorf("unexpected articles %+v", articles.Items)
+	}
+	if articles.Next == nil {
+		t.Error("expected a cursor for the last reddit article")
+	}
+	if subs := data.Sources[0].Subscriptions; len(subs) != 1 || subs[0].Webhook.Channel != "news" {
+		t.Errorf("unexpected subscriptions %+v", subs)
+	}
+
+	// Every article and subscription looks up its source, the loader only asks the collector once.
+	routes := collectorRoutes(collector)
+	for _, route := range []string{"/api/sources", "/api/articles/by/sourceid", "/api/subscriptions", "/api/discord/webhooks"} {
+		if routes[route] != 1 {
+			t.Errorf("expected 1 request to %v, got %v", route, routes[route])
+		}
+	}
+}
+
+func TestGraphQLArticle(t *testing.T) {
+	collector := newMockCollector(t)
+	reddit := collector.addSource("reddit", "golang")
+	article := collector.addArticle(reddit, "Go 1.22 is out", "go")
+	portal := newPortal(t, collector, web.ServerOptions{})
+
+	var data struct {
+		Article *struct {
+			Title string
+			Tags  []string
+		}
+		Missing *struct{ Title string }
+	}
+	res := postGraph(t, portal.URL, fmt.Sprintf(`{
+		article(id: "%v") { title tags }
+		missing: article(id: "%v") { title }
+	}`, article.ID, reddit.ID), &data)
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", res.Errors)
+	}
+
+	if data.Article == nil || data.Article.Title != "Go 1.22 is out" || len(data.Article.Tags) != 1 {
+		t.Errorf("unexpected article %+v", data.Article)
+	}
+	if data.Missing != nil {
+		t.Errorf("expected null for an ID the collector does not know, got %+v", data.Missing)
+	}
+}
+
+func TestGraphQLBadArgs(t *testing.T) {
+	portal := newPortal(t, newMockCollector(t), web.ServerOptions{})
+
+	queries := []string{
+		`{ articles(first: 0) { next } }`,
+		`{ articles(first: 101) { next } }`,
+		`{ articles(after: "!!!") { next } }`,
+		`{ source(id: "nope") { name } }`,
+	}
+	for _, query := range queries {
+		res := postGraph(t, portal.URL, query, nil)
+		if len(res.Errors) == 0 {
+			t.Errorf("%v: expected an error", query)
+		}
+	}
+}
+
+func TestGraphQLCollectorLimit(t *testing.T) {
+	collector := newMockCollector(t)
+	for i := 0; i < 60; i++ {
+		collector.addSource("reddit", fmt.Sprintf("source %v", i))
+	}
+	portal := newPortal(t, collector, web.ServerOptions{})
+	collector.takeCalls()
+
+	// Each source reads its own articles, so this would send the collector more than 60 requests.
+	res := postGraph(t, portal.URL, `{ sources { name articles(first: 1) { next } } }`, nil)
+	if len(res.Errors) == 0 {
+		t.Fatal("expected an error once the query asked the collector for too much")
+	}
+	if !strings.Contains(res.Errors[0].Message, "requests to the collector") {
+		t.Errorf("unexpected error '%v'", res.Errors[0].Message)
+	}
+
+	sent := 0
+	for route, count := range collectorRoutes(collector) {
+		if strings.HasPrefix(route, "/api/sources") || strings.HasPrefix(route, "/api/articles") {
+			sent += count
+		}
+	}
+	if sent > 50 {
+		t.Errorf("expected at most 50 requests to the collector, got %v", sent)
+	}
+
+	// Fewer sources fit in the limit.
+	res = postGraph(t, portal.URL, `{ source: sources(tag: "none") { name articles(first: 1) { next } } }`, nil)
+	if len(res.Errors) > 0 {
+		t.Errorf("unexpected errors %v", res.Errors)
+	}
+}
+
+func TestGraphQLWebhooksNeedAdmin(t *testing.T) {
+	collector := newMockCollector(t)
+	reddit := collector.addSource("reddit", "golang")
+	webhook := collector.addWebhook("home", "news")
+	collector.subscribe(webhook, reddit)
+	portal, _ := newUsersPortal(t, collector)
+
+	_, _, viewer := login(t, portal, "viewer", "viewer password")
+	_, _, admin := login(t, portal, "admin", "admin password")
+
+	queries := []string{
+		`{ webhooks { channel } }`,
+		fmt.Sprintf(`{ webhook(id: "%v") { channel } }`, webhook.ID),
+		`{ subscriptions { id } }`,
+		`{ sources { subscriptions { id } } }`,
+	}
+	for _, query := range queries {
+		for name, browser := range map[string]*http.Client{"nobody": http.DefaultClient, "viewer": viewer} {
+			res := postGraphAs(t, browser, portal, query)
+			if len(res.Errors) == 0 || !strings.Contains(res.Errors[0].Message, "admin role") {
+				t.Errorf("%v %v: expected the admin role to be needed, got %+v", name, query, res)
+			}
+		}
+
+		res := postGraphAs(t, admin, portal, query)
+		if len(res.Errors) > 0 {
+			t.Errorf("admin %v: unexpected errors %v", query, res.Errors)
+		}
+	}
+
+	res := postGraphAs(t, viewer, portal, `{ sources { name } }`)
+	if len(res.Errors) > 0 {
+		t.Errorf("expected the sources to stay open, got %v", res.Errors)
+	}
+}
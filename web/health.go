@@ -0,0 +1,151 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/jtom38/newsbot/portal/api"
+	"github.com/jtom38/newsbot/portal/services"
+)
+
+const (
+	// How long /readyz waits on the collector before reporting it as unreachable.
+	readyTimeout = 3 * time.Second
+)
+
+// These routes are for Docker, Kubernetes and Prometheus, so they skip the html layout.
+//
+// The probes stay open so the orchestrator never needs a login.
+// The status and metrics show the collector url, errors and traffic, so they need an admin once logins are on.
+func (s *HttpServer) mountHealthRoutes(r chi.Router) {
+	r.Get("/healthz", s.Healthz)
+	r.Get("/readyz", s.Readyz)
+
+	r.Group(func(r chi.Router) {
+		r.Use(s.requireLogin, requireRole(services.RoleAdmin))
+		r.Get("/status", s.Status)
+		r.Handle("/metrics", s.metrics.handler())
+	})
+}
+
+// /healthz
+//
+// The process is up and able to answer requests.
+func (s *HttpServer) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("ok"))
+}
+
+// /readyz
+//
+// The portal is not shutting down, the templates are parsed and the collector answered within the deadline.
+func (s *HttpServer) Readyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+
+	if s.shuttingDown.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("shutting down"))
+		return
+	}
+
+	err := templatesReady()
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	info, err := s.api.Info(ctx)
+	s.collector.set(info, err)
+	if err != nil && !api.IsVersionUnavailable(err) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("collector is not reachable: " + err.Error()))
+		return
+	}
+
+	w.Write([]byte("ok"))
+}
+
+type StatusResponse struct {
+	Status    string            `json:"status"`
+	Uptime    string            `json:"uptime"`
+	Collector CollectorResponse `json:"collector"`
+	Upstream  UpstreamResponse  `json:"upstream"`
+}
+
+type CollectorResponse struct {
+	Address      string    `json:"address"`
+	Version      string    `json:"version"`
+	Reachable    bool      `json:"reachable"`
+	Latency      string    `json:"latency"`
+	CheckedAt    time.Time `json:"checkedAt"`
+	Error        string    `json:"error,omitempty"`
+	VersionError string    `json:"versionError,omitempty"`
+}
+
+type UpstreamResponse struct {
+	Requests     uint64    `json:"requests"`
+	Errors       uint64    `json:"errors"`
+	LastLatency  string    `json:"lastLatency"`
+	LastError    string    `json:"lastError,omitempty"`
+	LastErrorAt  time.Time `json:"lastErrorAt"`
+	CacheHits    uint64    `json:"cacheHits"`
+	CacheMisses  uint64    `json:"cacheMisses"`
+	CacheEntries int       `json:"cacheEntries"`
+	Throttled    uint64    `json:"throttled"`
+	Rejected     uint64    `json:"rejected"`
+}
+
+// /status
+//
+// Reports what the portal knows about the collector without calling it.
+func (s *HttpServer) Status(w http.ResponseWriter, r *http.Request) {
+	state := s.collector.get()
+	stats := s.rest.Stats()
+
+	res := StatusResponse{
+		Status: "ok",
+		Uptime: time.Since(s.started).Round(time.Second).String(),
+		Collector: CollectorResponse{
+			Address:   state.Address,
+			Version:   state.Info.Version,
+			Reachable: state.Error == nil && !state.CheckedAt.IsZero(),
+			Latency:   state.Info.Latency.String(),
+			CheckedAt: state.CheckedAt,
+		},
+		Upstream: UpstreamResponse{
+			Requests:     stats.Requests,
+			Errors:       stats.Errors,
+			LastLatency:  stats.LastLatency.String(),
+			LastError:    stats.LastError,
+			LastErrorAt:  stats.LastErrorAt,
+			CacheHits:    stats.CacheHits,
+			CacheMisses:  stats.CacheMisses,
+			CacheEntries: stats.CacheEntries,
+			Throttled:    stats.Throttled,
+			Rejected:     stats.Rejected,
+		},
+	}
+
+	if state.Error != nil {
+		res.Status = "degraded"
+		res.Collector.Error = state.Error.Error()
+	}
+	if state.VersionError != nil {
+		res.Collector.VersionError = state.VersionError.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(res)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to write the status", "error", err)
+	}
+}
@@ -0,0 +1,111 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jtom38/newsbot/portal/services"
+)
+
+const (
+	// How often the TLS certificate files are checked for changes.
+	certificatePollInterval = time.Minute
+)
+
+// This serves the portal until the context is done, then stops taking new connections
+// and gives the requests that are still running until the shutdown timeout to finish.
+func (s *HttpServer) ListenAndServe(ctx context.Context, cfg services.ServerConfig) error {
+	srv := &http.Server{
+		Handler:      s.Router,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		ErrorLog:     slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+
+	ln, err := listen(cfg)
+	if err != nil {
+		return err
+	}
+
+	scheme := "http"
+	if cfg.TLS.Enabled() {
+		certs, err := services.NewCertificateReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		go certs.Watch(ctx, certificatePollInterval)
+
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}
+		ln = tls.NewListener(ln, srv.TLSConfig)
+		scheme = "https"
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ln)
+	}()
+	slog.Info("starting portal", "address", cfg.Address, "scheme", scheme)
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Let load balancers know to stop sending traffic while we drain.
+	s.shuttingDown.Store(true)
+	slog.Info("shutting down, waiting on running requests", "timeout", cfg.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	err = srv.Shutdown(shutdownCtx)
+	if err != nil {
+		return fmt.Errorf("requests were still running when the portal stopped: %w", err)
+	}
+
+	err = <-served
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	slog.Info("portal stopped")
+	return nil
+}
+
+// Opens the tcp port or unix socket the portal listens on.
+func listen(cfg services.ServerConfig) (net.Listener, error) {
+	path, ok := cfg.UnixSocket()
+	if !ok {
+		return net.Listen("tcp", cfg.Address)
+	}
+
+	// A socket left behind by a portal that did not stop cleanly would block us.
+	// Only remove it when nothing is answering on it.
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		conn, err := net.DialTimeout("unix", path, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("'%v' is already being served", path)
+		}
+
+		err = os.Remove(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return net.Listen("unix", path)
+}
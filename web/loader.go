@@ -0,0 +1,206 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+type loaderKey struct{}
+
+var errNoLoader = errors.New("the request has no loader")
+
+// This caches what the collector returns for the length of one request.
+// A graphql query like sources { articles { source } } asks for the same records many times over, and the collector
+// can not look up a batch of IDs, so every list is read once and the resolvers look up their records in it.
+//
+// Resolvers run in parallel, every value is loaded behind its own sync.Once.
+//
+// A query can still ask for a lot of different records, like the articles of every source,
+// so the loader stops asking the collector once it has sent maxCalls requests. 0 means there is no limit.
+type loader struct {
+	api      api.CollectorApi
+	maxCalls int32
+	calls    atomic.Int32
+
+	sourcesOnce sync.Once
+	sources     map[uuid.UUID]api.Source
+	sourceOrder []uuid.UUID
+	sourcesErr  error
+
+	webhooksOnce sync.Once
+	webhooks     map[uuid.UUID]api.DiscordWebHooks
+	webhookOrder []uuid.UUID
+	webhooksErr  error
+
+	subscriptionsOnce sync.Once
+	subscriptions     []api.Subscription
+	subscriptionsErr  error
+
+	mu       sync.Mutex
+	pages    map[articlePageKey]*articlePage
+	articles map[uuid.UUID]*articleLoad
+}
+
+type articlePageKey struct {
+	sourceID uuid.UUID
+	page     int
+}
+
+type articlePage struct {
+	once  sync.Once
+	items []api.Article
+	err   error
+}
+
+type articleLoad struct {
+	once sync.Once
+	item *api.Article
+	err  error
+}
+
+func newLoader(client api.CollectorApi, maxCalls int32) *loader {
+	return &loader{
+		api:      client,
+		maxCalls: maxCalls,
+		pages:    make(map[articlePageKey]*articlePage),
+		articles: make(map[uuid.UUID]*articleLoad),
+	}
+}
+
+func withLoader(ctx context.Context, loader *loader) context.Context {
+	return context.WithValue(ctx, loaderKey{}, loader)
+}
+
+func loaderFrom(ctx context.Context) (*loader, error) {
+	l, ok := ctx.Value(loaderKey{}).(*loader)
+	if !ok {
+		return nil, errNoLoader
+	}
+	return l, nil
+}
+
+// Counts a request to the collector, and returns an error instead when the loader is out of them.
+func (l *loader) spend() error {
+	if l.calls.Add(1) > l.maxCalls && l.maxCalls > 0 {
+		return fmt.Errorf("the query needs more than %v requests to the collector, ask for fewer records", l.maxCalls)
+	}
+	return nil
+}
+
+// Every source, including the deleted ones so old articles can still be joined with theirs.
+func (l *loader) Sources(ctx context.Context) (map[uuid.UUID]api.Source, []uuid.UUID, error) {
+	l.sourcesOnce.Do(func() {
+		err := l.spend()
+		if err != nil {
+			l.sourcesErr = err
+			return
+		}
+
+		items, err := l.api.Sources().List(ctx)
+		if err != nil {
+			l.sourcesErr = err
+			return
+		}
+
+		l.sources = make(map[uuid.UUID]api.Source)
+		if items != nil {
+			for _, item := range *items {
+				l.sources[item.ID] = item
+				l.sourceOrder = append(l.sourceOrder, item.ID)
+			}
+		}
+	})
+	return l.sources, l.sourceOrder, l.sourcesErr
+}
+
+func (l *loader) Webhooks(ctx context.Context) (map[uuid.UUID]api.DiscordWebHooks, []uuid.UUID, error) {
+	l.webhooksOnce.Do(func() {
+		err := l.spend()
+		if err != nil {
+			l.webhooksErr = err
+			return
+		}
+
+		items, err := l.api.Outputs().DiscordWebHook().List(ctx)
+		if err != nil {
+			l.webhooksErr = err
+			return
+		}
+
+		l.webhooks = make(map[uuid.UUID]api.DiscordWebHooks)
+		if items != nil {
+			for _, item := range *items {
+				l.webhooks[item.ID] = item
+				l.webhookOrder = append(l.webhookOrder, item.ID)
+			}
+		}
+	})
+	return l.webhooks, l.webhookOrder, l.webhooksErr
+}
+
+func (l *loader) Subscriptions(ctx context.Context) ([]api.Subscription, error) {
+	l.subscriptionsOnce.Do(func() {
+		l.subscriptionsErr = l.spend()
+		if l.subscriptionsErr != nil {
+			return
+		}
+		l.subscriptions, l.subscriptionsErr = l.api.Subscriptions().List(ctx)
+	})
+	return l.subscriptions, l.subscriptionsErr
+}
+
+// This is an articlePager, so a page that a few sources ask for is only read once.
+func (l *loader) ArticlesPage(ctx context.Context, sourceID uuid.UUID, page int) ([]api.Article, error) {
+	l.mu.Lock()
+	p, ok := l.pages[articlePageKey{sourceID, page}]
+	if !ok {
+		p = &articlePage{}
+		l.pages[articlePageKey{sourceID, page}] = p
+	}
+	l.mu.Unlock()
+
+	p.once.Do(func() {
+		p.err = l.spend()
+		if p.err != nil {
+			return
+		}
+
+		if sourceID == uuid.Nil {
+			p.items, p.err = l.api.Articles().List(ctx, api.ArticlesListParam{Page: int32(page)})
+			return
+		}
+
+		items, err := l.api.Articles().ListBySourceId(ctx, sourceID, page)
+		if err == nil && items != nil {
+			p.items = *items
+		}
+		p.err = err
+	})
+	return p.items, p.err
+}
+
+func (l *loader) Article(ctx context.Context, id uuid.UUID) (*api.Article, error) {
+	l.mu.Lock()
+	a, ok := l.articles[id]
+	if !ok {
+		a = &articleLoad{}
+		l.articles[id] = a
+	}
+	l.mu.Unlock()
+
+	a.once.Do(func() {
+		a.err = l.spend()
+		if a.err != nil {
+			return
+		}
+		a.item, a.err = l.api.Articles().Get(ctx, id)
+	})
+	return a.item, a.err
+}
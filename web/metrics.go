@@ -0,0 +1,133 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+// This holds the Prometheus collectors for the portal and the calls it makes to the collector api.
+// Routes are labeled with their chi pattern, like /articles/{ID}, and never the raw url.
+type portalMetrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+
+	upstreamRequests *prometheus.CounterVec
+	upstreamDuration *prometheus.HistogramVec
+	upstreamErrors   *prometheus.CounterVec
+}
+
+func newPortalMetrics() *portalMetrics {
+	m := portalMetrics{
+		registry: prometheus.NewRegistry(),
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "portal_http_requests_total",
+			Help: "Requests served by the portal.",
+		}, []string{"method", "route", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "portal_http_request_duration_seconds",
+			Help:    "How long the portal took to serve a request.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+
+		upstreamRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "portal_upstream_requests_total",
+			Help: "Requests sent to the collector api.",
+		}, []string{"method", "route", "code"}),
+		upstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "portal_upstream_request_duration_seconds",
+			Help:    "How long the collector api took to respond.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		upstreamErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "portal_upstream_errors_total",
+			Help: "Requests to the collector api that failed to connect or returned a 5xx.",
+		}, []string{"method", "route"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.upstreamRequests,
+		m.upstreamDuration,
+		m.upstreamErrors,
+	)
+
+	return &m
+}
+
+// This exposes the counters kept by the RestClient, like the cache and rate limit.
+func (m *portalMetrics) registerRestStats(rest *api.RestClient) {
+	counter := func(name string, help string, value func(api.RestStats) uint64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{Name: name, Help: help}, func() float64 {
+			return float64(value(rest.Stats()))
+		})
+	}
+
+	m.registry.MustRegister(
+		counter("portal_upstream_cache_hits_total", "GET requests answered from the cache after a 304.", func(s api.RestStats) uint64 { return s.CacheHits }),
+		counter("portal_upstream_cache_misses_total", "GET requests that had to download the full response.", func(s api.RestStats) uint64 { return s.CacheMisses }),
+		counter("portal_upstream_throttled_total", "Requests that had to wait on the rate limit.", func(s api.RestStats) uint64 { return s.Throttled }),
+		counter("portal_upstream_rejected_total", "Requests that gave up waiting on the rate limit.", func(s api.RestStats) uint64 { return s.Rejected }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "portal_upstream_cache_entries",
+			Help: "Responses currently held in the cache.",
+		}, func() float64 {
+			return float64(rest.Stats().CacheEntries)
+		}),
+	)
+}
+
+// Middleware that records every request once chi has matched it to a route.
+func (m *portalMetrics) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		m.requests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		m.requestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+func (m *portalMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest is called by the RestClient for each request sent to the collector api.
+func (m *portalMetrics) ObserveRequest(method string, route string, status int, duration time.Duration, err error) {
+	code := "error"
+	if status > 0 {
+		code = strconv.Itoa(status)
+	}
+
+	m.upstreamRequests.WithLabelValues(method, route, code).Inc()
+	m.upstreamDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+	if err != nil {
+		m.upstreamErrors.WithLabelValues(method, route).Inc()
+	}
+}
@@ -0,0 +1,38 @@
+package web_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jtom38/newsbot/portal/services"
+	"github.com/jtom38/newsbot/portal/web"
+)
+
+func TestMetricsCountPanics(t *testing.T) {
+	collector := newMockCollector(t)
+	server := web.NewServer(context.Background(), web.ServerOptions{
+		ApiEndpoint:    collector.URL,
+		Features:       services.NewFeatureFlags(map[string]bool{}),
+		SessionTimeout: time.Hour,
+	})
+	server.Router.Get("/boom", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	portal := httptest.NewServer(server.Router)
+	t.Cleanup(portal.Close)
+
+	res, _ := do(t, http.DefaultClient, http.MethodGet, portal.URL+"/boom", nil)
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected the panic to be a 500, got %v", res.StatusCode)
+	}
+
+	_, body := do(t, http.DefaultClient, http.MethodGet, portal.URL+"/metrics", nil)
+	want := `portal_http_requests_total{code="500",method="GET",route="/boom"} 1`
+	if !strings.Contains(body, want) {
+		t.Errorf("expected '%v' in the metrics", want)
+	}
+}
@@ -0,0 +1,283 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"github.com/jtom38/newsbot/portal/services"
+)
+
+const (
+	// Holds the state of a login that was sent to the provider, so the callback can tell it came from this browser.
+	oidcStateCookie = "newsbot_oidc"
+
+	// How long someone has to finish logging in with the provider.
+	oidcLoginTimeout = 10 * time.Minute
+
+	// How many logins can be waiting on the provider at once.
+	// Anyone can start a login, so past this the oldest ones are dropped and have to start again.
+	oidcMaxPending = 1000
+)
+
+// oidcLogin logs people in with an OpenID Connect provider, using the authorization code flow with PKCE.
+type oidcLogin struct {
+	cfg services.OIDCConfig
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+
+	// Logins that were sent to the provider and have not come back yet, by state.
+	pending map[string]pendingLogin
+}
+
+type pendingLogin struct {
+	verifier    string
+	nonce       string
+	next        string
+	redirectURL string
+	expires     time.Time
+}
+
+func newOIDCLogin(cfg services.OIDCConfig) *oidcLogin {
+	return &oidcLogin{
+		cfg:     cfg,
+		pending: make(map[string]pendingLogin),
+	}
+}
+
+// Returns the provider, it is looked up the first time someone logs in so the portal can start while it is down.
+func (o *oidcLogin) discover(ctx context.Context) (*oidc.Provider, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.provider != nil {
+		return o.provider, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, o.cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach the login provider: %w", err)
+	}
+	o.provider = provider
+	return provider, nil
+}
+
+func (o *oidcLogin) oauth(provider *oidc.Provider, redirectURL string) *oauth2.Config {
+	scopes := []string{oidc.ScopeOpenID}
+	for _, scope := range o.cfg.Scopes {
+		if scope != oidc.ScopeOpenID {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return &oauth2.Config{
+		ClientID:     o.cfg.ClientID,
+		ClientSecret: o.cfg.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+	}
+}
+
+// Returns where the provider sends people back to.
+func (o *oidcLogin) redirectURL(r *http.Request) string {
+	if o.cfg.RedirectURL != "" {
+		return o.cfg.RedirectURL
+	}
+	return baseURL(r) + "/login/oidc/callback"
+}
+
+func (o *oidcLogin) start(state string, login pendingLogin) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	// Logins that were never finished would pile up, so clear them out while we are here.
+	now := time.Now()
+	oldest := ""
+	for key, item := range o.pending {
+		if now.After(item.expires) {
+			delete(o.pending, key)
+			continue
+		}
+		if oldest == "" || item.expires.Before(o.pending[oldest].expires) {
+			oldest = key
+		}
+	}
+	if len(o.pending) >= oidcMaxPending {
+		delete(o.pending, oldest)
+	}
+
+	o.pending[state] = login
+}
+
+// Returns the login for the state, it can only be used once.
+func (o *oidcLogin) finish(state string) (pendingLogin, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	login, ok := o.pending[state]
+	delete(o.pending, state)
+	if !ok || time.Now().After(login.expires) {
+		return pendingLogin{}, false
+	}
+	return login, true
+}
+
+// /login/oidc
+//
+// Sends the browser to the provider to log in.
+func (s *HttpServer) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	next := safeNext(r, r.URL.Query().Get("next"))
+
+	provider, err := s.oidc.discover(r.Context())
+	if err != nil {
+		s.oidcError(w, r, next, err)
+		return
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		s.oidcError(w, r, next, err)
+		return
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		s.oidcError(w, r, next, err)
+		return
+	}
+
+	login := pendingLogin{
+		verifier:    oauth2.GenerateVerifier(),
+		nonce:       nonce,
+		next:        next,
+		redirectURL: s.oidc.redirectURL(r),
+		expires:     time.Now().Add(oidcLoginTimeout),
+	}
+	s.oidc.start(state, login)
+
+	http.SetCookie(w, authCookie(r, oidcStateCookie, state, login.expires))
+	url := s.oidc.oauth(provider, login.redirectURL).AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(login.verifier))
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// /login/oidc/callback
+//
+// The provider sends the browser back here with a code, which is traded for the ID token.
+func (s *HttpServer) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	next := basePathFrom(r.Context()) + "/"
+
+	// The state is only good once, so the cookie is cleared whatever happens next.
+	http.SetCookie(w, authCookie(r, oidcStateCookie, "", time.Unix(0, 0)))
+
+	if reason := query.Get("error"); reason != "" {
+		if description := query.Get("error_description"); description != "" {
+			reason = description
+		}
+		s.oidcError(w, r, next, fmt.Errorf("the login provider turned the login down: %v", reason))
+		return
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		s.oidcError(w, r, next, errors.New("the login was started in another browser or has expired, try again"))
+		return
+	}
+
+	login, ok := s.oidc.finish(state)
+	if !ok {
+		s.oidcError(w, r, next, errors.New("the login has expired, try again"))
+		return
+	}
+	next = login.next
+
+	user, err := s.oidc.exchange(r.Context(), login, query.Get("code"))
+	if err != nil {
+		s.oidcError(w, r, next, err)
+		return
+	}
+
+	s.startSession(w, r, user, false, next)
+}
+
+// Trades the code for an ID token and returns the user it is for.
+func (o *oidcLogin) exchange(ctx context.Context, login pendingLogin, code string) (services.User, error) {
+	provider, err := o.discover(ctx)
+	if err != nil {
+		return services.User{}, err
+	}
+
+	token, err := o.oauth(provider, login.redirectURL).Exchange(ctx, code, oauth2.VerifierOption(login.verifier))
+	if err != nil {
+		return services.User{}, fmt.Errorf("unable to finish the login: %w", err)
+	}
+
+	raw, ok := token.Extra("id_token").(string)
+	if !ok {
+		return services.User{}, errors.New("the login provider did not send an ID token")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: o.cfg.ClientID}).Verify(ctx, raw)
+	if err != nil {
+		return services.User{}, fmt.Errorf("the ID token is not valid: %w", err)
+	}
+	if idToken.Nonce != login.nonce {
+		return services.User{}, errors.New("the ID token is not for this login")
+	}
+
+	var claims map[string]interface{}
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return services.User{}, err
+	}
+
+	name := idToken.Subject
+	for _, key := range []string{"email", "preferred_username"} {
+		if value, ok := claims[key].(string); ok && value != "" {
+			name = value
+		}
+	}
+
+	groups := claimStrings(claims[o.cfg.GroupsClaim])
+	role, ok := o.cfg.RoleFor(groups)
+	if !ok {
+		slog.WarnContext(ctx, "turned away a login with no role", "user", name, "groups", groups)
+		return services.User{}, fmt.Errorf("%v is not in a group that can use the portal", name)
+	}
+
+	return services.User{Name: name, Role: role}, nil
+}
+
+// Groups are usually a list, some providers send a single group as a string.
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var items []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				items = append(items, s)
+			}
+		}
+		return items
+	}
+	return nil
+}
+
+func (s *HttpServer) oidcError(w http.ResponseWriter, r *http.Request, next string, err error) {
+	slog.WarnContext(r.Context(), "failed login with the provider", "remote", r.RemoteAddr, "error", err)
+
+	param := s.newLoginParam(next)
+	param.Errors = append(param.Errors, err.Error())
+	render(w, r, pageLogin, param)
+}
@@ -0,0 +1,224 @@
+package web_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+
+	"github.com/jtom38/newsbot/portal/services"
+	"github.com/jtom38/newsbot/portal/web"
+)
+
+// mockProvider is just enough of an OpenID Connect provider to log someone in.
+// Every login is approved straight away for the user in claims.
+type mockProvider struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+
+	// What the portal sent to the authorize endpoint for the last login.
+	challenge string
+	nonce     string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := mockProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                p.URL,
+			"authorization_endpoint":                p.URL + "/authorize",
+			"token_endpoint":                        p.URL + "/token",
+			"jwks_uri":                              p.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &p.key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("code_challenge_method") != "S256" {
+			http.Error(w, "expected a PKCE challenge", http.StatusBadRequest)
+			return
+		}
+		p.challenge = q.Get("code_challenge")
+		p.nonce = q.Get("nonce")
+
+		back, _ := url.Parse(q.Get("redirect_uri"))
+		back.RawQuery = url.Values{"code": {"the-code"}, "state": {q.Get("state")}}.Encode()
+		http.Redirect(w, r, back.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "the-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != p.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     p.idToken(t),
+		})
+	})
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return &p
+}
+
+func (p *mockProvider) idToken(t *testing.T) string {
+	claims := map[string]interface{}{
+		"iss":   p.URL,
+		"aud":   "portal",
+		"sub":   "1234",
+		"nonce": p.nonce,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for key, value := range p.claims {
+		claims[key] = value
+	}
+	payload, _ := json.Marshal(claims)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: p.key}, (&jose.SignerOptions{}).WithHeader("kid", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := signed.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// Starts a portal that logs in with the provider and returns a browser for it.
+func newOIDCPortal(t *testing.T, provider *mockProvider) (*httptest.Server, *http.Client) {
+	server := web.NewServer(context.Background(), web.ServerOptions{
+		ApiEndpoint: "http://127.0.0.1:1",
+		Features:    services.NewFeatureFlags(map[string]bool{}),
+		OIDC: services.OIDCConfig{
+			Issuer:      provider.URL,
+			ClientID:    "portal",
+			Scopes:      []string{"openid", "profile", "groups"},
+			GroupsClaim: "groups",
+			Groups:      map[string]services.Role{"portal-editors": services.RoleEditor},
+			Name:        "mock",
+		},
+		SessionTimeout: time.Hour,
+	})
+	portal := httptest.NewServer(server.Router)
+	t.Cleanup(portal.Close)
+
+	jar, _ := cookiejar.New(nil)
+	return portal, &http.Client{Jar: jar}
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	res, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+	return string(body)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	provider := newMockProvider(t)
+	provider.claims = map[string]interface{}{"preferred_username": "jamie", "groups": []string{"staff", "portal-editors"}}
+	portal, browser := newOIDCPortal(t, provider)
+
+	body := get(t, browser, portal.URL+"/settings/sources/import")
+	if !strings.Contains(body, "Log in with mock") {
+		t.Fatal("expected the settings to need a login")
+	}
+
+	body = get(t, browser, portal.URL+"/login/oidc?next=/settings/sources/import")
+	if !strings.Contains(body, "jamie") || !strings.Contains(body, "Import OPML") {
+		t.Errorf("expected to be logged in and sent on to the import, got %v", body)
+	}
+
+	body = get(t, browser, portal.URL+"/settings/outputs/discord/webhooks")
+	if !strings.Contains(body, "this page needs the admin role") {
+		t.Errorf("expected the editor group to not reach the web hooks, got %v", body)
+	}
+}
+
+func TestOIDCLoginWithoutGroup(t *testing.T) {
+	provider := newMockProvider(t)
+	provider.claims = map[string]interface{}{"preferred_username": "sam", "groups": []string{"staff"}}
+	portal, browser := newOIDCPortal(t, provider)
+
+	body := get(t, browser, portal.URL+"/login/oidc?next=/settings/sources/import")
+	if !strings.Contains(body, "sam is not in a group that can use the portal") {
+		t.Errorf("expected the login to be turned away, got %v", body)
+	}
+
+	// A callback that was not started by this browser is turned away as well.
+	body = get(t, browser, portal.URL+"/login/oidc/callback?code=the-code&state=made-up")
+	if !strings.Contains(body, "another browser") {
+		t.Errorf("expected a made up state to be rejected, got %v", body)
+	}
+}
+
+func TestOIDCPendingLoginsAreCapped(t *testing.T) {
+	provider := newMockProvider(t)
+	portal, _ := newOIDCPortal(t, provider)
+
+	// Stop at the redirect to the provider, so the logins are started but never finished.
+	jar, _ := cookiejar.New(nil)
+	browser := &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	start := func(client *http.Client) string {
+		res, err := client.Get(portal.URL + "/login/oidc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		location, err := url.Parse(res.Header.Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return location.Query().Get("state")
+	}
+
+	state := start(browser)
+	others := &http.Client{CheckRedirect: browser.CheckRedirect}
+	for i := 0; i < 1000; i++ {
+		start(others)
+	}
+
+	// The first login was the oldest, so it made room for the others.
+	body := get(t, browser, portal.URL+"/login/oidc/callback?code=the-code&state="+state)
+	if !strings.Contains(body, "the login has expired") {
+		t.Errorf("expected the oldest login to be dropped, got %v", body)
+	}
+}
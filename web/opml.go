@@ -0,0 +1,138 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/jtom38/newsbot/portal/admin"
+	"github.com/jtom38/newsbot/portal/api"
+)
+
+const (
+	importModePreview = "preview"
+	importModeImport  = "import"
+)
+
+var (
+	pageSettingsSourcesImport = parseSettings("templates/settings/sources/import.html", "templates/settings/sources/entries.html")
+)
+
+type ImportSourcesParam struct {
+	Title    string
+	Subtitle string
+	Errors   []string
+	OPML     string
+	Entries  []admin.SourceEntry
+
+	// The number of entries that would be created, and if any of them have tags.
+	New     int
+	HasTags bool
+}
+
+// Returns how many of the entries would be created so the page can offer to add them,
+// and if any of those have tags the api can not set.
+func countNew(entries []admin.SourceEntry) (int, bool) {
+	count, tags := 0, false
+	for _, entry := range entries {
+		if entry.IsNew() {
+			count++
+			tags = tags || len(entry.Tags) > 0
+		}
+	}
+	return count, tags
+}
+
+// /settings/sources/export.opml
+//
+// This is a download, so a failure is a plain 502 like the feeds rather than an html page saved as the file.
+func (s SettingsRouter) ExportOPML(w http.ResponseWriter, r *http.Request) {
+	items, err := s._api.Sources().List(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to export the sources", "error", err)
+		http.Error(w, "the sources could not be loaded from the collector", http.StatusBadGateway)
+		return
+	}
+
+	var sources []api.Source
+	if items != nil {
+		sources = *items
+	}
+
+	w.Header().Set("Content-Type", admin.OPMLContentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=\"newsbot-sources.opml\"")
+	err = admin.WriteOPML(w, sources)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to write the opml", "error", err)
+	}
+}
+
+// /settings/sources/import
+func (s SettingsRouter) ImportSourcesForm(w http.ResponseWriter, r *http.Request) {
+	param := ImportSourcesParam{
+		Title:    "Import Sources",
+		Subtitle: "Bring your feeds over from another reader with OPML",
+	}
+	render(w, r, pageSettingsSourcesImport, param)
+}
+
+// This previews the uploaded or pasted OPML, and adds the new sources when the import button was used.
+func (s SettingsRouter) ImportSourcesPost(w http.ResponseWriter, r *http.Request) {
+	param := ImportSourcesParam{
+		Title:    "Import Sources",
+		Subtitle: "Check what will be added",
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	err := r.ParseMultipartForm(maxUploadSize)
+	if err != nil && err != http.ErrNotMultipart {
+		param.Errors = append(param.Errors, err.Error())
+		render(w, r, pageSettingsSourcesImport, param)
+		return
+	}
+
+	param.OPML = r.FormValue("opml")
+	file, _, err := r.FormFile("file")
+	if err == nil {
+		defer file.Close()
+		body, err := io.ReadAll(file)
+		if err != nil {
+			param.Errors = append(param.Errors, err.Error())
+			render(w, r, pageSettingsSourcesImport, param)
+			return
+		}
+		if len(body) > 0 {
+			param.OPML = string(body)
+		}
+	}
+
+	if param.OPML == "" {
+		param.Errors = append(param.Errors, "Upload an OPML file or paste one in")
+		render(w, r, pageSettingsSourcesImport, param)
+		return
+	}
+
+	entries, err := admin.ParseOPML(bytes.NewBufferString(param.OPML))
+	if err != nil {
+		param.Errors = append(param.Errors, err.Error())
+		render(w, r, pageSettingsSourcesImport, param)
+		return
+	}
+
+	param.Entries, err = admin.CheckSources(r.Context(), s._api, entries)
+	if err != nil {
+		param.Errors = append(param.Errors, err.Error())
+		render(w, r, pageSettingsSourcesImport, param)
+		return
+	}
+	param.New, param.HasTags = countNew(param.Entries)
+
+	if r.FormValue("mode") == importModeImport {
+		param.Entries = admin.AddSources(r.Context(), s._api, param.Entries)
+		param.Subtitle = "Here is what was added"
+		param.New = 0
+	}
+
+	render(w, r, pageSettingsSourcesImport, param)
+}
@@ -0,0 +1,33 @@
+package web_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jtom38/newsbot/portal/web"
+)
+
+func TestExportOPML(t *testing.T) {
+	collector := newMockCollector(t)
+	collector.addSource("reddit", "golang", "go")
+	portal := newPortal(t, collector, web.ServerOptions{})
+
+	res, body := do(t, http.DefaultClient, http.MethodGet, portal.URL+"/settings/sources/export.opml", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %v: %v", res.StatusCode, body)
+	}
+	if !strings.Contains(res.Header.Get("Content-Disposition"), "newsbot-sources.opml") || !strings.Contains(body, "golang") {
+		t.Errorf("expected the sources as a download, got %v %v", res.Header, body)
+	}
+
+	// A collector that is down is a failed download, not a page saved as the file.
+	collector.Close()
+	res, body = do(t, http.DefaultClient, http.MethodGet, portal.URL+"/settings/sources/export.opml", nil)
+	if res.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected 502, got %v: %v", res.StatusCode, body)
+	}
+	if res.Header.Get("Content-Disposition") != "" {
+		t.Errorf("expected no attachment for the error, got '%v'", res.Header.Get("Content-Disposition"))
+	}
+}
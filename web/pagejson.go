@@ -0,0 +1,410 @@
+package web
+
+import (
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jtom38/newsbot/portal/admin"
+	"github.com/jtom38/newsbot/portal/api"
+	"github.com/jtom38/newsbot/portal/services"
+)
+
+// This is what every page returns when it is asked for json with "Accept: application/json".
+// The template params change with the pages, so they are copied into these types which only ever get new fields.
+//
+// Data depends on the page and is null on pages that only have a title, like the ones that confirm a change.
+type PageJson struct {
+	Title    string      `json:"title"`
+	Subtitle string      `json:"subtitle"`
+	Errors   []string    `json:"errors"`
+	Data     interface{} `json:"data"`
+}
+
+// The page params implement this so render can answer with json.
+type jsonPage interface {
+	pageJson() PageJson
+}
+
+// The error page, the status code of the response is set to Code.
+type ErrorJson struct {
+	Code  int    `json:"code"`
+	Error string `json:"error"`
+}
+
+// /articles/list, /articles/newest and /articles/sources/{ID}/list
+type ArticlesJson struct {
+	Items []ArticleJson `json:"items"`
+}
+
+// /articles/{ID}, Source is null when the source of the article could not be found.
+type ArticleJson struct {
+	ID          uuid.UUID   `json:"id"`
+	Title       string      `json:"title"`
+	Url         string      `json:"url"`
+	Pubdate     time.Time   `json:"pubdate"`
+	Description string      `json:"description"`
+	Thumbnail   string      `json:"thumbnail"`
+	Video       string      `json:"video"`
+	AuthorName  string      `json:"authorName"`
+	AuthorImage string      `json:"authorImage"`
+	Tags        []string    `json:"tags"`
+	Source      *SourceJson `json:"source"`
+}
+
+// /articles/sources and the source lists under /settings/sources, Source is set when the list only has one type.
+type SourcesJson struct {
+	Source string       `json:"source,omitempty"`
+	Items  []SourceJson `json:"items"`
+}
+
+type SourceJson struct {
+	ID      uuid.UUID `json:"id"`
+	Source  string    `json:"source"`
+	Name    string    `json:"name"`
+	Url     string    `json:"url"`
+	Enabled bool      `json:"enabled"`
+	Tags    []string  `json:"tags"`
+}
+
+// /settings/sources/{source}/new
+type NewSourceJson struct {
+	Source string `json:"source"`
+}
+
+// /settings/outputs/discord/webhooks
+type WebhooksJson struct {
+	Items []WebhookJson `json:"items"`
+}
+
+// The url of a web hook is what lets anyone post to the channel, so it is left out like it is on the pages.
+type WebhookJson struct {
+	ID      uuid.UUID `json:"id"`
+	Server  string    `json:"server"`
+	Channel string    `json:"channel"`
+	Enabled bool      `json:"enabled"`
+}
+
+// /settings/subscriptions/discord/webhooks
+type SubscriptionsJson struct {
+	Items []SubscriptionJson `json:"items"`
+}
+
+type SubscriptionJson struct {
+	ID      uuid.UUID   `json:"id"`
+	Source  SourceJson  `json:"source"`
+	Webhook WebhookJson `json:"webhook"`
+}
+
+// /settings/subscriptions/discord/webhooks/new, what can be picked on the form.
+type NewSubscriptionJson struct {
+	Sources  []SourceJson  `json:"sources"`
+	Webhooks []WebhookJson `json:"webhooks"`
+}
+
+// /settings
+type CollectorJson struct {
+	Address      string    `json:"address"`
+	Version      string    `json:"version"`
+	LatencyMs    int64     `json:"latencyMs"`
+	CheckedAt    time.Time `json:"checkedAt"`
+	Error        string    `json:"error,omitempty"`
+	VersionError string    `json:"versionError,omitempty"`
+}
+
+// /settings/features
+type FeaturesJson struct {
+	Items []FeatureJson `json:"items"`
+}
+
+type FeatureJson struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Source      string `json:"source"`
+}
+
+// /settings/apply, Plan is set once a manifest has been planned and Applied once it has been applied.
+type ApplyJson struct {
+	Prune    bool           `json:"prune"`
+	Plan     *admin.Plan    `json:"plan"`
+	PlanHash string         `json:"planHash,omitempty"`
+	Applied  []admin.Change `json:"applied"`
+}
+
+// /settings/export and /settings/import, Result is set once a backup has been imported.
+type BackupJson struct {
+	Result *admin.ImportResult `json:"result"`
+}
+
+// /settings/sources/import and /settings/sources/{source}/bulk
+type SourceEntriesJson struct {
+	Source  string              `json:"source,omitempty"`
+	Entries []admin.SourceEntry `json:"entries"`
+	New     int                 `json:"new"`
+	HasTags bool                `json:"hasTags"`
+}
+
+// /settings/users, the password hashes are never included.
+type UsersJson struct {
+	Roles []services.Role `json:"roles"`
+	Items []UserJson      `json:"items"`
+}
+
+type UserJson struct {
+	Name      string        `json:"name"`
+	Role      services.Role `json:"role"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+// Returns true when the client would rather have json than html.
+// Browsers list text/html first, scripts usually only ask for application/json.
+func wantsJson(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return false
+	}
+
+	jsonQ, htmlQ := 0.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case "application/json":
+			jsonQ = q
+		case "text/html":
+			htmlQ = q
+		}
+	}
+
+	return jsonQ > 0 && jsonQ > htmlQ
+}
+
+func renderJson(w http.ResponseWriter, r *http.Request, param interface{}) {
+	page, ok := param.(jsonPage)
+	if !ok {
+		http.Error(w, "this page can not be returned as json", http.StatusNotAcceptable)
+		return
+	}
+
+	body := page.pageJson()
+	if body.Errors == nil {
+		body.Errors = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if e, ok := body.Data.(ErrorJson); ok && e.Code != 0 {
+		w.WriteHeader(e.Code)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(body)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to write the page as json", "path", r.URL.Path, "error", err)
+	}
+}
+
+func newSourceJson(item api.Source) SourceJson {
+	tags := item.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return SourceJson{ID: item.ID, Source: item.Source, Name: item.Name, Url: item.Url, Enabled: item.Enabled, Tags: tags}
+}
+
+func newSourcesJson(source string, items *[]api.Source) SourcesJson {
+	res := SourcesJson{Source: source, Items: []SourceJson{}}
+	if items != nil {
+		for _, item := range *items {
+			res.Items = append(res.Items, newSourceJson(item))
+		}
+	}
+	return res
+}
+
+func newWebhookJson(item api.DiscordWebHooks) WebhookJson {
+	return WebhookJson{ID: item.ID, Server: item.Server, Channel: item.Channel, Enabled: item.Enabled}
+}
+
+func newArticleJson(item api.Article, source *api.Source) ArticleJson {
+	tags := item.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	res := ArticleJson{
+		ID:          item.ID,
+		Title:       item.Title,
+		Url:         item.Url,
+		Pubdate:     item.Pubdate,
+		Description: item.Description,
+		Thumbnail:   item.Thumbnail,
+		Video:       item.Video,
+		AuthorName:  item.AuthorName,
+		AuthorImage: item.AuthorImage,
+		Tags:        tags,
+	}
+	if source != nil && source.ID != uuid.Nil {
+		s := newSourceJson(*source)
+		res.Source = &s
+	}
+	return res
+}
+
+// The entries are only there once a file or list has been posted, the form on its own has none.
+func sourceEntriesJson(entries []admin.SourceEntry) []admin.SourceEntry {
+	if entries == nil {
+		return []admin.SourceEntry{}
+	}
+	return entries
+}
+
+func (p TitlesParam) pageJson() PageJson {
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors}
+}
+
+func (p ErrorParam) pageJson() PageJson {
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors, Data: ErrorJson{Code: p.Code, Error: p.Error}}
+}
+
+func (p UpdateSourceParam) pageJson() PageJson {
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors}
+}
+
+func (p ListArticleParam) pageJson() PageJson {
+	data := ArticlesJson{Items: []ArticleJson{}}
+	if p.Items != nil {
+		for _, item := range *p.Items {
+			source := item.Source
+			data.Items = append(data.Items, newArticleJson(item.Article, &source))
+		}
+	}
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors, Data: data}
+}
+
+func (p DisplayArticleParams) pageJson() PageJson {
+	res := PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors}
+	if p.Article != nil {
+		res.Data = newArticleJson(*p.Article, p.Source)
+	}
+	return res
+}
+
+func (p ListArticleSourcesParam) pageJson() PageJson {
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors, Data: newSourcesJson("", p.Items)}
+}
+
+func (p ListSettingsParam) pageJson() PageJson {
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors, Data: newSourcesJson(p.SourceName, p.Items)}
+}
+
+func (p NewSourceParam) pageJson() PageJson {
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors, Data: NewSourceJson{Source: p.SourceName}}
+}
+
+func (p ListOutputDiscordWebHooks) pageJson() PageJson {
+	data := WebhooksJson{Items: []WebhookJson{}}
+	if p.Items != nil {
+		for _, item := range *p.Items {
+			data.Items = append(data.Items, newWebhookJson(item))
+		}
+	}
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors, Data: data}
+}
+
+func (p ListSubscriptionsParam) pageJson() PageJson {
+	data := SubscriptionsJson{Items: []SubscriptionJson{}}
+	for _, item := range p.Items {
+		data.Items = append(data.Items, SubscriptionJson{
+			ID:      item.Subscription.ID,
+			Source:  newSourceJson(item.Source),
+			Webhook: newWebhookJson(item.Output),
+		})
+	}
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors, Data: data}
+}
+
+func (p NewDiscordWebHookSubscriptionFormParam) pageJson() PageJson {
+	data := NewSubscriptionJson{Sources: []SourceJson{}, Webhooks: []WebhookJson{}}
+	for _, item := range p.Sources {
+		data.Sources = append(data.Sources, newSourceJson(item))
+	}
+	for _, item := range p.Outputs {
+		data.Webhooks = append(data.Webhooks, newWebhookJson(item))
+	}
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors, Data: data}
+}
+
+func (p SettingsIndexParam) pageJson() PageJson {
+	data := CollectorJson{
+		Address:   p.Collector.Address,
+		Version:   p.Collector.Info.Version,
+		LatencyMs: p.Collector.Info.Latency.Milliseconds(),
+		CheckedAt: p.Collector.CheckedAt,
+	}
+	if p.Collector.Error != nil {
+		data.Error = p.Collector.Error.Error()
+	}
+	if p.Collector.VersionError != nil {
+		data.VersionError = p.Collector.VersionError.Error()
+	}
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors, Data: data}
+}
+
+func (p FeaturesParam) pageJson() PageJson {
+	data := FeaturesJson{Items: []FeatureJson{}}
+	for _, item := range p.Items {
+		data.Items = append(data.Items, FeatureJson{Name: item.Name, Description: item.Description, Enabled: item.Enabled, Source: item.Source})
+	}
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors, Data: data}
+}
+
+func (p ApplyParam) pageJson() PageJson {
+	data := ApplyJson{Prune: p.Prune, Plan: p.Plan, PlanHash: p.PlanHash, Applied: p.Applied}
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors, Data: data}
+}
+
+func (p BackupParam) pageJson() PageJson {
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors, Data: BackupJson{Result: p.Result}}
+}
+
+func (p ImportSourcesParam) pageJson() PageJson {
+	data := SourceEntriesJson{Entries: sourceEntriesJson(p.Entries), New: p.New, HasTags: p.HasTags}
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors, Data: data}
+}
+
+func (p BulkSourcesParam) pageJson() PageJson {
+	data := SourceEntriesJson{Source: p.SourceName, Entries: sourceEntriesJson(p.Entries), New: p.New, HasTags: p.HasTags}
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors, Data: data}
+}
+
+func (p LoginParam) pageJson() PageJson {
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors}
+}
+
+func (p ListUsersParam) pageJson() PageJson {
+	data := UsersJson{Roles: services.Roles, Items: []UserJson{}}
+	for _, item := range p.Items {
+		data.Items = append(data.Items, UserJson{Name: item.Name, Role: item.Role, CreatedAt: item.CreatedAt})
+	}
+	return PageJson{Title: p.Title, Subtitle: p.Subtitle, Errors: p.Errors, Data: data}
+}
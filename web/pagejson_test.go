@@ -0,0 +1,149 @@
+package web_test
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jtom38/newsbot/portal/web"
+)
+
+func TestPageJsonAccept(t *testing.T) {
+	portal := newPortal(t, newMockCollector(t), web.ServerOptions{})
+
+	cases := []struct {
+		accept string
+		json   bool
+	}{
+		{accept: "", json: false},
+		{accept: "application/json", json: true},
+		{accept: "application/json;q=0", json: false},
+		{accept: "application/json; q=0.0, text/html;q=0.1", json: false},
+		{accept: "*/*", json: false},
+		{accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", json: false},
+		{accept: "text/html, application/json", json: false},
+		{accept: "application/json, text/html;q=0.5", json: true},
+		{accept: "text/html;q=0.5, application/json;q=0.9", json: true},
+		{accept: "application/json;q=bogus", json: false},
+	}
+	for _, c := range cases {
+		header := http.Header{}
+		if c.accept != "" {
+			header.Set("Accept", c.accept)
+		}
+
+		res, body := do(t, http.DefaultClient, http.MethodGet, portal.URL+"/", header)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("'%v': expected 200, got %v: %v", c.accept, res.StatusCode, body)
+		}
+
+		isJson := strings.HasPrefix(res.Header.Get("Content-Type"), "application/json")
+		if isJson != c.json {
+			t.Errorf("'%v': expected json %v, got content type '%v'", c.accept, c.json, res.Header.Get("Content-Type"))
+		}
+
+		// Caches have to keep the html and the json apart.
+		if res.Header.Get("Vary") != "Accept" {
+			t.Errorf("'%v': expected Vary: Accept, got '%v'", c.accept, res.Header.Get("Vary"))
+		}
+	}
+}
+
+func TestPageJsonArticles(t *testing.T) {
+	collector := newMockCollector(t)
+	reddit := collector.addSource("reddit", "golang", "go")
+	collector.addArticle(reddit, "Go 1.22 is out", "go")
+	collector.addArticle(reddit, "Fuzzing in practice")
+	portal := newPortal(t, collector, web.ServerOptions{})
+
+	var page map[string]interface{}
+	getJson(t, portal.URL+"/articles/list", http.StatusOK, &page)
+	expectKeys(t, "page", page, "data", "errors", "subtitle", "title")
+	if page["title"] != "Newest Posts" {
+		t.Errorf("unexpected title %v", page["title"])
+	}
+	if errors, ok := page["errors"].([]interface{}); !ok || len(errors) != 0 {
+		t.Errorf("expected errors to be an empty list, got %v", page["errors"])
+	}
+
+	data := page["data"].(map[string]interface{})
+	expectKeys(t, "data", data, "items")
+
+	items := data["items"].([]interface{})
+	if len(items) != 2 {
+		t.Fatalf("expected 2 articles, got %v", len(items))
+	}
+	item := items[0].(map[string]interface{})
+	expectKeys(t, "article", item, "authorImage", "authorName", "description", "id", "pubdate", "source", "tags", "thumbnail", "title", "url", "video")
+	if item["title"] != "Go 1.22 is out" || item["pubdate"] != "2024-02-01T12:00:00Z" {
+		t.Errorf("unexpected article %v", item)
+	}
+	// A missing list is still a list, so clients do not have to check for null.
+	if tags, ok := items[1].(map[string]interface{})["tags"].([]interface{}); !ok || len(tags) != 0 {
+		t.Errorf("expected no tags to be an empty list, got %v", items[1].(map[string]interface{})["tags"])
+	}
+
+	source := item["source"].(map[string]interface{})
+	expectKeys(t, "source", source, "enabled", "id", "name", "source", "tags", "url")
+	if source["id"] != reddit.ID.String() {
+		t.Errorf("expected the source of the article, got %v", source)
+	}
+}
+
+func TestPageJsonSources(t *testing.T) {
+	collector := newMockCollector(t)
+	collector.addSource("reddit", "golang", "go", "news")
+	collector.addSource("youtube", "gophers")
+	portal := newPortal(t, collector, web.ServerOptions{})
+
+	var page map[string]interface{}
+	getJson(t, portal.URL+"/articles/sources", http.StatusOK, &page)
+	expectKeys(t, "page", page, "data", "errors", "subtitle", "title")
+
+	// Source is only set on lists that have one type of source.
+	data := page["data"].(map[string]interface{})
+	expectKeys(t, "data", data, "items")
+
+	items := data["items"].([]interface{})
+	if len(items) != 2 {
+		t.Fatalf("expected 2 sources, got %v", len(items))
+	}
+	item := items[0].(map[string]interface{})
+	expectKeys(t, "source", item, "enabled", "id", "name", "source", "tags", "url")
+	if item["name"] != "golang" || item["source"] != "reddit" || item["enabled"] != true {
+		t.Errorf("unexpected source %v", item)
+	}
+	if tags := item["tags"].([]interface{}); len(tags) != 2 || tags[1] != "news" {
+		t.Errorf("unexpected tags %v", tags)
+	}
+}
+
+func TestPageJsonArticleErrors(t *testing.T) {
+	portal := newPortal(t, newMockCollector(t), web.ServerOptions{})
+
+	var page web.PageJson
+	getJson(t, portal.URL+"/articles/not-an-id/", http.StatusOK, &page)
+	if len(page.Errors) != 1 {
+		t.Errorf("expected the bad id to be reported, got %v", page.Errors)
+	}
+	if page.Data != nil {
+		t.Errorf("expected no data without an article, got %v", page.Data)
+	}
+}
+
+// Fails the test when the json object does not have exactly these keys, so fields are not renamed or dropped by accident.
+func expectKeys(t *testing.T, name string, value map[string]interface{}, keys ...string) {
+	t.Helper()
+
+	var got []string
+	for key := range value {
+		got = append(got, key)
+	}
+	sort.Strings(got)
+
+	if !reflect.DeepEqual(got, keys) {
+		t.Errorf("%v: expected the keys %v, got %v", name, keys, got)
+	}
+}
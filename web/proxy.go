@@ -0,0 +1,132 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const (
+	headerForwardedFor   = "X-Forwarded-For"
+	headerForwardedHost  = "X-Forwarded-Host"
+	headerForwardedProto = "X-Forwarded-Proto"
+)
+
+type basePathKey struct{}
+
+// Middleware that makes the base path available to render.
+func withBasePath(base string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), basePathKey{}, base)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Returns the path the portal is mounted under, like "/newsbot", or a blank string when it is at the root.
+func basePathFrom(ctx context.Context) string {
+	base, _ := ctx.Value(basePathKey{}).(string)
+	return base
+}
+
+// Returns the link template func for the request.
+// The parts are joined and put after the base path, so {{ link "/articles/" .ID }} becomes /newsbot/articles/<id>.
+func linkFunc(ctx context.Context) func(parts ...interface{}) string {
+	base := basePathFrom(ctx)
+	return func(parts ...interface{}) string {
+		var b strings.Builder
+		b.WriteString(base)
+		for _, part := range parts {
+			fmt.Fprint(&b, part)
+		}
+		return b.String()
+	}
+}
+
+// Returns the url the portal can be reached at for this request, like https://example.com/newsbot.
+func baseURL(r *http.Request) string {
+	scheme := r.URL.Scheme
+	if scheme == "" {
+		scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+	}
+	return fmt.Sprintf("%v://%v%v", scheme, r.Host, basePathFrom(r.Context()))
+}
+
+// Middleware that trusts the X-Forwarded headers when the request came from one of the trusted proxies.
+// The client address, host and scheme on the request are replaced so logs and generated urls match
+// what the browser sees.  Requests from anywhere else have the headers ignored.
+func forwarded(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	isTrusted := func(addr netip.Addr) bool {
+		for _, prefix := range trusted {
+			if prefix.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.URL.Scheme = "http"
+			if r.TLS != nil {
+				r.URL.Scheme = "https"
+			}
+
+			remote, ok := remoteAddr(r)
+			if !ok || !isTrusted(remote) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Walk the chain from the closest hop and stop at the first address we do not trust,
+			// anything before that could have been made up by the client.
+			hops := strings.Split(strings.Join(r.Header.Values(headerForwardedFor), ","), ",")
+			for i := len(hops) - 1; i >= 0; i-- {
+				addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+				if err != nil {
+					break
+				}
+				remote = addr
+				if !isTrusted(addr) {
+					break
+				}
+			}
+			r.RemoteAddr = netip.AddrPortFrom(remote, 0).String()
+
+			if proto := r.Header.Get(headerForwardedProto); proto == "http" || proto == "https" {
+				r.URL.Scheme = proto
+			}
+			if host := r.Header.Get(headerForwardedHost); host != "" {
+				r.Host = host
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Returns the address of the client that opened the connection.
+// Requests over a unix socket come from a local proxy, so they are treated as loopback.
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if host == "" || host == "@" {
+		return netip.IPv6Loopback(), true
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return addr, false
+	}
+	return addr.Unmap(), true
+}
@@ -2,24 +2,107 @@ package web
 
 import (
 	"embed"
+	"fmt"
 	"html/template"
+	"log/slog"
+	"net/http"
+
+	"github.com/jtom38/newsbot/portal/services"
 )
 
 //go:embed *
 var files embed.FS
 
+// Every page that has been parsed, so readiness checks can look them over.
+var pages []*template.Template
+
+// Functions the templates can call.
+// These are placeholders so the templates parse, render binds them to the request being served.
+var templateFuncs = template.FuncMap{
+	"requestId":  func() string { return "" },
+	"feature":    func(name string) bool { return false },
+	"link":       func(parts ...interface{}) string { return "" },
+	"user":       func() string { return "" },
+	"logins":     func() bool { return false },
+	"localUsers": func() bool { return false },
+	"can":        func(role string) bool { return false },
+}
+
 func parse(file string) *template.Template {
-	temp := template.Must(template.New("layout.html").ParseFS(files, "layout.html", file))
-	return temp
+	temp := template.Must(template.New("layout.html").Funcs(templateFuncs).ParseFS(files, "layout.html", file))
+	return register(temp)
 }
 
 // This will load layout, requested template, and Articles menu
 func parseArticles(file string) *template.Template {
-	temp := template.Must(template.New("layout.html").ParseFS(files, "layout.html", "templates/articles/menu.html", file))
-	return temp
+	temp := template.Must(template.New("layout.html").Funcs(templateFuncs).ParseFS(files, "layout.html", "templates/articles/menu.html", file))
+	return register(temp)
+}
+
+// This will load layout, the settings menu, and the requested templates.
+// Extra files hold templates that are shared by a few of the settings pages.
+func parseSettings(file ...string) *template.Template {
+	patterns := append([]string{"layout.html", "templates/settings/menu.html"}, file...)
+	temp := template.Must(template.New("layout.html").Funcs(templateFuncs).ParseFS(files, patterns...))
+	return register(temp)
 }
 
-func parseSettings(file string) *template.Template {
-	temp := template.Must(template.New("layout.html").ParseFS(files, "layout.html", "templates/settings/menu.html", file))
+func register(temp *template.Template) *template.Template {
+	pages = append(pages, temp)
 	return temp
 }
+
+// This writes the page out with the template functions bound to the current request.
+// The parsed page is cloned first, html/template will not allow a clone once the original has been executed.
+//
+// Requests that ask for json get the param as a PageJson instead of the page.
+func render(w http.ResponseWriter, r *http.Request, page *template.Template, param interface{}) {
+	w.Header().Add("Vary", "Accept")
+	if wantsJson(r) {
+		renderJson(w, r, param)
+		return
+	}
+
+	temp, err := page.Clone()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to clone the template", "template", page.Name(), "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	temp.Funcs(template.FuncMap{
+		"requestId":  func() string { return services.RequestID(r.Context()) },
+		"feature":    featuresFrom(r.Context()).Enabled,
+		"link":       linkFunc(r.Context()),
+		"user":       func() string { return userFrom(r.Context()) },
+		"logins":     func() bool { return authFrom(r.Context()).enabled },
+		"localUsers": func() bool { return authFrom(r.Context()).localUsers },
+		"can":        func(role string) bool { return roleAllows(r.Context(), services.Role(role)) },
+	})
+
+	// The error page is still a page, but scripts and caches need to see that the request failed.
+	if e, ok := param.(ErrorParam); ok && e.Code != 0 {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(e.Code)
+	}
+
+	err = temp.Execute(w, param)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to render the page", "path", r.URL.Path, "error", err)
+	}
+}
+
+// Checks that every page was parsed and has something to render in the layout.
+func templatesReady() error {
+	if len(pages) == 0 {
+		return fmt.Errorf("no templates have been parsed")
+	}
+
+	for _, page := range pages {
+		if page.Lookup("content") == nil {
+			return fmt.Errorf("template '%v' is missing the content block", page.Name())
+		}
+	}
+
+	return nil
+}
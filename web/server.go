@@ -3,11 +3,15 @@ package web
 import (
 	"context"
 	"net/http"
+	"net/netip"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 
 	"github.com/jtom38/newsbot/portal/api"
+	"github.com/jtom38/newsbot/portal/services"
 )
 
 var (
@@ -22,16 +26,80 @@ type HttpServer struct {
 	//api *api.ApiClient
 	api api.CollectorApi
 
-	ctx context.Context
+	// The RestClient shared by the api routes, kept so its counters can be reported.
+	rest *api.RestClient
+
+	// What we last heard from the collector
+	collector *collectorStatus
+
+	metrics *portalMetrics
+
+	// Turns parts of the portal on or off
+	features *services.FeatureFlags
+
+	ctx     context.Context
+	started time.Time
+
+	// Set once the portal has started to shut down.
+	shuttingDown atomic.Bool
+
+	basePath string
+	proxies  []netip.Prefix
+
+	// The settings pages need a login when either of these are set.
+	users    *services.UserStore
+	oidc     *oidcLogin
+	sessions *sessionStore
+	logins   *loginLimiter
+}
+
+// ServerOptions are the settings the HttpServer is built with.
+type ServerOptions struct {
+	// The address of the collector api.
+	ApiEndpoint string
+	Rest        api.RestClientOptions
+
+	Features *services.FeatureFlags
+
+	// The path the portal is served under when it is behind a reverse proxy, like "/newsbot".
+	BasePath string
+
+	// The X-Forwarded headers are only used on requests from these addresses.
+	TrustedProxies []netip.Prefix
+
+	// The users that can log in with a password.
+	// The settings pages are open to everyone when this is nil and there is no OIDC issuer.
+	Users *services.UserStore
+
+	// The OpenID Connect provider people can log in with.
+	OIDC services.OIDCConfig
+
+	// How long a login lasts.
+	SessionTimeout time.Duration
 }
 
-func NewServer(ctx context.Context, ApiEndpoint string) *HttpServer {
+func NewServer(ctx context.Context, opts ServerOptions) *HttpServer {
 	s := HttpServer{
-		ctx: ctx,
+		ctx:       ctx,
+		collector: newCollectorStatus(opts.ApiEndpoint),
+		started:   time.Now(),
+		metrics:   newPortalMetrics(),
+		features:  opts.Features,
+		basePath:  opts.BasePath,
+		proxies:   opts.TrustedProxies,
+		users:     opts.Users,
+		sessions:  newSessionStore(opts.SessionTimeout),
+		logins:    newLoginLimiter(),
 	}
 
-	api := api.New(ApiEndpoint)
-	s.api = api
+	if opts.OIDC.Enabled() {
+		s.oidc = newOIDCLogin(opts.OIDC)
+	}
+
+	opts.Rest.Observer = s.metrics
+	s.rest = api.NewRestClientWithOptions(opts.Rest)
+	s.api = api.NewWithRestClient(opts.ApiEndpoint, s.rest)
+	s.metrics.registerRestStats(s.rest)
 
 	s.Router = chi.NewRouter()
 	s.MountMiddleware()
@@ -40,18 +108,71 @@ func NewServer(ctx context.Context, ApiEndpoint string) *HttpServer {
 	return &s
 }
 
+// This applies new settings to the client that talks to the collector.
+func (s *HttpServer) ReconfigureApi(opts api.RestClientOptions) {
+	s.rest.Reconfigure(opts)
+}
+
+// Drops every cached collector response and returns how many there were.
+func (s *HttpServer) PurgeApiCache() int {
+	return s.rest.PurgeCache()
+}
+
 func (s *HttpServer) MountMiddleware() {
-	s.Router.Use(middleware.Logger)
+	s.Router.Use(forwarded(s.proxies))
+	s.Router.Use(requestID)
+	s.Router.Use(tracing)
+	s.Router.Use(requestLogger)
+	s.Router.Use(withBasePath(s.basePath))
+	s.Router.Use(withFeatures(s.features))
+	s.Router.Use(s.withSession)
+	// The metrics go around the Recoverer, so a panic is counted as the 500 it turns into.
+	s.Router.Use(s.metrics.middleware)
 	s.Router.Use(middleware.Recoverer)
 }
 
 func (s *HttpServer) MountRoutes() {
-	s.Router.Get("/", s.Index)
+	// Probes and scrapers usually talk to the portal directly, so these stay at the root.
+	s.mountHealthRoutes(s.Router)
+
+	if s.basePath == "" {
+		s.mountPages(s.Router)
+		return
+	}
+
+	pages := chi.NewRouter()
+	s.mountHealthRoutes(pages)
+	s.mountPages(pages)
+	s.Router.Mount(s.basePath, pages)
 
-	s.Router.Mount("/articles", s.articlesRouter())
+	s.Router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, s.basePath+"/", http.StatusFound)
+	})
+}
+
+func (s *HttpServer) mountPages(r chi.Router) {
+	r.Get("/", s.Index)
+
+	r.Mount("/articles", s.articlesRouter())
+	r.Mount("/feeds", s.feedsRouter())
+	r.Mount("/api/"+ApiVersion, s.apiV1Router())
+	r.Get("/graphql", s.GraphQL)
+	r.Post("/graphql", s.GraphQL)
+
+	if s.loginsEnabled() {
+		r.Get("/login", s.LoginForm)
+		r.Post("/logout", s.Logout)
+	}
+	if s.users != nil {
+		r.Post("/login", s.LoginPost)
+	}
+	if s.oidc != nil {
+		r.Get("/login/oidc", s.OIDCLogin)
+		r.Get("/login/oidc/callback", s.OIDCCallback)
+	}
 
-	settings := NewSettingsRouter(&s.api)
-	s.Router.Mount("/settings", settings.GetRouter())
+	settings := NewSettingsRouter(&s.api, s.collector, s.users)
+	r.With(s.requireLogin).Mount("/settings", settings.GetRouter())
 
 	//s.Router.Mount("/settings/sources", s.sourcesRouter())
 	//s.Router.Mount("/settings/outputs", s.outputsRouter())
@@ -62,5 +183,5 @@ func (s *HttpServer) Index(w http.ResponseWriter, r *http.Request) {
 		Title:    "Welcome",
 		Subtitle: "Your news destination",
 	}
-	index.Execute(w, param)
+	render(w, r, index, param)
 }
@@ -2,13 +2,13 @@ package web
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/jtom38/newsbot/portal/api"
+	"github.com/jtom38/newsbot/portal/services"
 )
 
 const (
@@ -37,58 +37,123 @@ var (
 )
 
 type SettingsRouter struct {
-	_api api.CollectorApi
+	_api      api.CollectorApi
+	collector *collectorStatus
+
+	// Nil when logins are turned off.
+	users *services.UserStore
 }
 
-func NewSettingsRouter(api *api.CollectorApi) SettingsRouter {
+func NewSettingsRouter(api *api.CollectorApi, collector *collectorStatus, users *services.UserStore) SettingsRouter {
 	c := SettingsRouter{
-		_api: *api,
+		_api:      *api,
+		collector: collector,
+		users:     users,
 	}
 	return c
 }
 
+// Viewers can not see any of the settings, editors can change sources and subscriptions
+// and admins can change everything else.
 func (s *SettingsRouter) GetRouter() http.Handler {
 	r := chi.NewRouter()
+	r.Use(requireRole(services.RoleEditor))
 
 	r.Get("/", s.SettingsIndex)
 
 	r.Post("/sources/disable", s.DisableSourceById)
 	r.Post("/sources/enable", s.EnableSourceById)
-
-	r.Get("/sources/reddit", s.ListReddit)
-	r.Get("/sources/reddit/new", s.NewRedditForm)
-	r.Post("/sources/reddit/new", s.NewRedditPost)
-
-	r.Get("/sources/youtube", s.ListYoutube)
-	r.Get("/sources/youtube/new", s.NewYouTubeForm)
-	r.Post("/sources/youtube/new", s.NewYouTubePost)
-
-	r.Get("/sources/twitch", s.ListTwitch)
-	r.Get("/sources/twitch/new", s.NewTwitchForm)
-	r.Post("/sources/twitch/new", s.NewTwitchPost)
-
-	r.Get("/sources/ffxiv", s.ListFfxiv)
-
-	r.Get("/outputs/discord/webhooks", s.ListDiscordWebHooks)
-	r.Get("/outputs/discord/webhooks/new", s.NewDiscordWebHooksForm)
-	r.Post("/outputs/discord/webhooks/new", s.NewDiscordWebhookPost)
-	r.Post("/outputs/discord/webhooks/disable", s.DisableDiscordWebhook)
-	r.Post("/outputs/discord/webhooks/enable", s.EnableDiscordWebhook)
-
-	r.Get("/subscriptions/discord/webhooks", s.ListDiscordWebHookSubscriptions)
-	r.Get("/subscriptions/discord/webhooks/new", s.NewDiscordWebHookSubscriptionForm)
-	r.Post("/subscriptions/discord/webhooks/new", s.NewDiscordWebHookSubscriptionPost)
-	r.Post("/subscriptions/discord/webhooks/delete", s.DeleteDiscordWebHookSubscription)
+	r.Get("/sources/import", s.ImportSourcesForm)
+	r.Post("/sources/import", s.ImportSourcesPost)
+	r.Get("/sources/export.opml", s.ExportOPML)
+
+	r.Group(func(r chi.Router) {
+		r.Use(requireRole(services.RoleAdmin))
+		r.Get("/features", s.ListFeatures)
+		r.Get("/apply", s.ApplyForm)
+		r.Post("/apply", s.ApplyPost)
+		r.Get("/export", s.Export)
+		r.Post("/import", s.Import)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(requireFeature(services.Feature_RedditSources))
+		r.Get("/sources/reddit", s.ListReddit)
+		r.Get("/sources/reddit/new", s.NewRedditForm)
+		r.Get("/sources/reddit/bulk", s.BulkSourcesForm(RedditSourceName))
+		r.Post("/sources/reddit/bulk", s.BulkSourcesPost(RedditSourceName))
+		r.Post("/sources/reddit/new", s.NewRedditPost)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(requireFeature(services.Feature_YoutubeSources))
+		r.Get("/sources/youtube", s.ListYoutube)
+		r.Get("/sources/youtube/new", s.NewYouTubeForm)
+		r.Get("/sources/youtube/bulk", s.BulkSourcesForm(YoutubeSourceName))
+		r.Post("/sources/youtube/bulk", s.BulkSourcesPost(YoutubeSourceName))
+		r.Post("/sources/youtube/new", s.NewYouTubePost)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(requireFeature(services.Feature_TwitchSources))
+		r.Get("/sources/twitch", s.ListTwitch)
+		r.Get("/sources/twitch/new", s.NewTwitchForm)
+		r.Get("/sources/twitch/bulk", s.BulkSourcesForm(TwitchSourceName))
+		r.Post("/sources/twitch/bulk", s.BulkSourcesPost(TwitchSourceName))
+		r.Post("/sources/twitch/new", s.NewTwitchPost)
+	})
+
+	r.With(requireFeature(services.Feature_FfxivSources)).Get("/sources/ffxiv", s.ListFfxiv)
+
+	r.Group(func(r chi.Router) {
+		r.Use(requireRole(services.RoleAdmin))
+		r.Get("/outputs/discord/webhooks", s.ListDiscordWebHooks)
+		r.Get("/outputs/discord/webhooks/new", s.NewDiscordWebHooksForm)
+		r.Post("/outputs/discord/webhooks/new", s.NewDiscordWebhookPost)
+		r.Post("/outputs/discord/webhooks/disable", s.DisableDiscordWebhook)
+		r.Post("/outputs/discord/webhooks/enable", s.EnableDiscordWebhook)
+	})
+
+	if s.users != nil {
+		r.Group(func(r chi.Router) {
+			r.Use(requireRole(services.RoleAdmin))
+			r.Get("/users", s.ListUsers)
+			r.Post("/users/new", s.NewUserPost)
+			r.Post("/users/role", s.UserRolePost)
+			r.Post("/users/delete", s.DeleteUserPost)
+		})
+	}
+
+	r.Group(func(r chi.Router) {
+		r.Use(requireFeature(services.Feature_Subscriptions))
+		r.Get("/subscriptions/discord/webhooks", s.ListDiscordWebHookSubscriptions)
+		r.Get("/subscriptions/discord/webhooks/new", s.NewDiscordWebHookSubscriptionForm)
+		r.Post("/subscriptions/discord/webhooks/new", s.NewDiscordWebHookSubscriptionPost)
+		r.Post("/subscriptions/discord/webhooks/delete", s.DeleteDiscordWebHookSubscription)
+	})
 
 	return r
 }
 
+type SettingsIndexParam struct {
+	Title     string
+	Subtitle  string
+	Errors    []string
+	Collector CollectorState
+}
+
 func (s SettingsRouter) SettingsIndex(w http.ResponseWriter, r *http.Request) {
-	param := TitlesParam{
-		Title:    "Configuration",
-		Subtitle: "It doesn't do anything on its own",
+	param := SettingsIndexParam{
+		Title:     "Configuration",
+		Subtitle:  "It doesn't do anything on its own",
+		Collector: s.collector.get(),
 	}
-	pageSettingIndex.Execute(w, param)
+
+	if param.Collector.VersionError != nil {
+		param.Errors = append(param.Errors, param.Collector.VersionError.Error())
+	}
+
+	render(w, r, pageSettingIndex, param)
 }
 
 type UpdateSourceParam struct {
@@ -107,28 +172,28 @@ func (s *SettingsRouter) EnableSourceById(w http.ResponseWriter, r *http.Request
 	err := r.ParseForm()
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageSettingsUpdated.Execute(w, param)
+		render(w, r, pageSettingsUpdated, param)
 		return
 	}
 
 	id := r.Form.Get("id")
 	if id == "" {
 		param.Errors = append(param.Errors, "The Source ID is missing")
-		pageSettingsUpdated.Execute(w, param)
+		render(w, r, pageSettingsUpdated, param)
 		return
 	}
 
 	uid, err := uuid.Parse(id)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageSettingsUpdated.Execute(w, param)
+		render(w, r, pageSettingsUpdated, param)
 		return
 	}
 
 	err = s._api.Sources().Enable(r.Context(), uid)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageSettingsUpdated.Execute(w, param)
+		render(w, r, pageSettingsUpdated, param)
 		return
 	}
 
@@ -136,9 +201,7 @@ func (s *SettingsRouter) EnableSourceById(w http.ResponseWriter, r *http.Request
 		Title:    "Source was enabled",
 		Subtitle: "Head on back to see the change.",
 	}
-	if pageSettingsUpdated.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSettingsUpdated, param)
 }
 
 // /settings/sources/disable?id
@@ -151,28 +214,28 @@ func (s *SettingsRouter) DisableSourceById(w http.ResponseWriter, r *http.Reques
 	err := r.ParseForm()
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageSettingsUpdated.Execute(w, param)
+		render(w, r, pageSettingsUpdated, param)
 		return
 	}
 
 	id := r.Form.Get("id")
 	if id == "" {
 		param.Errors = append(param.Errors, "ID value was missing")
-		pageSettingsUpdated.Execute(w, param)
+		render(w, r, pageSettingsUpdated, param)
 		return
 	}
 
 	uid, err := uuid.Parse(id)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageSettingsUpdated.Execute(w, param)
+		render(w, r, pageSettingsUpdated, param)
 		return
 	}
 
 	err = s._api.Sources().Disable(r.Context(), uid)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageSettingsUpdated.Execute(w, param)
+		render(w, r, pageSettingsUpdated, param)
 		return
 	}
 
@@ -180,9 +243,7 @@ func (s *SettingsRouter) DisableSourceById(w http.ResponseWriter, r *http.Reques
 		Title:    "Source was disabled",
 		Subtitle: "Head on back to see the change",
 	}
-	if pageSettingsUpdated.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSettingsUpdated, param)
 }
 
 type ListSettingsParam struct {
@@ -204,15 +265,13 @@ func (s SettingsRouter) ListReddit(w http.ResponseWriter, r *http.Request) {
 	items, err := s._api.Sources().ListBySource(r.Context(), RedditSourceName)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageSettingSourcesList.Execute(w, param)
+		render(w, r, pageSettingSourcesList, param)
 		return
 	}
 
 	param.Items = items
 
-	if pageSettingSourcesList.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSettingSourcesList, param)
 }
 
 func (s SettingsRouter) ListYoutube(w http.ResponseWriter, r *http.Request) {
@@ -225,15 +284,13 @@ func (s SettingsRouter) ListYoutube(w http.ResponseWriter, r *http.Request) {
 	items, err := s._api.Sources().ListBySource(r.Context(), YoutubeSourceName)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageSettingSourcesList.Execute(w, param)
+		render(w, r, pageSettingSourcesList, param)
 		return
 	}
 
 	param.Items = items
 
-	if pageSettingSourcesList.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSettingSourcesList, param)
 }
 
 func (s SettingsRouter) ListTwitch(w http.ResponseWriter, r *http.Request) {
@@ -246,15 +303,13 @@ func (s SettingsRouter) ListTwitch(w http.ResponseWriter, r *http.Request) {
 	items, err := s._api.Sources().ListBySource(r.Context(), TwitchSourceName)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageSettingSourcesList.Execute(w, param)
+		render(w, r, pageSettingSourcesList, param)
 		return
 	}
 
 	param.Items = items
 
-	if pageSettingSourcesList.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSettingSourcesList, param)
 }
 
 func (s SettingsRouter) ListFfxiv(w http.ResponseWriter, r *http.Request) {
@@ -267,15 +322,13 @@ func (s SettingsRouter) ListFfxiv(w http.ResponseWriter, r *http.Request) {
 	items, err := s._api.Sources().ListBySource(r.Context(), FFXIVSourceName)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageSettingSourcesList.Execute(w, param)
+		render(w, r, pageSettingSourcesList, param)
 		return
 	}
 
 	param.Items = items
 
-	if pageSettingSourcesList.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSettingSourcesList, param)
 }
 
 type NewSourceParam struct {
@@ -286,15 +339,12 @@ type NewSourceParam struct {
 }
 
 func (s SettingsRouter) NewRedditForm(w http.ResponseWriter, r *http.Request) {
-	var err error
 	param := NewSourceParam{
 		Title:      "Create a new Reddit monitor",
 		Subtitle:   "",
 		SourceName: RedditSourceName,
 	}
-	if pageSettingsNewRedditForm.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSettingsNewRedditForm, param)
 }
 
 // This handles the data from the form and sends it to the API
@@ -307,14 +357,14 @@ func (s SettingsRouter) NewRedditPost(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
 		param.Error = err.Error()
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
 	name := r.Form.Get("name")
 	if name == "" {
 		param.Error = "Subreddit name was missing from the form"
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
@@ -322,7 +372,7 @@ func (s SettingsRouter) NewRedditPost(w http.ResponseWriter, r *http.Request) {
 	err = s._api.Sources().NewReddit(r.Context(), name, uri)
 	if err != nil {
 		param.Error = err.Error()
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
@@ -331,21 +381,16 @@ func (s SettingsRouter) NewRedditPost(w http.ResponseWriter, r *http.Request) {
 		Subtitle: "Head on back to see the update",
 	}
 
-	if pageSourceUpdated.Execute(w, p); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSourceUpdated, p)
 }
 
 func (s SettingsRouter) NewTwitchForm(w http.ResponseWriter, r *http.Request) {
-	var err error
 	param := NewSourceParam{
 		Title:      "Create a new Twitch monitor",
 		Subtitle:   "",
 		SourceName: TwitchSourceName,
 	}
-	if pageSettingsNewTwitchForm.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSettingsNewTwitchForm, param)
 }
 
 func (s SettingsRouter) NewTwitchPost(w http.ResponseWriter, r *http.Request) {
@@ -357,21 +402,21 @@ func (s SettingsRouter) NewTwitchPost(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
 		param.Error = err.Error()
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
 	name := r.Form.Get("name")
 	if name == "" {
 		param.Error = "Subreddit name was missing from the form"
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
 	err = s._api.Sources().NewTwitch(r.Context(), name)
 	if err != nil {
 		param.Error = err.Error()
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
@@ -380,21 +425,16 @@ func (s SettingsRouter) NewTwitchPost(w http.ResponseWriter, r *http.Request) {
 		Subtitle: "Head on back to see the update",
 	}
 
-	if pageSourceUpdated.Execute(w, p); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSourceUpdated, p)
 }
 
 func (s SettingsRouter) NewYouTubeForm(w http.ResponseWriter, r *http.Request) {
-	var err error
 	param := NewSourceParam{
 		Title:      "Create a new YouTube monitor",
 		Subtitle:   "",
 		SourceName: YoutubeSourceName,
 	}
-	if pageSettingsNewYouTubeForm.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSettingsNewYouTubeForm, param)
 }
 
 func (s SettingsRouter) NewYouTubePost(w http.ResponseWriter, r *http.Request) {
@@ -406,28 +446,28 @@ func (s SettingsRouter) NewYouTubePost(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
 		param.Error = err.Error()
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
 	name := r.Form.Get("name")
 	if name == "" {
 		param.Error = "Channel name was missing from the form."
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
 	url := r.Form.Get("url")
 	if url == "" {
 		param.Error = "URL name was missing from the form."
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
 	err = s._api.Sources().NewYouTube(r.Context(), name, url)
 	if err != nil {
 		param.Error = err.Error()
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
@@ -436,9 +476,7 @@ func (s SettingsRouter) NewYouTubePost(w http.ResponseWriter, r *http.Request) {
 		Subtitle: "Head on back to see the update",
 	}
 
-	if pageSourceUpdated.Execute(w, p); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSourceUpdated, p)
 }
 
 type ListOutputDiscordWebHooks struct {
@@ -461,19 +499,14 @@ func (s SettingsRouter) ListDiscordWebHooks(w http.ResponseWriter, r *http.Reque
 
 	param.Items = items
 
-	if pageSettingsDiscordWebhooksList.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSettingsDiscordWebhooksList, param)
 }
 
 func (s SettingsRouter) NewDiscordWebHooksForm(w http.ResponseWriter, r *http.Request) {
-	var err error
 	param := TitlesParam{
 		Title: "New Discord Webhook",
 	}
-	if pageSettingsDiscordWebhooksForm.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSettingsDiscordWebhooksForm, param)
 }
 
 func (s SettingsRouter) NewDiscordWebhookPost(w http.ResponseWriter, r *http.Request) {
@@ -485,35 +518,35 @@ func (s SettingsRouter) NewDiscordWebhookPost(w http.ResponseWriter, r *http.Req
 	err := r.ParseForm()
 	if err != nil {
 		param.Error = err.Error()
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
 	server := r.Form.Get("server")
 	if server == "" {
 		param.Error = "Server name was missing from the form."
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
 	url := r.Form.Get("url")
 	if url == "" {
 		param.Error = "URL name was missing from the form."
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
 	channel := r.Form.Get("channel")
 	if channel == "" {
 		param.Error = "Channel was missing from the form."
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
 	err = s._api.Outputs().DiscordWebHook().New(r.Context(), server, channel, url)
 	if err != nil {
 		param.Error = err.Error()
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
@@ -522,9 +555,7 @@ func (s SettingsRouter) NewDiscordWebhookPost(w http.ResponseWriter, r *http.Req
 		Subtitle: "Head on back to see the update",
 	}
 
-	if pageSourceUpdated.Execute(w, p); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSourceUpdated, p)
 }
 
 func (s SettingsRouter) DisableDiscordWebhook(w http.ResponseWriter, r *http.Request) {
@@ -536,28 +567,28 @@ func (s SettingsRouter) DisableDiscordWebhook(w http.ResponseWriter, r *http.Req
 	err := r.ParseForm()
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageSettingsUpdated.Execute(w, param)
+		render(w, r, pageSettingsUpdated, param)
 		return
 	}
 
 	id := r.Form.Get("id")
 	if id == "" {
 		param.Errors = append(param.Errors, "ID value was missing")
-		pageSettingsUpdated.Execute(w, param)
+		render(w, r, pageSettingsUpdated, param)
 		return
 	}
 
 	uid, err := uuid.Parse(id)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageSettingsUpdated.Execute(w, param)
+		render(w, r, pageSettingsUpdated, param)
 		return
 	}
 
 	err = s._api.Outputs().DiscordWebHook().Disable(r.Context(), uid)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageSettingsUpdated.Execute(w, param)
+		render(w, r, pageSettingsUpdated, param)
 		return
 	}
 
@@ -565,9 +596,7 @@ func (s SettingsRouter) DisableDiscordWebhook(w http.ResponseWriter, r *http.Req
 		Title:    "Webhook was disabled",
 		Subtitle: "Head on back to see the change",
 	}
-	if pageSettingsUpdated.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSettingsUpdated, param)
 }
 
 func (s SettingsRouter) EnableDiscordWebhook(w http.ResponseWriter, r *http.Request) {
@@ -579,28 +608,28 @@ func (s SettingsRouter) EnableDiscordWebhook(w http.ResponseWriter, r *http.Requ
 	err := r.ParseForm()
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageSettingsUpdated.Execute(w, param)
+		render(w, r, pageSettingsUpdated, param)
 		return
 	}
 
 	id := r.Form.Get("id")
 	if id == "" {
 		param.Errors = append(param.Errors, "ID value was missing")
-		pageSettingsUpdated.Execute(w, param)
+		render(w, r, pageSettingsUpdated, param)
 		return
 	}
 
 	uid, err := uuid.Parse(id)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageSettingsUpdated.Execute(w, param)
+		render(w, r, pageSettingsUpdated, param)
 		return
 	}
 
 	err = s._api.Outputs().DiscordWebHook().Enable(r.Context(), uid)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageSettingsUpdated.Execute(w, param)
+		render(w, r, pageSettingsUpdated, param)
 		return
 	}
 
@@ -608,9 +637,7 @@ func (s SettingsRouter) EnableDiscordWebhook(w http.ResponseWriter, r *http.Requ
 		Title:    "Webhook was enabled",
 		Subtitle: "Head on back to see the change",
 	}
-	if pageSettingsUpdated.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSettingsUpdated, param)
 }
 
 type ListSubscriptionsParam struct {
@@ -665,9 +692,7 @@ func (s SettingsRouter) ListDiscordWebHookSubscriptions(w http.ResponseWriter, r
 
 	param.Items = details
 
-	if pageSettingsSubscriptionsList.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSettingsSubscriptionsList, param)
 }
 
 type NewDiscordWebHookSubscriptionFormParam struct {
@@ -697,9 +722,7 @@ func (s SettingsRouter) NewDiscordWebHookSubscriptionForm(w http.ResponseWriter,
 	param.Outputs = *outputs
 	param.Sources = *sources
 
-	if pageSettingsSubscriptionsForm.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSettingsSubscriptionsForm, param)
 }
 
 func (s SettingsRouter) NewDiscordWebHookSubscriptionPost(w http.ResponseWriter, r *http.Request) {
@@ -710,7 +733,7 @@ func (s SettingsRouter) NewDiscordWebHookSubscriptionPost(w http.ResponseWriter,
 	err := r.ParseForm()
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
@@ -718,7 +741,7 @@ func (s SettingsRouter) NewDiscordWebHookSubscriptionPost(w http.ResponseWriter,
 	if source == "" {
 		msg := "Source was missing from the form."
 		param.Errors = append(param.Errors, msg)
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
@@ -726,7 +749,7 @@ func (s SettingsRouter) NewDiscordWebHookSubscriptionPost(w http.ResponseWriter,
 	sourceRecord, err := s._api.Sources().GetBySourceAndName(r.Context(), strings.TrimSpace(stringSplit[0]), strings.TrimSpace(stringSplit[1]))
 	if err != nil {
 		param.Errors = append(param.Errors, "The ID value is missing.")
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
@@ -734,7 +757,7 @@ func (s SettingsRouter) NewDiscordWebHookSubscriptionPost(w http.ResponseWriter,
 	if DiscordWebHook == "" {
 		msg := "DiscordWebHook name was missing from the form."
 		param.Errors = append(param.Errors, msg)
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
@@ -742,20 +765,18 @@ func (s SettingsRouter) NewDiscordWebHookSubscriptionPost(w http.ResponseWriter,
 	outputRecord, err := s._api.Outputs().DiscordWebHook().GetByServerAndChannel(r.Context(), strings.TrimSpace(outputSplit[0]), strings.TrimSpace(outputSplit[1]))
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
 	err = s._api.Subscriptions().New(r.Context(), outputRecord[0].ID, sourceRecord.ID)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
-	if pageSourceUpdated.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSourceUpdated, param)
 }
 
 // This will query for a ID value to find the requested subscription to delete.
@@ -768,28 +789,28 @@ func (s SettingsRouter) DeleteDiscordWebHookSubscription(w http.ResponseWriter,
 	err := r.ParseForm()
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
 	id := r.Form.Get("id")
 	if id == "" {
 		param.Errors = append(param.Errors, "The ID value is missing.")
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
 	uid, err := uuid.Parse(id)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
 	err = s._api.Subscriptions().Delete(r.Context(), uid)
 	if err != nil {
 		param.Errors = append(param.Errors, err.Error())
-		pageError.Execute(w, param)
+		render(w, r, pageError, param)
 		return
 	}
 
@@ -797,7 +818,5 @@ func (s SettingsRouter) DeleteDiscordWebHookSubscription(w http.ResponseWriter,
 		Title:    "Subscription was deleted",
 		Subtitle: "Head on back to see the change",
 	}
-	if pageSettingsUpdated.Execute(w, param); err != nil {
-		log.Print(err)
-	}
+	render(w, r, pageSettingsUpdated, param)
 }
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// A user name or address that fails this many logins has to wait until the oldest one is loginWindow old.
+	maxFailedLogins = 5
+	loginWindow     = 15 * time.Minute
+)
+
+// loginLimiter slows down password guessing by counting the failed logins for each user name and address.
+// Like the sessions it is kept in memory, so a restart clears it.
+type loginLimiter struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+}
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{
+		failures: make(map[string][]time.Time),
+	}
+}
+
+// Both the name and the address are counted, so guessing one password for many users is slowed down
+// as well as many passwords for one user.
+func loginKeys(r *http.Request, name string) []string {
+	keys := []string{"user:" + strings.ToLower(name)}
+	if addr, ok := remoteAddr(r); ok {
+		keys = append(keys, "addr:"+addr.String())
+	}
+	return keys
+}
+
+// Returns how long until any of the keys can try again, 0 when they all can.
+func (l *loginLimiter) wait(keys []string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range keys {
+		failures := l.recent(key, now)
+		if len(failures) < maxFailedLogins {
+			continue
+		}
+		if until := failures[len(failures)-maxFailedLogins].Add(loginWindow).Sub(now); until > wait {
+			wait = until
+		}
+	}
+	return wait
+}
+
+func (l *loginLimiter) fail(keys []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Names that are only tried once would pile up, so clear out the old ones while we are here.
+	now := time.Now()
+	for key := range l.failures {
+		l.recent(key, now)
+	}
+
+	for _, key := range keys {
+		l.failures[key] = append(l.failures[key], now)
+	}
+}
+
+// A good password clears the failures for the user, the address keeps its count
+// so logging in to one account does not buy more guesses at the others.
+func (l *loginLimiter) succeed(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.failures, "user:"+strings.ToLower(name))
+}
+
+// Returns the failures for the key inside the window and drops the older ones.
+func (l *loginLimiter) recent(key string, now time.Time) []time.Time {
+	failures := l.failures[key]
+	for len(failures) > 0 && now.Sub(failures[0]) >= loginWindow {
+		failures = failures[1:]
+	}
+
+	if len(failures) == 0 {
+		delete(l.failures, key)
+		return nil
+	}
+	l.failures[key] = failures
+	return failures
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/jtom38/newsbot/portal/services"
+)
+
+var (
+	pageSettingsUsers = parseSettings("templates/settings/users.html")
+)
+
+type ListUsersParam struct {
+	Title    string
+	Subtitle string
+	Errors   []string
+	Items    []services.User
+	Roles    []services.Role
+}
+
+// /settings/users
+func (s SettingsRouter) ListUsers(w http.ResponseWriter, r *http.Request) {
+	param := ListUsersParam{
+		Title:    "Users",
+		Subtitle: "Viewers can browse articles, editors can change sources and subscriptions, admins can change everything",
+		Items:    s.users.List(),
+		Roles:    services.Roles,
+	}
+
+	render(w, r, pageSettingsUsers, param)
+}
+
+// /settings/users/new
+func (s SettingsRouter) NewUserPost(w http.ResponseWriter, r *http.Request) {
+	param := UpdateSourceParam{
+		Title:    "User was not added",
+		Subtitle: "See error for details.",
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		param.Errors = append(param.Errors, err.Error())
+		render(w, r, pageSettingsUpdated, param)
+		return
+	}
+
+	name := r.PostForm.Get("name")
+	role, err := services.ParseRole(r.PostForm.Get("role"))
+	if err == nil {
+		err = s.users.Add(name, r.PostForm.Get("password"), role)
+	}
+	if err != nil {
+		param.Errors = append(param.Errors, err.Error())
+		render(w, r, pageSettingsUpdated, param)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "added a user", "user", name, "role", role, "by", userFrom(r.Context()))
+	param = UpdateSourceParam{
+		Title:    "User was added",
+		Subtitle: "Head on back to see the change",
+	}
+	render(w, r, pageSettingsUpdated, param)
+}
+
+// /settings/users/role
+func (s SettingsRouter) UserRolePost(w http.ResponseWriter, r *http.Request) {
+	param := UpdateSourceParam{
+		Title:    "Role was not changed",
+		Subtitle: "See error for details.",
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		param.Errors = append(param.Errors, err.Error())
+		render(w, r, pageSettingsUpdated, param)
+		return
+	}
+
+	name := r.PostForm.Get("name")
+	role, err := services.ParseRole(r.PostForm.Get("role"))
+	if err == nil {
+		err = s.users.SetRole(name, role)
+	}
+	if err != nil {
+		param.Errors = append(param.Errors, err.Error())
+		render(w, r, pageSettingsUpdated, param)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "changed the role of a user", "user", name, "role", role, "by", userFrom(r.Context()))
+	param = UpdateSourceParam{
+		Title:    "Role was changed",
+		Subtitle: "Head on back to see the change",
+	}
+	render(w, r, pageSettingsUpdated, param)
+}
+
+// /settings/users/delete
+func (s SettingsRouter) DeleteUserPost(w http.ResponseWriter, r *http.Request) {
+	param := UpdateSourceParam{
+		Title:    "User was not deleted",
+		Subtitle: "See error for details.",
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		param.Errors = append(param.Errors, err.Error())
+		render(w, r, pageSettingsUpdated, param)
+		return
+	}
+
+	name := r.PostForm.Get("name")
+	err = s.users.Delete(name)
+	if err != nil {
+		param.Errors = append(param.Errors, err.Error())
+		render(w, r, pageSettingsUpdated, param)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "deleted a user", "user", name, "by", userFrom(r.Context()))
+	param = UpdateSourceParam{
+		Title:    "User was deleted",
+		Subtitle: "Head on back to see the change",
+	}
+	render(w, r, pageSettingsUpdated, param)
+}